@@ -0,0 +1,150 @@
+package api
+
+import (
+	"errors"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// AreaHandler 提供一般位置 (STORAGE、DISPATCH、STANDBY、EXTRA) 的佔用 API
+type AreaHandler struct {
+	areas *peripheral.AreaManager
+}
+
+func NewAreaHandler(areas *peripheral.AreaManager) *AreaHandler {
+	return &AreaHandler{areas: areas}
+}
+
+func (h *AreaHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /areas", h.list)
+	mux.HandleFunc("GET /areas/{locationId}", h.get)
+	mux.HandleFunc("POST /areas/{locationId}/claims", h.claim)
+	mux.HandleFunc("DELETE /areas/{locationId}/claims", h.release)
+}
+
+type claimView struct {
+	Holder    string     `json:"holder"`
+	Kind      string     `json:"kind"`
+	ClaimedAt time.Time  `json:"claimedAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+type areaView struct {
+	LocationID string      `json:"locationId"`
+	Name       string      `json:"name"`
+	Type       string      `json:"type"`
+	Capacity   int         `json:"capacity"`
+	Claims     []claimView `json:"claims"`
+}
+
+func toAreaView(a peripheral.Area) areaView {
+	v := areaView{
+		LocationID: a.LocationID,
+		Name:       a.Name,
+		Type:       string(a.Type),
+		Capacity:   a.Capacity,
+		Claims:     make([]claimView, 0, len(a.Claims)),
+	}
+	for _, c := range a.Claims {
+		cv := claimView{Holder: c.Holder, Kind: string(c.Kind), ClaimedAt: c.ClaimedAt}
+		if !c.ExpiresAt.IsZero() {
+			cv.ExpiresAt = &c.ExpiresAt
+		}
+		v.Claims = append(v.Claims, cv)
+	}
+	return v
+}
+
+// list 可用 ?type=STORAGE,DISPATCH 過濾種類，?occupied=true 只列有佔用的位置
+func (h *AreaHandler) list(w http.ResponseWriter, r *http.Request) {
+	types := splitQuery(r.URL.Query()["type"])
+	occupied := r.URL.Query().Get("occupied") == "true"
+
+	out := []areaView{}
+	for _, a := range h.areas.Snapshot() {
+		if types != nil && !types[string(a.Type)] {
+			continue
+		}
+		if occupied && len(a.Claims) == 0 {
+			continue
+		}
+		out = append(out, toAreaView(a))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationID < out[j].LocationID })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *AreaHandler) get(w http.ResponseWriter, r *http.Request) {
+	h.reply(w)(h.areas.Area(r.PathValue("locationId")))
+}
+
+type claimRequest struct {
+	// Holder 是 AMR 或貨物的 id，沒帶時用 X-Actor
+	Holder string `json:"holder"`
+	// Kind 是 robot 或 cargo，沒帶時是 robot
+	Kind string `json:"kind"`
+	// TTLMs 是佔用多久 (毫秒)，沒帶時用 areas.default_ttl，負數表示不會過期
+	TTLMs int64 `json:"ttlMs"`
+}
+
+// claim 佔用位置，同一個 holder 再 claim 一次只更新過期時間
+func (h *AreaHandler) claim(w http.ResponseWriter, r *http.Request) {
+	var req claimRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+	kind := peripheral.OccupantKind(req.Kind)
+	if kind == "" {
+		kind = peripheral.OccupantRobot
+	}
+
+	ttl := time.Duration(req.TTLMs) * time.Millisecond
+	h.reply(w)(h.areas.Claim(actorContext(r), r.PathValue("locationId"), req.Holder, kind, ttl))
+}
+
+// release 釋放 ?holder= 的佔用，沒帶時釋放 X-Actor 的佔用。
+// 釋放所有佔用 (操作員排除卡住的位置) 必須明確帶 ?all=true
+func (h *AreaHandler) release(w http.ResponseWriter, r *http.Request) {
+	ctx := actorContext(r)
+	holder := r.URL.Query().Get("holder")
+
+	if r.URL.Query().Get("all") == "true" {
+		if holder != "" {
+			writeError(w, http.StatusBadRequest, "holder and all=true cannot be used together")
+			return
+		}
+	} else if holder == "" {
+		holder = infra.ActorFrom(ctx)
+	}
+	h.reply(w)(h.areas.Release(ctx, r.PathValue("locationId"), holder))
+}
+
+func (h *AreaHandler) reply(w http.ResponseWriter) func(peripheral.Area, error) {
+	return func(a peripheral.Area, err error) {
+		if err != nil {
+			writeAreaError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toAreaView(a))
+	}
+}
+
+func writeAreaError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, peripheral.ErrAreaNotFound),
+		errors.Is(err, peripheral.ErrClaimNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, peripheral.ErrInvalidOccupant):
+		status = http.StatusBadRequest
+	case errors.Is(err, peripheral.ErrAreaOccupied):
+		status = http.StatusConflict
+	}
+
+	writeError(w, status, err.Error())
+}
@@ -0,0 +1,19 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// RegisterDebug 註冊 /debug/state 與 net/http/pprof，state 回傳的內容會直接輸出成 JSON
+func RegisterDebug(mux *http.ServeMux, state func() interface{}) {
+	mux.HandleFunc("GET /debug/state", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, state())
+	})
+
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
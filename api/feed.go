@@ -0,0 +1,284 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feed 以 Server-Sent Events 推送週邊狀態：連線時先送完整快照，之後每次變動送一筆。
+// 變動來源跟 gRPC 推送相同 (peripheral.EventStackChanged、peripheral.EventAreaChanged)。
+//
+// 可用 query 過濾：?location=A,B 只收指定位置，?type=stack 只收指定週邊類型。
+type Feed struct {
+	ysm       *peripheral.YFYStackManager
+	areas     *peripheral.AreaManager // nil 表示不推送位置佔用
+	bus       *infra.EventBus
+	logger    infra.Logger
+	keepalive time.Duration
+}
+
+func NewFeed(ysm *peripheral.YFYStackManager, bus *infra.EventBus, logger infra.Logger) *Feed {
+	return &Feed{
+		ysm:       ysm,
+		bus:       bus,
+		logger:    logger.With(infra.FieldComponent, "feed"),
+		keepalive: 15 * time.Second,
+	}
+}
+
+// SetAreas 讓 feed 一併推送一般位置的佔用 (type=area)
+func (f *Feed) SetAreas(am *peripheral.AreaManager) {
+	f.areas = am
+}
+
+func (f *Feed) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /feed", f.serve)
+}
+
+type feedFilter struct {
+	locations map[string]bool
+	types     map[string]bool
+}
+
+func parseFilter(r *http.Request) feedFilter {
+	return feedFilter{
+		locations: splitQuery(r.URL.Query()["location"]),
+		types:     splitQuery(r.URL.Query()["type"]),
+	}
+}
+
+// splitQuery 支援 ?a=1,2 與 ?a=1&a=2 兩種寫法，沒帶就回傳 nil (不過濾)
+func splitQuery(values []string) map[string]bool {
+	var set map[string]bool
+	for _, v := range values {
+		for _, part := range strings.Split(v, ",") {
+			if part = strings.TrimSpace(part); part != "" {
+				if set == nil {
+					set = make(map[string]bool)
+				}
+				set[part] = true
+			}
+		}
+	}
+	return set
+}
+
+func (ff feedFilter) matchType(t string) bool {
+	return ff.types == nil || ff.types[t]
+}
+
+func (ff feedFilter) matchLocation(locID string) bool {
+	return ff.locations == nil || ff.locations[locID]
+}
+
+func (ff feedFilter) match(c peripheral.StackChange) bool {
+	if !ff.matchType(c.Peripheral) {
+		return false
+	}
+	if c.Type == peripheral.ChangeTransfer && ff.matchLocation(c.FromLocationID) {
+		return true
+	}
+	return c.Type == peripheral.ChangeReset || ff.matchLocation(c.LocationID)
+}
+
+type snapshotEvent struct {
+	ScriptID string      `json:"scriptId"`
+	Stacks   []stackView `json:"stacks"`
+	Areas    []areaView  `json:"areas,omitempty"`
+}
+
+type areaEvent struct {
+	LocationID string    `json:"locationId"`
+	Area       areaView  `json:"area"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+type changeEvent struct {
+	Type       peripheral.ChangeType `json:"type"`
+	Peripheral string                `json:"peripheral"`
+	LocationID string                `json:"locationId"`
+	ScriptID   string                `json:"scriptId"`
+	Stack      *stackView            `json:"stack,omitempty"`
+	Timestamp  time.Time             `json:"timestamp"`
+
+	FromLocationID string     `json:"fromLocationId,omitempty"`
+	FromStack      *stackView `json:"fromStack,omitempty"`
+}
+
+func (f *Feed) serve(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming not supported")
+		return
+	}
+
+	filter := parseFilter(r)
+
+	// 先訂閱再取快照，中間的變動會重複送但不會漏
+	changes := make(chan peripheral.StackChange, 256)
+	resync := make(chan struct{}, 1)
+	subID := f.bus.Subscribe(peripheral.EventStackChanged, func(data interface{}) {
+		c, ok := data.(peripheral.StackChange)
+		if !ok || !filter.match(c) {
+			return
+		}
+		select {
+		case changes <- c:
+		default:
+			// 客戶端跟不上，丟掉累積的變動改送一次完整快照
+			select {
+			case resync <- struct{}{}:
+			default:
+			}
+		}
+	})
+	defer f.bus.Unsubscribe(peripheral.EventStackChanged, subID)
+
+	areaChanges := make(chan peripheral.AreaChange, 256)
+	if f.areas != nil && filter.matchType(peripheral.PeripheralArea) {
+		areaSubID := f.bus.Subscribe(peripheral.EventAreaChanged, func(data interface{}) {
+			c, ok := data.(peripheral.AreaChange)
+			if !ok || (c.Type != peripheral.ChangeReset && !filter.matchLocation(c.LocationID)) {
+				return
+			}
+			select {
+			case areaChanges <- c:
+			default:
+				select {
+				case resync <- struct{}{}:
+				default:
+				}
+			}
+		})
+		defer f.bus.Unsubscribe(peripheral.EventAreaChanged, areaSubID)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	if err := f.sendSnapshot(w, filter); err != nil {
+		return
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(f.keepalive)
+	defer ticker.Stop()
+
+	for {
+		var err error
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-resync:
+			drain(changes)
+			drainAreas(areaChanges)
+			err = f.sendSnapshot(w, filter)
+		case c := <-changes:
+			if c.Type == peripheral.ChangeReset {
+				err = f.sendSnapshot(w, filter)
+			} else {
+				err = writeEvent(w, "change", toChangeEvent(c))
+			}
+		case c := <-areaChanges:
+			if c.Type == peripheral.ChangeReset {
+				err = f.sendSnapshot(w, filter)
+			} else {
+				err = writeEvent(w, "area", areaEvent{LocationID: c.LocationID, Area: toAreaView(*c.Area), Timestamp: c.Timestamp})
+			}
+		case <-ticker.C:
+			_, err = fmt.Fprint(w, ": ping\n\n")
+		}
+
+		if err != nil {
+			f.logger.Debug("feed client %s gone: %v", r.RemoteAddr, err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (f *Feed) sendSnapshot(w http.ResponseWriter, filter feedFilter) error {
+	ev := snapshotEvent{
+		ScriptID: f.ysm.ScriptID(),
+		Stacks:   []stackView{},
+	}
+
+	if filter.matchType(peripheral.PeripheralStack) {
+		for locID, s := range f.ysm.Snapshot() {
+			if filter.matchLocation(locID) {
+				ev.Stacks = append(ev.Stacks, toStackView(locID, s))
+			}
+		}
+		sort.Slice(ev.Stacks, func(i, j int) bool { return ev.Stacks[i].LocationID < ev.Stacks[j].LocationID })
+	}
+
+	if f.areas != nil && filter.matchType(peripheral.PeripheralArea) {
+		ev.Areas = []areaView{}
+		for locID, a := range f.areas.Snapshot() {
+			if filter.matchLocation(locID) {
+				ev.Areas = append(ev.Areas, toAreaView(a))
+			}
+		}
+		sort.Slice(ev.Areas, func(i, j int) bool { return ev.Areas[i].LocationID < ev.Areas[j].LocationID })
+	}
+
+	return writeEvent(w, "snapshot", ev)
+}
+
+func toChangeEvent(c peripheral.StackChange) changeEvent {
+	ev := changeEvent{
+		Type:       c.Type,
+		Peripheral: c.Peripheral,
+		LocationID: c.LocationID,
+		ScriptID:   c.ScriptID,
+		Timestamp:  c.Timestamp,
+	}
+	if c.Stack != nil {
+		v := toStackView(c.LocationID, *c.Stack)
+		ev.Stack = &v
+	}
+	if c.FromStack != nil {
+		v := toStackView(c.FromLocationID, *c.FromStack)
+		ev.FromLocationID = c.FromLocationID
+		ev.FromStack = &v
+	}
+	return ev
+}
+
+func writeEvent(w http.ResponseWriter, event string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
+
+func drain(ch <-chan peripheral.StackChange) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
+
+func drainAreas(ch <-chan peripheral.AreaChange) {
+	for {
+		select {
+		case <-ch:
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"kenmec/peripheral/jimmy/peripheral"
+	"net/http"
+)
+
+type groupView struct {
+	GroupID    string `json:"groupId"`
+	Name       string `json:"name"`
+	Members    int    `json:"members"`
+	Disabled   int    `json:"disabled"`
+	Reserved   int    `json:"reserved"`
+	Capacity   int    `json:"capacity"`
+	CargoCount int    `json:"cargoCount"`
+	FreeSlots  int    `json:"freeSlots"`
+	Available  bool   `json:"available"`
+}
+
+func toGroupView(g peripheral.GroupSummary) groupView {
+	return groupView{
+		GroupID:    g.GroupID,
+		Name:       g.Name,
+		Members:    g.Members,
+		Disabled:   g.Disabled,
+		Reserved:   g.Reserved,
+		Capacity:   g.Capacity,
+		CargoCount: g.CargoCount,
+		FreeSlots:  g.FreeSlots,
+		Available:  g.Available(),
+	}
+}
+
+func (h *StackHandler) listGroups(w http.ResponseWriter, r *http.Request) {
+	sums := h.ysm.GroupSummaries()
+
+	out := make([]groupView, 0, len(sums))
+	for _, g := range sums {
+		out = append(out, toGroupView(g))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *StackHandler) getGroup(w http.ResponseWriter, r *http.Request) {
+	g, err := h.ysm.GroupSummary(r.PathValue("groupId"))
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toGroupView(g))
+}
+
+type groupPatch struct {
+	Disable *bool `json:"disable"`
+}
+
+// patchGroup 一次停用或啟用群組裡的所有堆疊
+func (h *StackHandler) patchGroup(w http.ResponseWriter, r *http.Request) {
+	groupID := r.PathValue("groupId")
+
+	var req groupPatch
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Disable == nil {
+		writeError(w, http.StatusBadRequest, "disable is required")
+		return
+	}
+
+	if err := h.ysm.DisableGroup(actorContext(r), groupID, *req.Disable); err != nil {
+		writeStackError(w, err)
+		return
+	}
+	h.getGroup(w, r)
+}
+
+// clearGroup 移除群組裡所有堆疊的貨物，回傳被移除的貨物
+func (h *StackHandler) clearGroup(w http.ResponseWriter, r *http.Request) {
+	removed, err := h.ysm.ClearGroup(actorContext(r), r.PathValue("groupId"))
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	out := make([]cargoView, 0, len(removed))
+	for _, c := range removed {
+		out = append(out, toCargoView(c))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
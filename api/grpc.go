@@ -0,0 +1,67 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"kenmec/peripheral/jimmy/peripheral"
+	stackpb "kenmec/peripheral/jimmy/protoGen"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CargoServer 實作 gRPC 的 CargoService
+type CargoServer struct {
+	stackpb.UnimplementedCargoServiceServer
+
+	ysm *peripheral.YFYStackManager
+}
+
+func NewCargoServer(ysm *peripheral.YFYStackManager) *CargoServer {
+	return &CargoServer{ysm: ysm}
+}
+
+func (s *CargoServer) FindCargo(ctx context.Context, req *stackpb.FindCargoRequest) (*stackpb.FindCargoResponse, error) {
+	var (
+		key   peripheral.CargoKey
+		value string
+	)
+
+	switch k := req.Key.(type) {
+	case *stackpb.FindCargoRequest_Id:
+		key, value = peripheral.ByCargoID, k.Id
+	case *stackpb.FindCargoRequest_CustomId:
+		key, value = peripheral.ByCustomID, k.CustomId
+	case *stackpb.FindCargoRequest_MetadataValue:
+		key, value = peripheral.ByMetadata, k.MetadataValue
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of id, custom_id or metadata_value is required")
+	}
+	if value == "" {
+		return nil, status.Error(codes.InvalidArgument, "lookup value must not be empty")
+	}
+
+	locs, err := s.ysm.FindCargo(key, value)
+	if errors.Is(err, peripheral.ErrCargoNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &stackpb.FindCargoResponse{}
+	for _, l := range locs {
+		resp.Locations = append(resp.Locations, &stackpb.CargoLocation{
+			LocationId: l.LocationID,
+			Position:   int32(l.Position),
+			Cargo: &stackpb.Cargo{
+				Id:         l.Cargo.ID,
+				Metadata:   l.Cargo.Metadata,
+				CustomId:   l.Cargo.CustomID,
+				Status:     l.Cargo.Status,
+				MetadataId: l.Cargo.MetadataID,
+			},
+		})
+	}
+	return resp, nil
+}
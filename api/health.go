@@ -0,0 +1,89 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CheckFunc 回傳 nil 代表該相依服務可用
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	fn   CheckFunc
+}
+
+// Health 提供 /healthz 與 /readyz
+type Health struct {
+	timeout time.Duration
+
+	mu     sync.RWMutex
+	checks []check
+}
+
+func NewHealth(timeout time.Duration) *Health {
+	return &Health{timeout: timeout}
+}
+
+// AddCheck 新增 readiness 檢查，全部通過 /readyz 才回 200
+func (h *Health) AddCheck(name string, fn CheckFunc) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks = append(h.checks, check{name: name, fn: fn})
+}
+
+func (h *Health) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /healthz", h.healthz)
+	mux.HandleFunc("GET /readyz", h.readyz)
+}
+
+type readyBody struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// healthz 只代表程序還活著
+func (h *Health) healthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (h *Health) readyz(w http.ResponseWriter, r *http.Request) {
+	h.mu.RLock()
+	checks := h.checks
+	h.mu.RUnlock()
+
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	body := readyBody{Status: "ok", Checks: make(map[string]string, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+
+			result := "ok"
+			if err := c.fn(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			body.Checks[c.name] = result
+			if result != "ok" {
+				body.Status = "unavailable"
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	if body.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeJSON(w, status, body)
+}
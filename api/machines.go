@@ -0,0 +1,229 @@
+package api
+
+import (
+	"errors"
+	"kenmec/peripheral/jimmy/peripheral"
+	"kenmec/peripheral/jimmy/wcs"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// MachineHandler 提供機械手臂、疊棧機與旋轉台的指令 API
+type MachineHandler struct {
+	machines *peripheral.MachineManager
+	stations *wcs.Mock // nil 表示不模擬站點行為
+}
+
+func NewMachineHandler(machines *peripheral.MachineManager) *MachineHandler {
+	return &MachineHandler{machines: machines}
+}
+
+// SetStations 讓機台指令經過 mock WCS，機台位置有設定 mock_wcs_station 時套用站點行為
+func (h *MachineHandler) SetStations(m *wcs.Mock) {
+	h.stations = m
+}
+
+func (h *MachineHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /machines", h.list)
+	mux.HandleFunc("GET /machines/{locationId}", h.get)
+	mux.HandleFunc("POST /machines/{locationId}/pick", h.command(h.pick))
+	mux.HandleFunc("POST /machines/{locationId}/place", h.command(h.place))
+	mux.HandleFunc("POST /machines/{locationId}/release", h.command(h.release))
+	mux.HandleFunc("POST /machines/{locationId}/rotate", h.command(h.rotate))
+	mux.HandleFunc("POST /machines/{locationId}/reset", h.command(h.reset))
+}
+
+func (h *MachineHandler) command(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runCommand(h.stations, w, r, next)
+	}
+}
+
+type armView struct {
+	CycleTimeMs int64      `json:"cycleTimeMs"`
+	From        string     `json:"from,omitempty"`
+	To          string     `json:"to,omitempty"`
+	Holding     *cargoView `json:"holding,omitempty"`
+	Cycles      int        `json:"cycles"`
+}
+
+type palletizerView struct {
+	PatternCount int         `json:"patternCount"`
+	LayerSize    int         `json:"layerSize"`
+	Layers       int         `json:"layers"`
+	Cargo        []cargoView `json:"cargo"`
+	Pallets      int         `json:"pallets"`
+}
+
+type rotateTableView struct {
+	Angle  float64 `json:"angle"`
+	Target float64 `json:"target"`
+	Home   float64 `json:"home"`
+	Speed  float64 `json:"speed"`
+}
+
+type machineView struct {
+	LocationID string     `json:"locationId"`
+	Name       string     `json:"name"`
+	Type       string     `json:"type"`
+	State      string     `json:"state"`
+	Error      string     `json:"error,omitempty"`
+	UpdatedAt  *time.Time `json:"updatedAt,omitempty"`
+
+	Arm         *armView         `json:"arm,omitempty"`
+	Palletizer  *palletizerView  `json:"palletizer,omitempty"`
+	RotateTable *rotateTableView `json:"rotateTable,omitempty"`
+}
+
+func toMachineView(mc peripheral.Machine) machineView {
+	v := machineView{
+		LocationID: mc.LocationID,
+		Name:       mc.Name,
+		Type:       string(mc.Type),
+		State:      string(mc.State),
+		Error:      mc.Error,
+	}
+	if !mc.UpdatedAt.IsZero() {
+		v.UpdatedAt = &mc.UpdatedAt
+	}
+	if a := mc.Arm; a != nil {
+		v.Arm = &armView{
+			CycleTimeMs: a.CycleTime.Milliseconds(),
+			From:        a.From,
+			To:          a.To,
+			Cycles:      a.Cycles,
+		}
+		if a.Holding != nil {
+			c := toCargoView(*a.Holding)
+			v.Arm.Holding = &c
+		}
+	}
+	if p := mc.Palletizer; p != nil {
+		v.Palletizer = &palletizerView{
+			PatternCount: p.PatternCount,
+			LayerSize:    p.LayerSize,
+			Layers:       p.Layers(),
+			Cargo:        toCargoViews(p.Cargo),
+			Pallets:      p.Pallets,
+		}
+	}
+	if t := mc.RotateTable; t != nil {
+		v.RotateTable = &rotateTableView{Angle: t.Angle, Target: t.Target, Home: t.Home, Speed: t.Speed}
+	}
+	return v
+}
+
+func toCargoViews(cargo []peripheral.CargoData) []cargoView {
+	out := make([]cargoView, 0, len(cargo))
+	for _, c := range cargo {
+		out = append(out, toCargoView(c))
+	}
+	return out
+}
+
+func (h *MachineHandler) list(w http.ResponseWriter, r *http.Request) {
+	snapshot := h.machines.Snapshot()
+
+	out := make([]machineView, 0, len(snapshot))
+	for _, mc := range snapshot {
+		out = append(out, toMachineView(mc))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationID < out[j].LocationID })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *MachineHandler) get(w http.ResponseWriter, r *http.Request) {
+	h.reply(w, http.StatusOK)(h.machines.Machine(r.PathValue("locationId")))
+}
+
+type pickRequest struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// pick 立刻回傳 202，取放在背景完成
+func (h *MachineHandler) pick(w http.ResponseWriter, r *http.Request) {
+	var req pickRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.From == "" || req.To == "" {
+		writeError(w, http.StatusBadRequest, "from and to are required")
+		return
+	}
+	h.reply(w, http.StatusAccepted)(h.machines.Pick(actorContext(r), r.PathValue("locationId"), req.From, req.To))
+}
+
+func (h *MachineHandler) place(w http.ResponseWriter, r *http.Request) {
+	var req cargoView
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		writeError(w, http.StatusBadRequest, "cargo id is required")
+		return
+	}
+	h.reply(w, http.StatusOK)(h.machines.Place(actorContext(r), r.PathValue("locationId"), peripheral.CargoData{
+		ID:         req.ID,
+		Metadata:   req.Metadata,
+		MetadataID: req.MetadataID,
+		CustomID:   req.CustomID,
+		Status:     req.Status,
+	}))
+}
+
+// release 回傳放行的整板貨物
+func (h *MachineHandler) release(w http.ResponseWriter, r *http.Request) {
+	pallet, err := h.machines.Release(actorContext(r), r.PathValue("locationId"))
+	if err != nil {
+		writeMachineError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCargoViews(pallet))
+}
+
+type rotateRequest struct {
+	// Angle 是目標角度 (度)，沒帶時轉回 Direction.yaw
+	Angle *float64 `json:"angle"`
+}
+
+func (h *MachineHandler) rotate(w http.ResponseWriter, r *http.Request) {
+	var req rotateRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+	h.reply(w, http.StatusAccepted)(h.machines.Rotate(actorContext(r), r.PathValue("locationId"), req.Angle))
+}
+
+func (h *MachineHandler) reset(w http.ResponseWriter, r *http.Request) {
+	h.reply(w, http.StatusOK)(h.machines.Reset(actorContext(r), r.PathValue("locationId")))
+}
+
+func (h *MachineHandler) reply(w http.ResponseWriter, status int) func(peripheral.Machine, error) {
+	return func(mc peripheral.Machine, err error) {
+		if err != nil {
+			writeMachineError(w, err)
+			return
+		}
+		writeJSON(w, status, toMachineView(mc))
+	}
+}
+
+// writeMachineError 轉換機台的錯誤，其餘 (例如 Reset 放回堆疊失敗) 交給 writeStackError
+func writeMachineError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, peripheral.ErrMachineNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, peripheral.ErrWrongMachineType):
+		writeError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, peripheral.ErrMachineBusy),
+		errors.Is(err, peripheral.ErrMachineFault),
+		errors.Is(err, peripheral.ErrPalletFull),
+		errors.Is(err, peripheral.ErrPalletEmpty):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeStackError(w, err)
+	}
+}
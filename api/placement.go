@@ -0,0 +1,126 @@
+package api
+
+import (
+	"kenmec/peripheral/jimmy/peripheral"
+	"net/http"
+)
+
+type placementView struct {
+	GroupID         string  `json:"groupId,omitempty"`
+	LoadPriority    int     `json:"loadPriority"`
+	OffloadPriority int     `json:"offloadPriority"`
+	Priority        int     `json:"priority"`
+	X               float64 `json:"x"`
+	Y               float64 `json:"y"`
+}
+
+func toPlacementView(p peripheral.Placement) placementView {
+	return placementView{
+		GroupID:         p.GroupID,
+		LoadPriority:    p.LoadPriority,
+		OffloadPriority: p.OffloadPriority,
+		Priority:        p.Priority,
+		X:               p.X,
+		Y:               p.Y,
+	}
+}
+
+type placementRequest struct {
+	GroupID  string                 `json:"groupId"`
+	Op       peripheral.PlacementOp `json:"op"`
+	Strategy string                 `json:"strategy"`
+	Cargo    *cargoView             `json:"cargo"`
+	X        float64                `json:"x"`
+	Y        float64                `json:"y"`
+	Reserve  bool                   `json:"reserve"`
+	Booker   string                 `json:"booker"`
+}
+
+type placementResult struct {
+	Strategy string    `json:"strategy"`
+	Reserved bool      `json:"reserved"`
+	Stack    stackView `json:"stack"`
+}
+
+// selectStack 依策略挑出群組裡要放貨或取貨的堆疊，reserve 時同時預約
+func (h *StackHandler) selectStack(w http.ResponseWriter, r *http.Request) {
+	var req placementRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.GroupID == "" {
+		writeError(w, http.StatusBadRequest, "groupId is required")
+		return
+	}
+	if req.Op != peripheral.OpLoad && req.Op != peripheral.OpOffload {
+		writeError(w, http.StatusBadRequest, `op must be "load" or "offload"`)
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = peripheral.StrategyPriority
+	}
+
+	preq := peripheral.PlacementRequest{
+		GroupID:  req.GroupID,
+		Op:       req.Op,
+		Strategy: req.Strategy,
+		X:        req.X,
+		Y:        req.Y,
+		Reserve:  req.Reserve,
+		Booker:   req.Booker,
+	}
+	if req.Cargo != nil {
+		preq.Cargo = peripheral.CargoData{ID: req.Cargo.ID}
+	}
+
+	chosen, err := h.ysm.SelectStack(actorContext(r), preq)
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, placementResult{
+		Strategy: req.Strategy,
+		Reserved: req.Reserve,
+		Stack:    toStackView(chosen.LocationID, chosen.Stack),
+	})
+}
+
+func (h *StackHandler) strategies(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.ysm.Strategies())
+}
+
+type reservationRequest struct {
+	Booker string `json:"booker"`
+}
+
+func (h *StackHandler) reserve(w http.ResponseWriter, r *http.Request) {
+	locID := r.PathValue("locationId")
+
+	// body 可以不帶，預約者用 X-Actor
+	var req reservationRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := h.ysm.Reserve(actorContext(r), locID, req.Booker); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	s, _ := h.ysm.Stack(locID)
+	writeJSON(w, http.StatusOK, toStackView(locID, s))
+}
+
+// release 取消預約，?booker= 有帶時必須是原本的預約者
+func (h *StackHandler) release(w http.ResponseWriter, r *http.Request) {
+	locID := r.PathValue("locationId")
+
+	if err := h.ysm.Release(actorContext(r), locID, r.URL.Query().Get("booker")); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	s, _ := h.ysm.Stack(locID)
+	writeJSON(w, http.StatusOK, toStackView(locID, s))
+}
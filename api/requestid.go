@@ -0,0 +1,77 @@
+package api
+
+import (
+	"crypto/rand"
+	"kenmec/peripheral/jimmy/infra"
+	"net/http"
+	"time"
+)
+
+// RequestIDHeader 帶 request ID，沒帶或格式不對時由服務產生，回應一律附上
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLen 是沿用呼叫端 request ID 的最大長度，太長的改為自己產生
+const maxRequestIDLen = 128
+
+// WithRequestID 讓每個 request 帶著 request ID：寫入回應 header 與 context，
+// 並把附上 infra.FieldRequestID 的 logger 放進 context (infra.LoggerFrom 取得)，結束時記一筆 debug log
+func WithRequestID(next http.Handler, logger infra.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = rand.Text()
+		}
+		w.Header().Set(RequestIDHeader, id)
+
+		reqLogger := logger.With(infra.FieldRequestID, id)
+		ctx := infra.WithLogger(infra.WithRequestID(r.Context(), id), reqLogger)
+
+		started := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r.WithContext(ctx))
+
+		reqLogger.Debug("%s %s -> %d in %v", r.Method, r.URL.Path, sw.status, time.Since(started))
+	})
+}
+
+// validRequestID 只接受可見的 ASCII 字元，避免 header 內容直接寫進 log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// statusWriter 記下回應的狀態碼，/feed 需要的 Flush 轉給原本的 ResponseWriter
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status, w.wroteHeader = status, true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/peripheral"
+	"kenmec/peripheral/jimmy/wcs"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// ShelfHandler 提供多層貨架的 REST API，每一層用 level 指定
+type ShelfHandler struct {
+	shelves  *peripheral.ShelfManager
+	stations *wcs.Mock // nil 表示不模擬站點行為
+}
+
+func NewShelfHandler(shelves *peripheral.ShelfManager) *ShelfHandler {
+	return &ShelfHandler{shelves: shelves}
+}
+
+// SetStations 讓貨架指令經過 mock WCS，套用站點的延遲、停用與注入的故障
+func (h *ShelfHandler) SetStations(m *wcs.Mock) {
+	h.stations = m
+}
+
+func (h *ShelfHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /shelves", h.list)
+	mux.HandleFunc("GET /shelves/{locationId}", h.get)
+	mux.HandleFunc("POST /shelves/{locationId}/levels/{level}/cargo", h.command(h.putCargo))
+	mux.HandleFunc("DELETE /shelves/{locationId}/levels/{level}/cargo", h.command(h.takeCargo))
+	mux.HandleFunc("PUT /shelves/{locationId}/levels/{level}/reservation", h.command(h.reserve))
+	mux.HandleFunc("DELETE /shelves/{locationId}/levels/{level}/reservation", h.command(h.release))
+}
+
+func (h *ShelfHandler) command(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runCommand(h.stations, w, r, next)
+	}
+}
+
+type shelfLevelView struct {
+	ConfigID     string      `json:"configId"`
+	PeripheralID string      `json:"peripheralId"`
+	Name         string      `json:"name"`
+	Description  string      `json:"description"`
+	Level        int         `json:"level"`
+	Height       int         `json:"height"`
+	Disable      bool        `json:"disable"`
+	Booker       string      `json:"booker"`
+	CargoLimit   int         `json:"cargoLimit"`
+	Cargo        []cargoView `json:"cargo"`
+}
+
+type shelfView struct {
+	ShelfID    string           `json:"shelfId"`
+	LocationID string           `json:"locationId"`
+	Category   string           `json:"category,omitempty"`
+	Style      string           `json:"style,omitempty"`
+	Levels     []shelfLevelView `json:"levels"`
+}
+
+func toShelfView(s peripheral.Shelf) shelfView {
+	v := shelfView{
+		ShelfID:    s.ShelfID,
+		LocationID: s.LocationID,
+		Category:   s.Category,
+		Style:      s.Style,
+		Levels:     make([]shelfLevelView, 0, len(s.Levels)),
+	}
+	for _, l := range s.Levels {
+		v.Levels = append(v.Levels, shelfLevelView{
+			ConfigID:     l.ConfigID,
+			PeripheralID: l.PeripheralID,
+			Name:         l.Name,
+			Description:  l.Description,
+			Level:        l.Level,
+			Height:       l.Height,
+			Disable:      l.Disable,
+			Booker:       l.Booker,
+			CargoLimit:   l.CargoLimit,
+			Cargo:        toCargoViews(l.Cargo),
+		})
+	}
+	return v
+}
+
+func (h *ShelfHandler) list(w http.ResponseWriter, r *http.Request) {
+	snapshot := h.shelves.Snapshot()
+
+	out := make([]shelfView, 0, len(snapshot))
+	for _, s := range snapshot {
+		out = append(out, toShelfView(s))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationID < out[j].LocationID })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *ShelfHandler) get(w http.ResponseWriter, r *http.Request) {
+	h.reply(w, r, http.StatusOK)
+}
+
+// reply 回傳目前的貨架
+func (h *ShelfHandler) reply(w http.ResponseWriter, r *http.Request, status int) {
+	s, err := h.shelves.Shelf(r.PathValue("locationId"))
+	if err != nil {
+		writeShelfError(w, err)
+		return
+	}
+	writeJSON(w, status, toShelfView(s))
+}
+
+// pathLevel 讀出 path 的 level，不是整數時回 400
+func pathLevel(w http.ResponseWriter, r *http.Request) (int, bool) {
+	level, err := strconv.Atoi(r.PathValue("level"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "level must be an integer")
+		return 0, false
+	}
+	return level, true
+}
+
+func (h *ShelfHandler) putCargo(w http.ResponseWriter, r *http.Request) {
+	level, ok := pathLevel(w, r)
+	if !ok {
+		return
+	}
+
+	var req cargoView
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		writeError(w, http.StatusBadRequest, "cargo id is required")
+		return
+	}
+	if len(req.Metadata) > 0 && !json.Valid(req.Metadata) {
+		writeError(w, http.StatusBadRequest, "metadata must be valid JSON")
+		return
+	}
+
+	if err := h.shelves.PutCargo(actorContext(r), r.PathValue("locationId"), level, peripheral.CargoData{
+		ID:         req.ID,
+		Metadata:   req.Metadata,
+		MetadataID: req.MetadataID,
+		CustomID:   req.CustomID,
+		Status:     req.Status,
+	}); err != nil {
+		writeShelfError(w, err)
+		return
+	}
+	h.reply(w, r, http.StatusCreated)
+}
+
+// takeCargo 取走 ?cargoId= 指定的貨物，沒帶時取最後放上的
+func (h *ShelfHandler) takeCargo(w http.ResponseWriter, r *http.Request) {
+	level, ok := pathLevel(w, r)
+	if !ok {
+		return
+	}
+
+	c, err := h.shelves.TakeCargo(actorContext(r), r.PathValue("locationId"), level, r.URL.Query().Get("cargoId"))
+	if err != nil {
+		writeShelfError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCargoView(c))
+}
+
+func (h *ShelfHandler) reserve(w http.ResponseWriter, r *http.Request) {
+	level, ok := pathLevel(w, r)
+	if !ok {
+		return
+	}
+
+	// body 可以不帶，預約者用 X-Actor
+	var req reservationRequest
+	if r.ContentLength != 0 && !decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := h.shelves.Reserve(actorContext(r), r.PathValue("locationId"), level, req.Booker); err != nil {
+		writeShelfError(w, err)
+		return
+	}
+	h.reply(w, r, http.StatusOK)
+}
+
+// release 取消預約，?booker= 有帶時必須是原本的預約者
+func (h *ShelfHandler) release(w http.ResponseWriter, r *http.Request) {
+	level, ok := pathLevel(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.shelves.Release(actorContext(r), r.PathValue("locationId"), level, r.URL.Query().Get("booker")); err != nil {
+		writeShelfError(w, err)
+		return
+	}
+	h.reply(w, r, http.StatusOK)
+}
+
+func writeShelfError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, peripheral.ErrShelfNotFound),
+		errors.Is(err, peripheral.ErrShelfLevelNotFound),
+		errors.Is(err, peripheral.ErrCargoNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, cargo.ErrInvalidHolder):
+		status = http.StatusBadRequest
+	case errors.Is(err, peripheral.ErrCargoExists),
+		errors.Is(err, peripheral.ErrShelfReserved),
+		errors.Is(err, cargo.ErrIllegalTransition):
+		status = http.StatusConflict
+	case errors.Is(err, peripheral.ErrShelfLevelDisabled),
+		errors.Is(err, peripheral.ErrShelfLevelFull),
+		errors.Is(err, peripheral.ErrShelfLevelEmpty):
+		status = http.StatusUnprocessableEntity
+	}
+
+	writeError(w, status, err.Error())
+}
@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"kenmec/peripheral/jimmy/timeline"
+	"net/http"
+)
+
+// SimulationHandler 提供時間軸執行的統計
+type SimulationHandler struct {
+	results *timeline.Collector
+}
+
+func NewSimulationHandler(results *timeline.Collector) *SimulationHandler {
+	return &SimulationHandler{results: results}
+}
+
+func (h *SimulationHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /simulation/result", h.current)
+}
+
+// current 回傳執行中的統計，沒有在執行時回傳最後一次的結果
+func (h *SimulationHandler) current(w http.ResponseWriter, r *http.Request) {
+	res, err := h.results.Current()
+	if errors.Is(err, timeline.ErrNoResult) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"kenmec/peripheral/jimmy/peripheral"
+	"net/http"
+)
+
+// SnapshotHandler 提供快照的管理指令，用來保存現場狀態或重現問題
+type SnapshotHandler struct {
+	ysm   *peripheral.YFYStackManager
+	store *peripheral.SnapshotStore
+}
+
+func NewSnapshotHandler(ysm *peripheral.YFYStackManager, store *peripheral.SnapshotStore) *SnapshotHandler {
+	return &SnapshotHandler{ysm: ysm, store: store}
+}
+
+func (h *SnapshotHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /admin/snapshots", h.list)
+	mux.HandleFunc("GET /admin/snapshots/{name}", h.get)
+	mux.HandleFunc("POST /admin/snapshots/{name}", h.save)
+	mux.HandleFunc("POST /admin/snapshots/{name}/restore", h.restore)
+}
+
+type snapshotSummary struct {
+	Name          string `json:"name"`
+	ScriptID      string `json:"scriptId"`
+	Version       int    `json:"version"`
+	StackCount    int    `json:"stackCount"`
+	ConveyorCount int    `json:"conveyorCount"`
+	GroupCount    int    `json:"groupCount"`
+}
+
+func summarize(name string, snap *peripheral.StateSnapshot) snapshotSummary {
+	return snapshotSummary{
+		Name:          name,
+		ScriptID:      snap.ScriptID,
+		Version:       snap.Version,
+		StackCount:    len(snap.Stacks),
+		ConveyorCount: len(snap.Conveyors),
+		GroupCount:    len(snap.Groups),
+	}
+}
+
+func (h *SnapshotHandler) list(w http.ResponseWriter, r *http.Request) {
+	infos, err := h.store.List()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, infos)
+}
+
+func (h *SnapshotHandler) get(w http.ResponseWriter, r *http.Request) {
+	snap, err := h.store.Load(r.PathValue("name"))
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, snap)
+}
+
+func (h *SnapshotHandler) save(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	snap := h.ysm.TakeSnapshot()
+	if err := h.store.Save(name, snap); err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, summarize(name, snap))
+}
+
+func (h *SnapshotHandler) restore(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	snap, err := h.store.Load(name)
+	if err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+
+	if err := h.ysm.Restore(actorContext(r), snap); err != nil {
+		writeSnapshotError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summarize(name, snap))
+}
+
+func writeSnapshotError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, peripheral.ErrSnapshotNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, peripheral.ErrSnapshotName):
+		status = http.StatusBadRequest
+	case errors.Is(err, peripheral.ErrSnapshotVersion):
+		status = http.StatusUnprocessableEntity
+	case errors.Is(err, peripheral.ErrSnapshotScript):
+		status = http.StatusConflict
+	}
+
+	writeError(w, status, err.Error())
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"kenmec/peripheral/jimmy/wcs"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// StackHandler 提供堆疊的 REST API，所有操作都透過 YFYStackManager
+type StackHandler struct {
+	ysm      *peripheral.YFYStackManager
+	stations *wcs.Mock // nil 表示不模擬站點行為
+}
+
+func NewStackHandler(ysm *peripheral.YFYStackManager) *StackHandler {
+	return &StackHandler{ysm: ysm}
+}
+
+func (h *StackHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /stacks", h.list)
+	mux.HandleFunc("GET /stacks/{locationId}", h.get)
+	// 新增、刪除與修改設定是管理操作，不經過站點；只有貨物與預約指令經過
+	mux.HandleFunc("POST /stacks/{locationId}", h.add)
+	mux.HandleFunc("DELETE /stacks/{locationId}", h.delete)
+	mux.HandleFunc("PATCH /stacks/{locationId}", h.patch)
+	mux.HandleFunc("POST /stacks/{locationId}/cargo", h.command(h.pushCargo))
+	mux.HandleFunc("DELETE /stacks/{locationId}/cargo", h.command(h.popCargo))
+	mux.HandleFunc("POST /stacks/{locationId}/transfer", h.command(h.transfer))
+	mux.HandleFunc("PUT /stacks/{locationId}/reservation", h.command(h.reserve))
+	mux.HandleFunc("DELETE /stacks/{locationId}/reservation", h.command(h.release))
+	mux.HandleFunc("GET /cargo", h.findCargo)
+	mux.HandleFunc("POST /placement", h.selectStack)
+	mux.HandleFunc("GET /placement/strategies", h.strategies)
+	mux.HandleFunc("GET /groups", h.listGroups)
+	mux.HandleFunc("GET /groups/{groupId}", h.getGroup)
+	mux.HandleFunc("PATCH /groups/{groupId}", h.patchGroup)
+	mux.HandleFunc("DELETE /groups/{groupId}/cargo", h.clearGroup)
+}
+
+type cargoView struct {
+	ID         string          `json:"id"`
+	Metadata   json.RawMessage `json:"metadata,omitempty"`
+	MetadataID string          `json:"metadataId,omitempty"`
+	CustomID   string          `json:"customId,omitempty"`
+	Status     string          `json:"status,omitempty"`
+	PlacedAt   *time.Time      `json:"placedAt,omitempty"`
+	// Holder 只輸出，例如 robot:amr-01
+	Holder string `json:"holder,omitempty"`
+}
+
+type stackView struct {
+	LocationID  string      `json:"locationId"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Disable     bool        `json:"disable"`
+	Booker      string      `json:"booker"`
+	StackCount  int         `json:"stackCount"`
+	Heights     []int       `json:"heights"`
+	Cargo       []cargoView `json:"cargo"`
+
+	Placement placementView `json:"placement"`
+}
+
+func toStackView(locID string, s peripheral.YFYStack) stackView {
+	v := stackView{
+		LocationID:  locID,
+		Name:        s.Name,
+		Description: s.Description,
+		Disable:     s.Disable,
+		Booker:      s.Booker,
+		StackCount:  s.StackCount,
+		Heights:     s.Heights,
+		Cargo:       make([]cargoView, 0, len(s.Cargo)),
+		Placement:   toPlacementView(s.Placement),
+	}
+	if v.Heights == nil {
+		v.Heights = []int{}
+	}
+	for _, c := range s.Cargo {
+		v.Cargo = append(v.Cargo, toCargoView(c))
+	}
+	return v
+}
+
+func toCargoView(c peripheral.CargoData) cargoView {
+	v := cargoView{
+		ID:         c.ID,
+		Metadata:   c.Metadata,
+		MetadataID: c.MetadataID,
+		CustomID:   c.CustomID,
+		Status:     c.Status,
+	}
+	if c.Holder.Kind != cargo.HolderNone {
+		v.Holder = c.Holder.String()
+	}
+	if !c.PlacedAt.IsZero() {
+		v.PlacedAt = &c.PlacedAt
+	}
+	return v
+}
+
+func (h *StackHandler) list(w http.ResponseWriter, r *http.Request) {
+	snapshot := h.ysm.Snapshot()
+
+	out := make([]stackView, 0, len(snapshot))
+	for locID, s := range snapshot {
+		out = append(out, toStackView(locID, s))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationID < out[j].LocationID })
+
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *StackHandler) get(w http.ResponseWriter, r *http.Request) {
+	locID := r.PathValue("locationId")
+
+	s, ok := h.ysm.Stack(locID)
+	if !ok {
+		writeError(w, http.StatusNotFound, peripheral.ErrStackNotFound.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, toStackView(locID, s))
+}
+
+func (h *StackHandler) add(w http.ResponseWriter, r *http.Request) {
+	locID := r.PathValue("locationId")
+
+	if err := h.ysm.AddStack(actorContext(r), locID); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	s, _ := h.ysm.Stack(locID)
+	writeJSON(w, http.StatusCreated, toStackView(locID, s))
+}
+
+func (h *StackHandler) delete(w http.ResponseWriter, r *http.Request) {
+	if err := h.ysm.DeleteStack(actorContext(r), r.PathValue("locationId")); err != nil {
+		writeStackError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// patchRequest 只更新有帶的欄位
+type patchRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Disable     *bool   `json:"disable"`
+}
+
+func (h *StackHandler) patch(w http.ResponseWriter, r *http.Request) {
+	locID := r.PathValue("locationId")
+
+	var req patchRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Name == nil && req.Description == nil && req.Disable == nil {
+		writeError(w, http.StatusBadRequest, "nothing to update: set name, description or disable")
+		return
+	}
+	if req.Name != nil && *req.Name == "" {
+		writeError(w, http.StatusBadRequest, "name must not be empty")
+		return
+	}
+
+	s, ok := h.ysm.Stack(locID)
+	if !ok {
+		writeError(w, http.StatusNotFound, peripheral.ErrStackNotFound.Error())
+		return
+	}
+
+	name, desc, disable := s.Name, s.Description, s.Disable
+	if req.Name != nil {
+		name = *req.Name
+	}
+	if req.Description != nil {
+		desc = *req.Description
+	}
+	if req.Disable != nil {
+		disable = *req.Disable
+	}
+
+	if err := h.ysm.UpdatestackConfig(actorContext(r), locID, name, desc, disable); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	s, _ = h.ysm.Stack(locID)
+	writeJSON(w, http.StatusOK, toStackView(locID, s))
+}
+
+func (h *StackHandler) pushCargo(w http.ResponseWriter, r *http.Request) {
+	locID := r.PathValue("locationId")
+
+	var req cargoView
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.ID == "" {
+		writeError(w, http.StatusBadRequest, "cargo id is required")
+		return
+	}
+	if len(req.Metadata) > 0 && !json.Valid(req.Metadata) {
+		writeError(w, http.StatusBadRequest, "metadata must be valid JSON")
+		return
+	}
+
+	if err := h.ysm.PushCargo(actorContext(r), locID, peripheral.CargoData{
+		ID:         req.ID,
+		Metadata:   req.Metadata,
+		MetadataID: req.MetadataID,
+		CustomID:   req.CustomID,
+		Status:     req.Status,
+	}); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	s, _ := h.ysm.Stack(locID)
+	writeJSON(w, http.StatusCreated, toStackView(locID, s))
+}
+
+func (h *StackHandler) popCargo(w http.ResponseWriter, r *http.Request) {
+	c, err := h.ysm.PopCargo(actorContext(r), r.PathValue("locationId"))
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, toCargoView(c))
+}
+
+// ActorHeader 帶操作者名稱，會記錄到 journal，沒帶就記成 "api"
+const ActorHeader = "X-Actor"
+
+func actorContext(r *http.Request) context.Context {
+	actor := r.Header.Get(ActorHeader)
+	if actor == "" {
+		actor = "api"
+	}
+	return infra.WithActor(r.Context(), actor)
+}
+
+type transferRequest struct {
+	CargoID string `json:"cargoId"`
+	To      string `json:"to"`
+}
+
+type transferView struct {
+	From stackView `json:"from"`
+	To   stackView `json:"to"`
+}
+
+func (h *StackHandler) transfer(w http.ResponseWriter, r *http.Request) {
+	from := r.PathValue("locationId")
+
+	var req transferRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.CargoID == "" || req.To == "" {
+		writeError(w, http.StatusBadRequest, "cargoId and to are required")
+		return
+	}
+
+	if err := h.ysm.Transfer(actorContext(r), from, req.To, req.CargoID); err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	src, _ := h.ysm.Stack(from)
+	dst, _ := h.ysm.Stack(req.To)
+	writeJSON(w, http.StatusOK, transferView{From: toStackView(from, src), To: toStackView(req.To, dst)})
+}
+
+type cargoLocationView struct {
+	LocationID string    `json:"locationId"`
+	Position   int       `json:"position"`
+	Cargo      cargoView `json:"cargo"`
+}
+
+// findCargo 以 ?id=、?customId= 或 ?metadata= 其中一個查詢貨物位置
+func (h *StackHandler) findCargo(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	var lookups []peripheral.CargoKey
+	for _, key := range []peripheral.CargoKey{peripheral.ByCargoID, peripheral.ByCustomID, peripheral.ByMetadata} {
+		if q.Has(string(key)) {
+			lookups = append(lookups, key)
+		}
+	}
+	if len(lookups) != 1 {
+		writeError(w, http.StatusBadRequest, "exactly one of id, customId or metadata is required")
+		return
+	}
+
+	key := lookups[0]
+	value := q.Get(string(key))
+	if value == "" {
+		writeError(w, http.StatusBadRequest, string(key)+" must not be empty")
+		return
+	}
+
+	locs, err := h.ysm.FindCargo(key, value)
+	if err != nil {
+		writeStackError(w, err)
+		return
+	}
+
+	out := make([]cargoLocationView, 0, len(locs))
+	for _, l := range locs {
+		out = append(out, cargoLocationView{
+			LocationID: l.LocationID,
+			Position:   l.Position,
+			Cargo:      toCargoView(l.Cargo),
+		})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20))
+	dec.DisallowUnknownFields()
+
+	if err := dec.Decode(v); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+// writeStackError 把 peripheral 的錯誤轉成對應的 HTTP 狀態碼
+func writeStackError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+
+	switch {
+	case errors.Is(err, peripheral.ErrStackNotFound),
+		errors.Is(err, peripheral.ErrCargoNotFound),
+		errors.Is(err, peripheral.ErrNoStackAvailable),
+		errors.Is(err, peripheral.ErrGroupNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, peripheral.ErrUnknownStrategy),
+		errors.Is(err, cargo.ErrInvalidHolder):
+		status = http.StatusBadRequest
+	case errors.Is(err, peripheral.ErrStackExists),
+		errors.Is(err, peripheral.ErrCargoExists),
+		errors.Is(err, peripheral.ErrMetadataDuplicate),
+		errors.Is(err, peripheral.ErrStackReserved),
+		errors.Is(err, peripheral.ErrCargoMoved),
+		errors.Is(err, cargo.ErrIllegalTransition):
+		status = http.StatusConflict
+	case errors.Is(err, peripheral.ErrStackDisabled),
+		errors.Is(err, peripheral.ErrStackFull),
+		errors.Is(err, peripheral.ErrStackEmpty),
+		errors.Is(err, peripheral.ErrCargoNotOnTop),
+		errors.Is(err, peripheral.ErrSameStack),
+		errors.Is(err, peripheral.ErrMetadataInvalid),
+		errors.Is(err, peripheral.ErrMetadataFormatNotFound):
+		status = http.StatusUnprocessableEntity
+	}
+
+	writeError(w, status, err.Error())
+}
@@ -0,0 +1,82 @@
+package api
+
+import (
+	"errors"
+	"kenmec/peripheral/jimmy/timeline"
+	"net/http"
+	"time"
+)
+
+// TimelineHandler 控制時間軸的執行
+type TimelineHandler struct {
+	engine *timeline.Engine
+}
+
+func NewTimelineHandler(engine *timeline.Engine) *TimelineHandler {
+	return &TimelineHandler{engine: engine}
+}
+
+func (h *TimelineHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /timeline", h.status)
+	mux.HandleFunc("POST /timeline/start", h.start)
+	mux.HandleFunc("POST /timeline/pause", h.control(h.engine.Pause))
+	mux.HandleFunc("POST /timeline/resume", h.control(h.engine.Resume))
+	mux.HandleFunc("POST /timeline/stop", h.control(h.engine.Stop))
+}
+
+type timelineView struct {
+	State     timeline.State `json:"state"`
+	ScriptID  string         `json:"scriptId"`
+	StartedAt *time.Time     `json:"startedAt,omitempty"`
+	ElapsedMs int64          `json:"elapsedMs"`
+	Entries   int            `json:"entries"`
+	Pending   int            `json:"pending"`
+	Fired     int            `json:"fired"`
+}
+
+func toTimelineView(st timeline.Status) timelineView {
+	v := timelineView{
+		State:     st.State,
+		ScriptID:  st.ScriptID,
+		ElapsedMs: st.Elapsed.Milliseconds(),
+		Entries:   st.Entries,
+		Pending:   st.Pending,
+		Fired:     st.Fired,
+	}
+	if !st.StartedAt.IsZero() {
+		v.StartedAt = &st.StartedAt
+	}
+	return v
+}
+
+func (h *TimelineHandler) status(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, toTimelineView(h.engine.Status()))
+}
+
+func (h *TimelineHandler) start(w http.ResponseWriter, r *http.Request) {
+	if err := h.engine.Start(r.Context()); err != nil {
+		writeTimelineError(w, err)
+		return
+	}
+	h.status(w, r)
+}
+
+func (h *TimelineHandler) control(fn func() error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(); err != nil {
+			writeTimelineError(w, err)
+			return
+		}
+		h.status(w, r)
+	}
+}
+
+func writeTimelineError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, timeline.ErrAlreadyRunning) ||
+		errors.Is(err, timeline.ErrNotRunning) ||
+		errors.Is(err, timeline.ErrNotPaused) {
+		status = http.StatusConflict
+	}
+	writeError(w, status, err.Error())
+}
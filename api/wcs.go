@@ -0,0 +1,189 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"kenmec/peripheral/jimmy/wcs"
+	"net/http"
+	"time"
+)
+
+// SetStations 讓堆疊指令經過 mock WCS，套用站點的延遲、停用與注入的故障
+func (h *StackHandler) SetStations(m *wcs.Mock) {
+	h.stations = m
+}
+
+// command 以 path 的 locationId 對應的站點執行指令，transfer 只看來源站點
+func (h *StackHandler) command(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		runCommand(h.stations, w, r, next)
+	}
+}
+
+// runCommand 讓 next 經過 stations 的站點行為，stations 為 nil 時直接執行
+func runCommand(stations *wcs.Mock, w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if stations == nil {
+		next(w, r)
+		return
+	}
+
+	err := stations.Do(r.Context(), r.PathValue("locationId"), func(ctx context.Context) error {
+		next(w, r.WithContext(ctx))
+		return nil
+	})
+	if err != nil {
+		writeStationError(w, err)
+	}
+}
+
+// WCSHandler 管理 mock WCS 站點，並可注入故障
+type WCSHandler struct {
+	stations *wcs.Mock
+}
+
+func NewWCSHandler(stations *wcs.Mock) *WCSHandler {
+	return &WCSHandler{stations: stations}
+}
+
+func (h *WCSHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /wcs/stations", h.list)
+	mux.HandleFunc("GET /wcs/stations/{locationId}", h.get)
+	mux.HandleFunc("PATCH /wcs/stations/{locationId}", h.patch)
+	mux.HandleFunc("POST /wcs/stations/{locationId}/faults", h.inject)
+	mux.HandleFunc("DELETE /wcs/stations/{locationId}/faults", h.clear)
+}
+
+type faultView struct {
+	Kind    wcs.FaultKind `json:"kind"`
+	Code    string        `json:"code,omitempty"`
+	Message string        `json:"message,omitempty"`
+	Times   int           `json:"times"`
+}
+
+type stationView struct {
+	ID          string      `json:"id"`
+	LocationID  string      `json:"locationId"`
+	MachineType string      `json:"machineType"`
+	DelayMs     int64       `json:"delayMs"`
+	Enabled     bool        `json:"enabled"`
+	Stuck       int         `json:"stuck"`
+	Faults      []faultView `json:"faults"`
+}
+
+func toStationView(s wcs.Station) stationView {
+	v := stationView{
+		ID:          s.ID,
+		LocationID:  s.LocationID,
+		MachineType: string(s.MachineType),
+		DelayMs:     s.Delay.Milliseconds(),
+		Enabled:     s.Enabled,
+		Stuck:       s.Stuck,
+		Faults:      make([]faultView, 0, len(s.Faults)),
+	}
+	for _, f := range s.Faults {
+		v.Faults = append(v.Faults, faultView(f))
+	}
+	return v
+}
+
+func (h *WCSHandler) list(w http.ResponseWriter, r *http.Request) {
+	stations := h.stations.Stations()
+	out := make([]stationView, 0, len(stations))
+	for _, s := range stations {
+		out = append(out, toStationView(s))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *WCSHandler) get(w http.ResponseWriter, r *http.Request) {
+	h.reply(w)(h.stations.Station(r.PathValue("locationId")))
+}
+
+type patchStationRequest struct {
+	Enabled *bool  `json:"enabled"`
+	DelayMs *int64 `json:"delayMs"`
+}
+
+func (h *WCSHandler) patch(w http.ResponseWriter, r *http.Request) {
+	var req patchStationRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	var delay *time.Duration
+	if req.DelayMs != nil {
+		d := time.Duration(*req.DelayMs) * time.Millisecond
+		delay = &d
+	}
+	h.reply(w)(h.stations.Configure(r.PathValue("locationId"), req.Enabled, delay))
+}
+
+// inject 接受單一故障或故障陣列，依序排到佇列後面
+func (h *WCSHandler) inject(w http.ResponseWriter, r *http.Request) {
+	var raw json.RawMessage
+	if !decodeBody(w, r, &raw) {
+		return
+	}
+
+	var views []faultView
+	if err := decodeFaults(raw, &views); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON body: "+err.Error())
+		return
+	}
+
+	faults := make([]wcs.Fault, 0, len(views))
+	for _, v := range views {
+		faults = append(faults, wcs.Fault(v))
+	}
+	h.reply(w)(h.stations.Inject(r.PathValue("locationId"), faults...))
+}
+
+// decodeFaults 跟 decodeBody 一樣不接受未知欄位，body 可以是單一故障或陣列
+func decodeFaults(raw json.RawMessage, views *[]faultView) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+
+	if trimmed := bytes.TrimSpace(raw); len(trimmed) > 0 && trimmed[0] == '[' {
+		return dec.Decode(views)
+	}
+	var one faultView
+	if err := dec.Decode(&one); err != nil {
+		return err
+	}
+	*views = []faultView{one}
+	return nil
+}
+
+func (h *WCSHandler) clear(w http.ResponseWriter, r *http.Request) {
+	h.reply(w)(h.stations.ClearFaults(r.PathValue("locationId")))
+}
+
+func (h *WCSHandler) reply(w http.ResponseWriter) func(wcs.Station, error) {
+	return func(s wcs.Station, err error) {
+		if err != nil {
+			writeStationError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, toStationView(s))
+	}
+}
+
+func writeStationError(w http.ResponseWriter, err error) {
+	var stationErr *wcs.StationError
+	status := http.StatusInternalServerError
+	switch {
+	case errors.As(err, &stationErr):
+		status = http.StatusBadGateway
+	case errors.Is(err, wcs.ErrStationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, wcs.ErrInvalidFault):
+		status = http.StatusBadRequest
+	case errors.Is(err, wcs.ErrStationDisabled):
+		status = http.StatusServiceUnavailable
+	case errors.Is(err, wcs.ErrStationTimeout), errors.Is(err, context.DeadlineExceeded):
+		status = http.StatusGatewayTimeout
+	}
+	writeError(w, status, err.Error())
+}
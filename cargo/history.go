@@ -0,0 +1,117 @@
+package cargo
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// Recorder 把每一次狀態轉換寫回 cargo_info 的狀態與位置並留下 cargo_history
+type Recorder struct {
+	conn   *sql.DB
+	db     *db.Queries
+	logger infra.Logger
+}
+
+func NewRecorder(conn *sql.DB, logger infra.Logger) *Recorder {
+	return &Recorder{
+		conn:   conn,
+		db:     db.New(conn),
+		logger: logger.With(infra.FieldComponent, "cargo-lifecycle"),
+	}
+}
+
+// Record 在同一個交易裡更新 cargo_info 並寫入每個 Move 對應動作的 cargo_history，操作者取自 ctx。
+// 只存在記憶體的貨物 (例如模擬生成的) 在資料庫沒有資料，不寫紀錄；
+// 資料庫裡的狀態不是 Move.From 時整批不寫入並回傳 ErrIllegalTransition
+func (r *Recorder) Record(ctx context.Context, moves ...Move) error {
+	if len(moves) == 0 {
+		return nil
+	}
+	return r.inTx(ctx, moves, nil)
+}
+
+// RecordShelf 同 Record，並在同一個交易把貨架層 shelfConfigID 的 ShelfConfig.hasCargo 改成 hasCargo
+func (r *Recorder) RecordShelf(ctx context.Context, shelfConfigID string, hasCargo bool, moves ...Move) error {
+	return r.inTx(ctx, moves, func(q *db.Queries) error {
+		err := q.UpdateShelfHasCargo(ctx, db.UpdateShelfHasCargoParams{HasCargo: hasCargo, ID: shelfConfigID})
+		if err != nil {
+			return fmt.Errorf("update shelf %s: %w", shelfConfigID, err)
+		}
+		return nil
+	})
+}
+
+// inTx 在同一個交易寫入 moves，then 不是 nil 時接著執行
+func (r *Recorder) inTx(ctx context.Context, moves []Move, then func(q *db.Queries) error) error {
+	tx, err := r.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin cargo lifecycle: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := r.db.WithTx(tx)
+	for _, mv := range moves {
+		if err := r.record(ctx, q, mv); err != nil {
+			return err
+		}
+	}
+	if then != nil {
+		if err := then(q); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit cargo lifecycle: %w", err)
+	}
+	return nil
+}
+
+func (r *Recorder) record(ctx context.Context, q *db.Queries, mv Move) error {
+	res, err := q.UpdateCargoLifecycle(ctx, db.UpdateCargoLifecycleParams{
+		Status:        db.CargoInfoStatus(mv.To),
+		Owner:         mv.Holder.Owner(),
+		StackConfigID: sql.NullString{String: mv.StackConfigID, Valid: mv.StackConfigID != ""},
+		ShelfConfigID: sql.NullString{String: mv.ShelfConfigID, Valid: mv.ShelfConfigID != ""},
+		ID:            mv.CargoID,
+		FromStatus:    db.CargoInfoStatus(mv.From),
+	})
+	if err != nil {
+		return fmt.Errorf("update cargo %s: %w", mv.CargoID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update cargo %s: %w", mv.CargoID, err)
+	}
+	if n == 0 {
+		// 沒有更新到：可能是資料庫沒有這個貨物，也可能是狀態不一樣
+		info, err := q.GetCargoInfo(ctx, mv.CargoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			r.logger.Debug("cargo %s is not in cargo_info, %s kept in memory only", mv.CargoID, mv.Event)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("get cargo %s: %w", mv.CargoID, err)
+		}
+		return fmt.Errorf("%w: cargo %s is %s in cargo_info, cannot %s from %s",
+			ErrIllegalTransition, mv.CargoID, info.Status, mv.Event, mv.From)
+	}
+
+	err = q.InsertCargoHistory(ctx, db.InsertCargoHistoryParams{
+		ID:          rand.Text(),
+		CargoID:     mv.CargoID,
+		Action:      transitions[mv.Event].action,
+		Description: sql.NullString{String: mv.description(), Valid: true},
+		Actor:       sql.NullString{String: infra.ActorFrom(ctx), Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("insert cargo history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package cargo
+
+import (
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+)
+
+var (
+	ErrIllegalTransition = errors.New("illegal cargo transition")
+	ErrInvalidHolder     = errors.New("invalid cargo holder")
+	ErrUnknownEvent      = errors.New("unknown cargo event")
+)
+
+// Status 是 cargo_info.status，貨物生命週期的狀態
+type Status string
+
+const (
+	StatusPreSpawn   Status = "PRE_SPAWN"   // 還沒進入場域
+	StatusOnAMR      Status = "ON_AMR"      // 在 AMR (或機械手臂) 上搬運中
+	StatusAtLocation Status = "AT_LOCATION" // 放在堆疊、貨架、輸送帶或電梯上
+	StatusShift      Status = "SHIFT"       // 已經移出場域，不會再移動
+)
+
+// HolderKind 是目前拿著貨物的週邊種類
+type HolderKind string
+
+const (
+	HolderNone     HolderKind = ""
+	HolderStack    HolderKind = "stack"
+	HolderShelf    HolderKind = "shelf"
+	HolderConveyor HolderKind = "conveyor"
+	HolderElevator HolderKind = "elevator"
+	HolderMachine  HolderKind = "machine" // 疊棧機等機台，機械手臂搬運中算 robot
+	HolderRobot    HolderKind = "robot"
+)
+
+// Holder 是貨物目前在哪裡，ID 是位置的 locationId 或 AMR 的 id。
+// 移出場域的貨物沒有 holder
+type Holder struct {
+	Kind HolderKind `json:"kind"`
+	ID   string     `json:"id"`
+}
+
+func (h Holder) String() string {
+	if h.Kind == HolderNone {
+		return "none"
+	}
+	return string(h.Kind) + ":" + h.ID
+}
+
+// located 表示 holder 是固定的位置
+func (h Holder) located() bool {
+	switch h.Kind {
+	case HolderStack, HolderShelf, HolderConveyor, HolderElevator, HolderMachine:
+		return true
+	}
+	return false
+}
+
+// Owner 轉成 cargo_info.owner，堆疊、貨架與機台都算 STORAGE
+func (h Holder) Owner() db.CargoInfoOwner {
+	switch h.Kind {
+	case HolderConveyor:
+		return db.CargoInfoOwnerCONVEYOR
+	case HolderElevator:
+		return db.CargoInfoOwnerELEVATOR
+	case HolderRobot:
+		return db.CargoInfoOwnerAMR
+	case HolderNone:
+		return db.CargoInfoOwnerSHIFT
+	}
+	return db.CargoInfoOwnerSTORAGE
+}
+
+// Event 是讓貨物改變狀態的動作。load / offload 以位置的角度命名：
+// load 是 AMR 把貨放到位置上，offload 是 AMR 從位置取走貨物
+type Event string
+
+const (
+	EventSpawn    Event = "spawn"    // 在位置上生成
+	EventLoad     Event = "load"     // AMR 放到位置上
+	EventOffload  Event = "offload"  // AMR 從位置取走
+	EventTransfer Event = "transfer" // 位置之間直接移動
+	EventShift    Event = "shift"    // 從位置移出場域
+)
+
+type transition struct {
+	from   Status
+	to     Status
+	holder func(Holder) bool
+	action db.CargoHistoryAction
+}
+
+var transitions = map[Event]transition{
+	EventSpawn:    {StatusPreSpawn, StatusAtLocation, Holder.located, db.CargoHistoryActionCREATED},
+	EventLoad:     {StatusOnAMR, StatusAtLocation, Holder.located, db.CargoHistoryActionLOAD},
+	EventOffload:  {StatusAtLocation, StatusOnAMR, isRobot, db.CargoHistoryActionOFFLOAD},
+	EventTransfer: {StatusAtLocation, StatusAtLocation, Holder.located, db.CargoHistoryActionTRANSFER},
+	EventShift:    {StatusAtLocation, StatusShift, isNone, db.CargoHistoryActionSHIFTED},
+}
+
+func isRobot(h Holder) bool { return h.Kind == HolderRobot && h.ID != "" }
+func isNone(h Holder) bool  { return h.Kind == HolderNone }
+
+// Next 檢查貨物從 from 狀態做 ev 移到 to 是否合法，合法時回傳新的狀態。
+// 例如 PRE_SPAWN 的貨物不能 offload，SHIFT 的貨物不能再做任何動作
+func Next(from Status, ev Event, to Holder) (Status, error) {
+	t, ok := transitions[ev]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownEvent, ev)
+	}
+	if from != t.from {
+		return "", fmt.Errorf("%w: %s cargo cannot %s", ErrIllegalTransition, from, ev)
+	}
+	if !t.holder(to) {
+		return "", fmt.Errorf("%w: %s cannot %s to %s", ErrInvalidHolder, from, ev, to)
+	}
+	return t.to, nil
+}
+
+// Arrival 回傳貨物放到位置上時的動作：還沒生成 (或沒有狀態) 的貨物是 spawn，其餘是 load
+func Arrival(s Status) Event {
+	if s == "" || s == StatusPreSpawn {
+		return EventSpawn
+	}
+	return EventLoad
+}
+
+// Move 是一次合法的狀態轉換，由 Next 檢查過後交給 Recorder 寫入
+type Move struct {
+	CargoID string
+	From    Status
+	To      Status
+	Event   Event
+	Holder  Holder
+	// StackConfigID、ShelfConfigID 是貨物放上的堆疊或貨架層 (cargo_info.stack_config_id、shelfConfigId)，
+	// 不在堆疊 (貨架) 上時為空，寫入時清除
+	StackConfigID string
+	ShelfConfigID string
+}
+
+// Plan 檢查並組出 Move，呼叫端在修改記憶體前先呼叫，不合法時不做任何事
+func Plan(cargoID string, from Status, ev Event, to Holder) (Move, error) {
+	next, err := Next(from, ev, to)
+	if err != nil {
+		return Move{}, fmt.Errorf("cargo %s: %w", cargoID, err)
+	}
+	return Move{CargoID: cargoID, From: from, To: next, Event: ev, Holder: to}, nil
+}
+
+func (mv Move) description() string {
+	return fmt.Sprintf("%s -> %s (%s)", mv.From, mv.To, mv.Holder)
+}
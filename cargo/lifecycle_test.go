@@ -0,0 +1,99 @@
+package cargo
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNext(t *testing.T) {
+	stack := Holder{Kind: HolderStack, ID: "L1"}
+	robot := Holder{Kind: HolderRobot, ID: "amr-1"}
+
+	tests := []struct {
+		name    string
+		from    Status
+		ev      Event
+		to      Holder
+		want    Status
+		wantErr error
+	}{
+		{name: "spawn on a stack", from: StatusPreSpawn, ev: EventSpawn, to: stack, want: StatusAtLocation},
+		{name: "spawn on a machine", from: StatusPreSpawn, ev: EventSpawn, to: Holder{Kind: HolderMachine, ID: "M1"}, want: StatusAtLocation},
+		{name: "load from an amr", from: StatusOnAMR, ev: EventLoad, to: Holder{Kind: HolderConveyor, ID: "C1"}, want: StatusAtLocation},
+		{name: "offload to an amr", from: StatusAtLocation, ev: EventOffload, to: robot, want: StatusOnAMR},
+		{name: "transfer between locations", from: StatusAtLocation, ev: EventTransfer, to: Holder{Kind: HolderShelf, ID: "S1"}, want: StatusAtLocation},
+		{name: "shift out of the site", from: StatusAtLocation, ev: EventShift, to: Holder{}, want: StatusShift},
+
+		{name: "offload before spawn", from: StatusPreSpawn, ev: EventOffload, to: robot, wantErr: ErrIllegalTransition},
+		{name: "transfer before spawn", from: StatusPreSpawn, ev: EventTransfer, to: stack, wantErr: ErrIllegalTransition},
+		{name: "spawn twice", from: StatusAtLocation, ev: EventSpawn, to: stack, wantErr: ErrIllegalTransition},
+		{name: "load onto a location twice", from: StatusAtLocation, ev: EventLoad, to: stack, wantErr: ErrIllegalTransition},
+		{name: "offload while on an amr", from: StatusOnAMR, ev: EventOffload, to: robot, wantErr: ErrIllegalTransition},
+		{name: "shifted cargo cannot come back", from: StatusShift, ev: EventLoad, to: stack, wantErr: ErrIllegalTransition},
+		{name: "shifted cargo cannot shift again", from: StatusShift, ev: EventShift, to: Holder{}, wantErr: ErrIllegalTransition},
+
+		{name: "spawn onto a robot", from: StatusPreSpawn, ev: EventSpawn, to: robot, wantErr: ErrInvalidHolder},
+		{name: "offload to a location", from: StatusAtLocation, ev: EventOffload, to: stack, wantErr: ErrInvalidHolder},
+		{name: "offload to an unknown robot", from: StatusAtLocation, ev: EventOffload, to: Holder{Kind: HolderRobot}, wantErr: ErrInvalidHolder},
+		{name: "shift to a holder", from: StatusAtLocation, ev: EventShift, to: stack, wantErr: ErrInvalidHolder},
+		{name: "load without a holder", from: StatusOnAMR, ev: EventLoad, to: Holder{}, wantErr: ErrInvalidHolder},
+
+		{name: "unknown event", from: StatusAtLocation, ev: "teleport", to: stack, wantErr: ErrUnknownEvent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Next(tt.from, tt.ev, tt.to)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Next = %q, %v; want %v", got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("Next = %q, %v; want %q", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	to := Holder{Kind: HolderStack, ID: "L1"}
+
+	mv, err := Plan("c1", StatusPreSpawn, Arrival(""), to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Move{CargoID: "c1", From: StatusPreSpawn, To: StatusAtLocation, Event: EventSpawn, Holder: to}
+	if mv != want {
+		t.Fatalf("Plan = %+v, want %+v", mv, want)
+	}
+
+	if _, err := Plan("c2", StatusOnAMR, Arrival(StatusOnAMR), to); err != nil {
+		t.Fatalf("Plan load = %v", err)
+	}
+	if _, err := Plan("c3", StatusPreSpawn, EventOffload, Holder{Kind: HolderRobot, ID: "amr-1"}); !errors.Is(err, ErrIllegalTransition) {
+		t.Fatalf("Plan offload before spawn = %v, want ErrIllegalTransition", err)
+	}
+}
+
+func TestHolderOwner(t *testing.T) {
+	tests := []struct {
+		holder Holder
+		want   string
+	}{
+		{Holder{Kind: HolderStack, ID: "L1"}, "STORAGE"},
+		{Holder{Kind: HolderShelf, ID: "S1"}, "STORAGE"},
+		{Holder{Kind: HolderMachine, ID: "M1"}, "STORAGE"},
+		{Holder{Kind: HolderConveyor, ID: "C1"}, "CONVEYOR"},
+		{Holder{Kind: HolderElevator, ID: "E1"}, "ELEVATOR"},
+		{Holder{Kind: HolderRobot, ID: "amr-1"}, "AMR"},
+		{Holder{}, "SHIFT"},
+	}
+
+	for _, tt := range tests {
+		if got := string(tt.holder.Owner()); got != tt.want {
+			t.Errorf("%s Owner = %s, want %s", tt.holder, got, tt.want)
+		}
+	}
+}
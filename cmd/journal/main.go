@@ -0,0 +1,147 @@
+// journal 重播貨物事件 journal 到指定時間點，用來追查貨物去向：
+//
+//	go run ./cmd/journal -file journal/cargo.jsonl -until 2026-10-19T08:30:00+08:00
+//	go run ./cmd/journal -cargo PALLET-001
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"kenmec/peripheral/jimmy/peripheral"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+func main() {
+	file := flag.String("file", "journal/cargo.jsonl", "journal file")
+	untilStr := flag.String("until", "", "replay up to this time (RFC3339), default: everything")
+	cargoID := flag.String("cargo", "", "print the history of this cargo instead of the final state")
+	flag.Parse()
+
+	var until time.Time
+	if *untilStr != "" {
+		t, err := time.Parse(time.RFC3339, *untilStr)
+		if err != nil {
+			fail("invalid -until: %v", err)
+		}
+		until = t
+	}
+
+	var err error
+	if *cargoID != "" {
+		err = traceCargo(os.Stdout, *file, until, *cargoID)
+	} else {
+		err = printState(os.Stdout, *file, until)
+	}
+	if err != nil {
+		fail("%v", err)
+	}
+}
+
+func fail(msg string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, msg+"\n", args...)
+	os.Exit(1)
+}
+
+func printState(w io.Writer, file string, until time.Time) error {
+	snap, err := peripheral.ReplayJournal(file, until)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "script %s, last event at %s\n", snap.ScriptID, snap.CreatedAt.Format(time.RFC3339Nano))
+
+	locIDs := make([]string, 0, len(snap.Stacks))
+	for locID := range snap.Stacks {
+		locIDs = append(locIDs, locID)
+	}
+	sort.Strings(locIDs)
+
+	for _, locID := range locIDs {
+		s := snap.Stacks[locID]
+
+		ids := make([]string, 0, len(s.Cargo))
+		for _, c := range s.Cargo {
+			ids = append(ids, c.ID)
+		}
+
+		note := ""
+		if s.Disable {
+			note = " (disabled)"
+		}
+		fmt.Fprintf(w, "%-20s %d/%d%s [%s]\n", locID, len(s.Cargo), s.StackCount, note, strings.Join(ids, ", "))
+	}
+	return nil
+}
+
+// traceCargo 逐筆套用，貨物位置有變或事件直接提到這個貨物就印出來
+func traceCargo(w io.Writer, file string, until time.Time, cargoID string) error {
+	snap := &peripheral.StateSnapshot{Stacks: map[string]peripheral.YFYStack{}}
+	prevLoc, prevPos := "", -1
+
+	err := peripheral.ReadJournal(file, func(e peripheral.JournalEntry) error {
+		if !until.IsZero() && e.Timestamp.After(until) {
+			return io.EOF
+		}
+		if err := peripheral.ApplyJournal(snap, e); err != nil {
+			return err
+		}
+
+		loc, pos := locate(snap, cargoID)
+		if loc == prevLoc && pos == prevPos && !mentions(e, cargoID) {
+			return nil
+		}
+		prevLoc, prevPos = loc, pos
+
+		location := e.LocationID
+		if e.ToLocationID != "" {
+			location += "->" + e.ToLocationID
+		}
+
+		where := "not on any stack"
+		if loc != "" {
+			where = fmt.Sprintf("at %s position %d", loc, pos)
+		}
+		fmt.Fprintf(w, "#%d %s %-14s actor=%s location=%s -> %s\n",
+			e.Seq, e.Timestamp.Format(time.RFC3339Nano), e.Type, e.Actor, location, where)
+		return nil
+	})
+	if errors.Is(err, peripheral.ErrJournalPartialLine) {
+		// 當機留下的殘缺行，前面的紀錄仍然有效
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+		err = nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if prevPos < 0 && prevLoc == "" {
+		fmt.Fprintf(w, "cargo %s is not on any stack\n", cargoID)
+	}
+	return nil
+}
+
+// locate 回傳貨物所在位置與層數 (0 是最底層)
+func locate(snap *peripheral.StateSnapshot, cargoID string) (string, int) {
+	for locID, s := range snap.Stacks {
+		for i, c := range s.Cargo {
+			if c.ID == cargoID {
+				return locID, i
+			}
+		}
+	}
+	return "", -1
+}
+
+func mentions(e peripheral.JournalEntry, cargoID string) bool {
+	for _, c := range e.Cargo {
+		if c.ID == cargoID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,508 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"kenmec/peripheral/jimmy/infra"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is prepended to every environment override, e.g. PERIPHERAL_DATABASE_DSN
+const EnvPrefix = "PERIPHERAL_"
+
+// Config is the full service configuration
+type Config struct {
+	Database  DatabaseConfig  `yaml:"database" toml:"database"`
+	Redis     RedisConfig     `yaml:"redis" toml:"redis"`
+	Upstream  UpstreamConfig  `yaml:"upstream" toml:"upstream"`
+	Bus       BusConfig       `yaml:"bus" toml:"bus"`
+	Simulator SimulatorConfig `yaml:"simulator" toml:"simulator"`
+	Lifecycle LifecycleConfig `yaml:"lifecycle" toml:"lifecycle"`
+	HTTP      HTTPConfig      `yaml:"http" toml:"http"`
+	Log       LogConfig       `yaml:"log" toml:"log"`
+	Snapshot  SnapshotConfig  `yaml:"snapshot" toml:"snapshot"`
+	Journal   JournalConfig   `yaml:"journal" toml:"journal"`
+	MockWCS   MockWCSConfig   `yaml:"mock_wcs" toml:"mock_wcs"`
+	GRPC      GRPCConfig      `yaml:"grpc" toml:"grpc"`
+	Cargo     CargoConfig     `yaml:"cargo" toml:"cargo"`
+	Timeline  TimelineConfig  `yaml:"timeline" toml:"timeline"`
+	Machines  MachinesConfig  `yaml:"machines" toml:"machines"`
+	Areas     AreasConfig     `yaml:"areas" toml:"areas"`
+}
+
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn" toml:"dsn"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"`
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr" toml:"addr"`
+	Password string `yaml:"password" toml:"password"`
+	DB       int    `yaml:"db" toml:"db"`
+
+	ConnectRetries int `yaml:"connect_retries" toml:"connect_retries"`
+	// ScriptChannel is an extra pub/sub channel announcing script changes, for servers without keyspace notifications
+	ScriptChannel string `yaml:"script_channel" toml:"script_channel"`
+}
+
+type UpstreamConfig struct {
+	Target            string        `yaml:"target" toml:"target"`
+	BackoffBase       time.Duration `yaml:"backoff_base" toml:"backoff_base"`
+	BackoffMax        time.Duration `yaml:"backoff_max" toml:"backoff_max"`
+	BackoffMultiplier float64       `yaml:"backoff_multiplier" toml:"backoff_multiplier"`
+	BackoffJitter     float64       `yaml:"backoff_jitter" toml:"backoff_jitter"`
+	KeepaliveTime     time.Duration `yaml:"keepalive_time" toml:"keepalive_time"`
+	KeepaliveTimeout  time.Duration `yaml:"keepalive_timeout" toml:"keepalive_timeout"`
+	TLS               TLSConfig     `yaml:"tls" toml:"tls"`
+}
+
+type TLSConfig struct {
+	Enabled    bool   `yaml:"enabled" toml:"enabled"`
+	CAFile     string `yaml:"ca_file" toml:"ca_file"`
+	CertFile   string `yaml:"cert_file" toml:"cert_file"`
+	KeyFile    string `yaml:"key_file" toml:"key_file"`
+	ServerName string `yaml:"server_name" toml:"server_name"`
+}
+
+type BusConfig struct {
+	// RequestTimeout is how long a request-response bus request, such as a timeline command to a mock WCS station, waits for its handler
+	RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout"`
+}
+
+type SimulatorConfig struct {
+	PushInterval time.Duration `yaml:"push_interval" toml:"push_interval"`
+	Scale        float64       `yaml:"scale" toml:"scale"`
+}
+
+type LifecycleConfig struct {
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+}
+
+type HTTPConfig struct {
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+type LogConfig struct {
+	Level  string `yaml:"level" toml:"level"`   // debug, info, warn, error
+	Format string `yaml:"format" toml:"format"` // json, text
+}
+
+type SnapshotConfig struct {
+	Dir string `yaml:"dir" toml:"dir"`
+	// Interval is how often the latest snapshot is written automatically, 0 disables it
+	Interval time.Duration `yaml:"interval" toml:"interval"`
+}
+
+type JournalConfig struct {
+	// Path is the JSON Lines journal of cargo events, empty disables it
+	Path string `yaml:"path" toml:"path"`
+}
+
+type MockWCSConfig struct {
+	// Timeout is how long a command hit by an injected timeout fault waits before failing, in simulated time
+	Timeout time.Duration `yaml:"timeout" toml:"timeout"`
+}
+
+type GRPCConfig struct {
+	// Addr is where this service serves gRPC queries such as FindCargo, empty disables it
+	Addr string `yaml:"addr" toml:"addr"`
+}
+
+type CargoConfig struct {
+	// IndexMetadataKey is the metadata field indexed for FindCargo lookups, e.g. sku
+	IndexMetadataKey string `yaml:"index_metadata_key" toml:"index_metadata_key"`
+}
+
+type TimelineConfig struct {
+	// AutoStart starts the current script's timeline at startup and after a script change
+	AutoStart bool `yaml:"auto_start" toml:"auto_start"`
+	// SampleInterval is how often stack utilisation is sampled during a run, in simulated time
+	SampleInterval time.Duration `yaml:"sample_interval" toml:"sample_interval"`
+	// ResultDir receives a detailed <simulation_result id>.json per run, empty disables it
+	ResultDir string `yaml:"result_dir" toml:"result_dir"`
+}
+
+type MachinesConfig struct {
+	// ArmCycleTime is one pick-and-place of a robotic arm, half to pick and half to place, in simulated time
+	ArmCycleTime time.Duration `yaml:"arm_cycle_time" toml:"arm_cycle_time"`
+	// PalletizerPatternCount is how many layers make a full pallet
+	PalletizerPatternCount int `yaml:"palletizer_pattern_count" toml:"palletizer_pattern_count"`
+	// PalletizerLayerSize is how many cargos make one layer
+	PalletizerLayerSize int `yaml:"palletizer_layer_size" toml:"palletizer_layer_size"`
+	// RotateSpeed is how fast a rotate table turns, in degrees per second
+	RotateSpeed float64 `yaml:"rotate_speed" toml:"rotate_speed"`
+}
+
+type AreasConfig struct {
+	// DefaultTTL is how long a claim without its own ttl holds a location, in simulated time; 0 never expires
+	DefaultTTL time.Duration `yaml:"default_ttl" toml:"default_ttl"`
+	// SweepInterval is how often expired claims are released
+	SweepInterval time.Duration `yaml:"sweep_interval" toml:"sweep_interval"`
+}
+
+// Default returns the configuration used for anything not set elsewhere
+func Default() Config {
+	return Config{
+		Database: DatabaseConfig{
+			MaxOpenConns:    10,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Redis: RedisConfig{
+			Addr:           "localhost:6379",
+			ConnectRetries: 5,
+			ScriptChannel:  "script-changed",
+		},
+		Upstream: UpstreamConfig{
+			Target:            "localhost:50051",
+			BackoffBase:       500 * time.Millisecond,
+			BackoffMax:        30 * time.Second,
+			BackoffMultiplier: 1.6,
+			BackoffJitter:     0.2,
+			KeepaliveTime:     5 * time.Minute,
+			KeepaliveTimeout:  20 * time.Second,
+		},
+		Bus: BusConfig{
+			RequestTimeout: 30 * time.Second,
+		},
+		Simulator: SimulatorConfig{
+			PushInterval: 1 * time.Second,
+			Scale:        1,
+		},
+		Lifecycle: LifecycleConfig{
+			ShutdownTimeout: 10 * time.Second,
+		},
+		HTTP: HTTPConfig{
+			Addr: ":8080",
+		},
+		Log: LogConfig{
+			Level:  "info",
+			Format: "json",
+		},
+		Snapshot: SnapshotConfig{
+			Dir:      "snapshots",
+			Interval: 1 * time.Minute,
+		},
+		Journal: JournalConfig{
+			Path: "journal/cargo.jsonl",
+		},
+		MockWCS: MockWCSConfig{
+			Timeout: 30 * time.Second,
+		},
+		GRPC: GRPCConfig{
+			Addr: ":50052",
+		},
+		Timeline: TimelineConfig{
+			SampleInterval: 1 * time.Minute,
+			ResultDir:      "simulation_results",
+		},
+		Machines: MachinesConfig{
+			ArmCycleTime:           12 * time.Second,
+			PalletizerPatternCount: 5,
+			PalletizerLayerSize:    4,
+			RotateSpeed:            90,
+		},
+		Areas: AreasConfig{
+			DefaultTTL:    5 * time.Minute,
+			SweepInterval: 1 * time.Second,
+		},
+	}
+}
+
+// field binds one config key to its value for env and flag overrides
+type field struct {
+	key   string
+	ptr   interface{}
+	usage string
+}
+
+func (c *Config) fields() []field {
+	return []field{
+		{"database.dsn", &c.Database.DSN, "MySQL DSN"},
+		{"database.max_open_conns", &c.Database.MaxOpenConns, "max open MySQL connections"},
+		{"database.max_idle_conns", &c.Database.MaxIdleConns, "max idle MySQL connections"},
+		{"database.conn_max_lifetime", &c.Database.ConnMaxLifetime, "max lifetime of a MySQL connection"},
+		{"redis.addr", &c.Redis.Addr, "Redis address"},
+		{"redis.password", &c.Redis.Password, "Redis password"},
+		{"redis.db", &c.Redis.DB, "Redis database number"},
+		{"redis.connect_retries", &c.Redis.ConnectRetries, "ping retries before giving up at startup"},
+		{"redis.script_channel", &c.Redis.ScriptChannel, "pub/sub channel announcing a script change"},
+		{"upstream.target", &c.Upstream.Target, "upstream gRPC target"},
+		{"upstream.backoff_base", &c.Upstream.BackoffBase, "first reconnect delay"},
+		{"upstream.backoff_max", &c.Upstream.BackoffMax, "max reconnect delay"},
+		{"upstream.backoff_multiplier", &c.Upstream.BackoffMultiplier, "reconnect delay multiplier"},
+		{"upstream.backoff_jitter", &c.Upstream.BackoffJitter, "reconnect delay jitter (0-1)"},
+		{"upstream.keepalive_time", &c.Upstream.KeepaliveTime, "gRPC keepalive ping interval"},
+		{"upstream.keepalive_timeout", &c.Upstream.KeepaliveTimeout, "gRPC keepalive ping timeout"},
+		{"upstream.tls.enabled", &c.Upstream.TLS.Enabled, "use TLS for the upstream connection"},
+		{"upstream.tls.ca_file", &c.Upstream.TLS.CAFile, "CA certificate file"},
+		{"upstream.tls.cert_file", &c.Upstream.TLS.CertFile, "client certificate file (mTLS)"},
+		{"upstream.tls.key_file", &c.Upstream.TLS.KeyFile, "client key file (mTLS)"},
+		{"upstream.tls.server_name", &c.Upstream.TLS.ServerName, "expected server name"},
+		{"bus.request_timeout", &c.Bus.RequestTimeout, "default request-response bus timeout"},
+		{"simulator.push_interval", &c.Simulator.PushInterval, "interval between snapshot pushes"},
+		{"simulator.scale", &c.Simulator.Scale, "simulation time scale, e.g. 10 runs 10x faster than the wall clock"},
+		{"lifecycle.shutdown_timeout", &c.Lifecycle.ShutdownTimeout, "max time to wait for a clean shutdown"},
+		{"http.addr", &c.HTTP.Addr, "listen address of the HTTP server (metrics, health, debug)"},
+		{"log.level", &c.Log.Level, "log level: debug, info, warn or error"},
+		{"log.format", &c.Log.Format, "log format: json or text"},
+		{"snapshot.dir", &c.Snapshot.Dir, "directory of the state snapshots"},
+		{"snapshot.interval", &c.Snapshot.Interval, "interval between automatic snapshots, 0 disables them"},
+		{"journal.path", &c.Journal.Path, "cargo event journal file, empty disables it"},
+		{"mock_wcs.timeout", &c.MockWCS.Timeout, "wait of a command hit by an injected timeout fault"},
+		{"grpc.addr", &c.GRPC.Addr, "listen address of the gRPC server, empty disables it"},
+		{"cargo.index_metadata_key", &c.Cargo.IndexMetadataKey, "metadata field indexed for cargo lookups"},
+		{"timeline.auto_start", &c.Timeline.AutoStart, "start the script timeline at startup and on script change"},
+		{"timeline.sample_interval", &c.Timeline.SampleInterval, "interval between utilisation samples of a run"},
+		{"timeline.result_dir", &c.Timeline.ResultDir, "directory of the detailed simulation results, empty disables them"},
+		{"machines.arm_cycle_time", &c.Machines.ArmCycleTime, "pick-and-place cycle time of a robotic arm"},
+		{"machines.palletizer_pattern_count", &c.Machines.PalletizerPatternCount, "layers of a full pallet"},
+		{"machines.palletizer_layer_size", &c.Machines.PalletizerLayerSize, "cargos per palletizer layer"},
+		{"machines.rotate_speed", &c.Machines.RotateSpeed, "rotate table speed in degrees per second"},
+		{"areas.default_ttl", &c.Areas.DefaultTTL, "expiry of an area claim without its own ttl, 0 never expires"},
+		{"areas.sweep_interval", &c.Areas.SweepInterval, "interval between releases of expired area claims"},
+	}
+}
+
+// Load builds the configuration from defaults, the config file, environment
+// variables and command line flags, in that order of precedence (last wins).
+// The config file is taken from -config or PERIPHERAL_CONFIG.
+func Load(args []string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("peripheral", flag.ContinueOnError)
+	path := fs.String("config", os.Getenv(EnvPrefix+"CONFIG"), "path to a YAML or TOML config file")
+
+	flagValues := make(map[string]*string)
+	for _, f := range cfg.fields() {
+		flagValues[f.key] = fs.String(f.key, "", f.usage)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		if err := loadFile(*path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+
+	for _, f := range cfg.fields() {
+		if v, ok := os.LookupEnv(envName(f.key)); ok {
+			if err := setValue(f.ptr, v); err != nil {
+				return cfg, fmt.Errorf("env %s: %w", envName(f.key), err)
+			}
+		}
+	}
+
+	var flagErr error
+	fields := cfg.fields()
+	fs.Visit(func(fl *flag.Flag) {
+		for _, f := range fields {
+			if f.key == fl.Name && flagErr == nil {
+				if err := setValue(f.ptr, *flagValues[f.key]); err != nil {
+					flagErr = fmt.Errorf("flag -%s: %w", f.key, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return cfg, flagErr
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// Validate checks that the configuration is usable
+func (c Config) Validate() error {
+	var errs []error
+
+	if c.Database.DSN == "" {
+		errs = append(errs, errors.New("database.dsn is required"))
+	}
+	if c.Database.MaxOpenConns < 0 || c.Database.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("database connection limits must not be negative"))
+	}
+	if c.Redis.Addr == "" {
+		errs = append(errs, errors.New("redis.addr is required"))
+	}
+	if c.Redis.DB < 0 || c.Redis.ConnectRetries < 0 {
+		errs = append(errs, errors.New("redis.db and redis.connect_retries must not be negative"))
+	}
+	if c.Upstream.Target == "" {
+		errs = append(errs, errors.New("upstream.target is required"))
+	}
+	if c.Upstream.BackoffBase <= 0 || c.Upstream.BackoffMax < c.Upstream.BackoffBase {
+		errs = append(errs, errors.New("upstream backoff must satisfy 0 < backoff_base <= backoff_max"))
+	}
+	if c.Upstream.BackoffMultiplier < 1 {
+		errs = append(errs, errors.New("upstream.backoff_multiplier must be >= 1"))
+	}
+	if c.Upstream.BackoffJitter < 0 || c.Upstream.BackoffJitter > 1 {
+		errs = append(errs, errors.New("upstream.backoff_jitter must be between 0 and 1"))
+	}
+	if c.Upstream.KeepaliveTime <= 0 || c.Upstream.KeepaliveTimeout <= 0 {
+		errs = append(errs, errors.New("upstream keepalive durations must be positive"))
+	}
+	if tls := c.Upstream.TLS; tls.Enabled {
+		if (tls.CertFile == "") != (tls.KeyFile == "") {
+			errs = append(errs, errors.New("upstream.tls.cert_file and key_file must be set together"))
+		}
+		for _, f := range []string{tls.CAFile, tls.CertFile, tls.KeyFile} {
+			if f == "" {
+				continue
+			}
+			if _, err := os.Stat(f); err != nil {
+				errs = append(errs, fmt.Errorf("upstream tls file: %w", err))
+			}
+		}
+	}
+	if c.Bus.RequestTimeout <= 0 {
+		errs = append(errs, errors.New("bus.request_timeout must be positive"))
+	}
+	if c.Simulator.PushInterval <= 0 {
+		errs = append(errs, errors.New("simulator.push_interval must be positive"))
+	}
+	if c.Simulator.Scale <= 0 {
+		errs = append(errs, errors.New("simulator.scale must be positive"))
+	}
+	if c.MockWCS.Timeout <= 0 {
+		errs = append(errs, errors.New("mock_wcs.timeout must be positive"))
+	}
+	if c.Timeline.SampleInterval <= 0 {
+		errs = append(errs, errors.New("timeline.sample_interval must be positive"))
+	}
+	if c.Machines.ArmCycleTime <= 0 {
+		errs = append(errs, errors.New("machines.arm_cycle_time must be positive"))
+	}
+	if c.Machines.PalletizerPatternCount <= 0 {
+		errs = append(errs, errors.New("machines.palletizer_pattern_count must be positive"))
+	}
+	if c.Machines.PalletizerLayerSize <= 0 {
+		errs = append(errs, errors.New("machines.palletizer_layer_size must be positive"))
+	}
+	if c.Machines.RotateSpeed <= 0 {
+		errs = append(errs, errors.New("machines.rotate_speed must be positive"))
+	}
+	if c.Areas.DefaultTTL < 0 {
+		errs = append(errs, errors.New("areas.default_ttl must not be negative"))
+	}
+	if c.Areas.SweepInterval <= 0 {
+		errs = append(errs, errors.New("areas.sweep_interval must be positive"))
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		errs = append(errs, fmt.Errorf("log.level %q must be debug, info, warn or error", c.Log.Level))
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "json", "text":
+	default:
+		errs = append(errs, fmt.Errorf("log.format %q must be json or text", c.Log.Format))
+	}
+	if c.HTTP.Addr == "" {
+		errs = append(errs, errors.New("http.addr is required"))
+	}
+	if c.Lifecycle.ShutdownTimeout <= 0 {
+		errs = append(errs, errors.New("lifecycle.shutdown_timeout must be positive"))
+	}
+	if c.Snapshot.Dir == "" {
+		errs = append(errs, errors.New("snapshot.dir is required"))
+	}
+	if c.Snapshot.Interval < 0 {
+		errs = append(errs, errors.New("snapshot.interval must not be negative"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file type: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func envName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_").Replace(key))
+}
+
+func setValue(ptr interface{}, s string) error {
+	switch p := ptr.(type) {
+	case *string:
+		*p = s
+	case *int:
+		v, err := strconv.Atoi(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *bool:
+		v, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *float64:
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return err
+		}
+		*p = v
+	case *time.Duration:
+		v, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*p = v
+	default:
+		return fmt.Errorf("unsupported config type %T", ptr)
+	}
+	return nil
+}
+
+// ClientConfig converts the upstream section into the infra client configuration
+func (u UpstreamConfig) ClientConfig() infra.UpstreamConfig {
+	return infra.UpstreamConfig{
+		Target: u.Target,
+		Backoff: infra.Backoff{
+			Base:       u.BackoffBase,
+			Max:        u.BackoffMax,
+			Multiplier: u.BackoffMultiplier,
+			Jitter:     u.BackoffJitter,
+		},
+		KeepaliveTime:    u.KeepaliveTime,
+		KeepaliveTimeout: u.KeepaliveTimeout,
+		TLS: infra.TLSConfig{
+			Enabled:    u.TLS.Enabled,
+			CAFile:     u.TLS.CAFile,
+			CertFile:   u.TLS.CertFile,
+			KeyFile:    u.TLS.KeyFile,
+			ServerName: u.TLS.ServerName,
+		},
+	}
+}
@@ -0,0 +1,142 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestLoadPrecedence checks that each source overrides the ones before it:
+// defaults, then the config file, then environment variables, then flags
+func TestLoadPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.yaml")
+	yaml := "database:\n  dsn: file-dsn\nhttp:\n  addr: \":1001\"\nbus:\n  request_timeout: 11s\n"
+	if err := os.WriteFile(file, []byte(yaml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		file        bool
+		env         map[string]string
+		args        []string
+		wantAddr    string
+		wantTimeout time.Duration
+	}{
+		{
+			name:        "defaults",
+			env:         map[string]string{"PERIPHERAL_DATABASE_DSN": "env-dsn"},
+			wantAddr:    Default().HTTP.Addr,
+			wantTimeout: Default().Bus.RequestTimeout,
+		},
+		{
+			name:        "file over defaults",
+			file:        true,
+			wantAddr:    ":1001",
+			wantTimeout: 11 * time.Second,
+		},
+		{
+			name:        "env over file",
+			file:        true,
+			env:         map[string]string{"PERIPHERAL_HTTP_ADDR": ":2002"},
+			wantAddr:    ":2002",
+			wantTimeout: 11 * time.Second,
+		},
+		{
+			name:        "flag over env",
+			file:        true,
+			env:         map[string]string{"PERIPHERAL_HTTP_ADDR": ":2002", "PERIPHERAL_BUS_REQUEST_TIMEOUT": "22s"},
+			args:        []string{"-http.addr", ":3003"},
+			wantAddr:    ":3003",
+			wantTimeout: 22 * time.Second,
+		},
+		{
+			name:        "flag over file",
+			file:        true,
+			args:        []string{"-bus.request_timeout", "33s"},
+			wantAddr:    ":1001",
+			wantTimeout: 33 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Clear what the surrounding environment may set for these keys
+			for _, key := range []string{"CONFIG", "DATABASE_DSN", "HTTP_ADDR", "BUS_REQUEST_TIMEOUT"} {
+				t.Setenv(EnvPrefix+key, "")
+				os.Unsetenv(EnvPrefix + key)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			args := tt.args
+			if tt.file {
+				args = append([]string{"-config", file}, args...)
+			}
+
+			cfg, err := Load(args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.HTTP.Addr != tt.wantAddr || cfg.Bus.RequestTimeout != tt.wantTimeout {
+				t.Errorf("http.addr, bus.request_timeout = %q, %v; want %q, %v",
+					cfg.HTTP.Addr, cfg.Bus.RequestTimeout, tt.wantAddr, tt.wantTimeout)
+			}
+		})
+	}
+}
+
+// TestLoadConfigFromEnv reads the config file named by PERIPHERAL_CONFIG when -config is not given
+func TestLoadConfigFromEnv(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.toml")
+	toml := "[database]\ndsn = \"toml-dsn\"\n"
+	if err := os.WriteFile(file, []byte(toml), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(EnvPrefix+"DATABASE_DSN", "")
+	os.Unsetenv(EnvPrefix + "DATABASE_DSN")
+	t.Setenv(EnvPrefix+"CONFIG", file)
+
+	cfg, err := Load(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Database.DSN != "toml-dsn" {
+		t.Errorf("database.dsn = %q, want toml-dsn from PERIPHERAL_CONFIG", cfg.Database.DSN)
+	}
+}
+
+// TestLoadErrors covers values that cannot be parsed or do not pass Validate
+func TestLoadErrors(t *testing.T) {
+	t.Setenv(EnvPrefix+"CONFIG", "")
+	os.Unsetenv(EnvPrefix + "CONFIG")
+	t.Setenv(EnvPrefix+"DATABASE_DSN", "dsn")
+	ini := filepath.Join(t.TempDir(), "config.ini")
+	if err := os.WriteFile(ini, []byte("[database]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+	}{
+		{name: "missing dsn", env: map[string]string{"PERIPHERAL_DATABASE_DSN": ""}},
+		{name: "bad env value", env: map[string]string{"PERIPHERAL_REDIS_DB": "zero"}},
+		{name: "bad flag value", args: []string{"-bus.request_timeout", "soon"}},
+		{name: "invalid value", args: []string{"-bus.request_timeout", "0s"}},
+		{name: "missing file", args: []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}},
+		{name: "unknown file type", args: []string{"-config", ini}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			if _, err := Load(tt.args); err == nil {
+				t.Error("Load = nil, want an error")
+			}
+		})
+	}
+}
@@ -10,8 +10,72 @@ import (
 	"database/sql"
 	"encoding/json"
 	"strings"
+	"time"
 )
 
+const allConveyor = `-- name: AllConveyor :many
+SELECT
+    loc.locationId AS locationId,
+    cc.id AS conveyor_id,
+    cc.disable AS conveyor_disable,
+    cc.hasCargo AS has_cargo,
+    cc.is_spawn_cargo,
+    cc.active_shift,
+    peripheral_name.id as peripheral_id,
+    peripheral_name.name as peripheral_name,
+    peripheral_name.description as peripheral_desc
+FROM Loc loc
+ JOIN mock_wcs_station mws ON loc.id = mws.sourceId
+ JOIN conveyor_config cc ON mws.conveyor_id = cc.id
+ JOIN peripheral_name ON cc.name = peripheral_name.id
+ WHERE loc.mission_script_id = ?
+`
+
+type AllConveyorRow struct {
+	Locationid      string
+	ConveyorID      string
+	ConveyorDisable bool
+	HasCargo        bool
+	IsSpawnCargo    bool
+	ActiveShift     bool
+	PeripheralID    string
+	PeripheralName  sql.NullString
+	PeripheralDesc  string
+}
+
+func (q *Queries) AllConveyor(ctx context.Context, missionScriptID sql.NullString) ([]AllConveyorRow, error) {
+	rows, err := q.db.QueryContext(ctx, allConveyor, missionScriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []AllConveyorRow
+	for rows.Next() {
+		var i AllConveyorRow
+		if err := rows.Scan(
+			&i.Locationid,
+			&i.ConveyorID,
+			&i.ConveyorDisable,
+			&i.HasCargo,
+			&i.IsSpawnCargo,
+			&i.ActiveShift,
+			&i.PeripheralID,
+			&i.PeripheralName,
+			&i.PeripheralDesc,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const allStack = `-- name: AllStack :many
 SELECT 
     ms.id,
@@ -25,8 +89,15 @@ SELECT
     -- cargo_info.metadata as cargo_metadata,
     -- cargo_info.custom_id as cargo_custom_id,
     -- cargo_info.custom_cargo_metadata_id as custom_cargo_metadata_id,
+    peripheral_name.id as peripheral_id,
     peripheral_name.name as peripheral_name,
-    peripheral_name.description as peripheral_desc
+    peripheral_name.description as peripheral_desc,
+    peripheral_name.group_id as group_id,
+    peripheral_name.load_priority,
+    peripheral_name.offload_priority,
+    loc.x,
+    loc.y,
+    loc.placement_priority
 FROM mission_script ms
  JOIN Loc loc ON ms.id = loc.mission_script_id
  JOIN mock_wcs_station mws ON loc.id = mws.sourceId
@@ -36,14 +107,21 @@ FROM mission_script ms
 `
 
 type AllStackRow struct {
-	ID             string
-	Locationid     string
-	Stackid        string
-	StackDisable   bool
-	StackHeights   json.RawMessage
-	StackCount     int32
-	PeripheralName sql.NullString
-	PeripheralDesc string
+	ID                string
+	Locationid        string
+	Stackid           string
+	StackDisable      bool
+	StackHeights      json.RawMessage
+	StackCount        int32
+	PeripheralID      string
+	PeripheralName    sql.NullString
+	PeripheralDesc    string
+	GroupID           sql.NullString
+	LoadPriority      int32
+	OffloadPriority   int32
+	X                 float64
+	Y                 float64
+	PlacementPriority int32
 }
 
 // JOIN cargo_info ON stack.id = cargo_info.stack_config_id
@@ -63,8 +141,15 @@ func (q *Queries) AllStack(ctx context.Context, id string) ([]AllStackRow, error
 			&i.StackDisable,
 			&i.StackHeights,
 			&i.StackCount,
+			&i.PeripheralID,
 			&i.PeripheralName,
 			&i.PeripheralDesc,
+			&i.GroupID,
+			&i.LoadPriority,
+			&i.OffloadPriority,
+			&i.X,
+			&i.Y,
+			&i.PlacementPriority,
 		); err != nil {
 			return nil, err
 		}
@@ -125,23 +210,203 @@ func (q *Queries) AllTitleBridgeLoc(ctx context.Context) ([]AllTitleBridgeLocRow
 	return items, nil
 }
 
+const getCargoInfo = `-- name: GetCargoInfo :one
+SELECT id, status, metadata, custom_id, custom_cargo_metadata_id
+FROM cargo_info
+WHERE id = ?
+`
+
+type GetCargoInfoRow struct {
+	ID                    string
+	Status                CargoInfoStatus
+	Metadata              json.RawMessage
+	CustomID              sql.NullString
+	CustomCargoMetadataID sql.NullString
+}
+
+func (q *Queries) GetCargoInfo(ctx context.Context, id string) (GetCargoInfoRow, error) {
+	row := q.db.QueryRowContext(ctx, getCargoInfo, id)
+	var i GetCargoInfoRow
+	err := row.Scan(
+		&i.ID,
+		&i.Status,
+		&i.Metadata,
+		&i.CustomID,
+		&i.CustomCargoMetadataID,
+	)
+	return i, err
+}
+
+const getCargoStack = `-- name: GetCargoStack :one
+SELECT stack_config_id
+FROM cargo_info
+WHERE id = ?
+`
+
+func (q *Queries) GetCargoStack(ctx context.Context, id string) (sql.NullString, error) {
+	row := q.db.QueryRowContext(ctx, getCargoStack, id)
+	var stack_config_id sql.NullString
+	err := row.Scan(&stack_config_id)
+	return stack_config_id, err
+}
+
+const insertCargoHistory = `-- name: InsertCargoHistory :exec
+INSERT INTO cargo_history (id, cargo_id, action, description, actor)
+VALUES (?, ?, ?, ?, ?)
+`
+
+type InsertCargoHistoryParams struct {
+	ID          string
+	CargoID     string
+	Action      CargoHistoryAction
+	Description sql.NullString
+	Actor       sql.NullString
+}
+
+func (q *Queries) InsertCargoHistory(ctx context.Context, arg InsertCargoHistoryParams) error {
+	_, err := q.db.ExecContext(ctx, insertCargoHistory,
+		arg.ID,
+		arg.CargoID,
+		arg.Action,
+		arg.Description,
+		arg.Actor,
+	)
+	return err
+}
+
+const listAreas = `-- name: ListAreas :many
+SELECT
+    loc.locationId AS locationId,
+    loc.name,
+    loc.areaType,
+    pn.quantity
+FROM Loc loc
+ LEFT JOIN peripheral_name pn ON pn.name = loc.name AND pn.type = loc.areaType
+ WHERE loc.mission_script_id = ?
+   AND loc.areaType IN ('STORAGE', 'DISPATCH', 'STANDBY', 'EXTRA')
+`
+
+type ListAreasRow struct {
+	Locationid string
+	Name       sql.NullString
+	Areatype   LocAreatype
+	Quantity   sql.NullInt32
+}
+
+// 一般位置沒有 config 表，peripheral_name 以同名同類型對應
+func (q *Queries) ListAreas(ctx context.Context, missionScriptID sql.NullString) ([]ListAreasRow, error) {
+	rows, err := q.db.QueryContext(ctx, listAreas, missionScriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListAreasRow
+	for rows.Next() {
+		var i ListAreasRow
+		if err := rows.Scan(
+			&i.Locationid,
+			&i.Name,
+			&i.Areatype,
+			&i.Quantity,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listCargosByShelfConfigIds = `-- name: ListCargosByShelfConfigIds :many
+SELECT
+    shelfConfigId,
+    id as cargo_id,
+    status as cargo_status,
+    metadata as cargo_metadata,
+    custom_id as cargo_custom_id,
+    custom_cargo_metadata_id,
+    updatedAt as cargo_updated_at
+FROM cargo_info
+WHERE shelfConfigId IN (/*SLICE:shelfConfigIds*/?) AND status = 'AT_LOCATION'
+`
+
+type ListCargosByShelfConfigIdsRow struct {
+	Shelfconfigid         sql.NullString
+	CargoID               string
+	CargoStatus           CargoInfoStatus
+	CargoMetadata         json.RawMessage
+	CargoCustomID         sql.NullString
+	CustomCargoMetadataID sql.NullString
+	CargoUpdatedAt        time.Time
+}
+
+func (q *Queries) ListCargosByShelfConfigIds(ctx context.Context, shelfconfigids []sql.NullString) ([]ListCargosByShelfConfigIdsRow, error) {
+	query := listCargosByShelfConfigIds
+	var queryParams []interface{}
+	if len(shelfconfigids) > 0 {
+		for _, v := range shelfconfigids {
+			queryParams = append(queryParams, v)
+		}
+		query = strings.Replace(query, "/*SLICE:shelfConfigIds*/?", strings.Repeat(",?", len(shelfconfigids))[1:], 1)
+	} else {
+		query = strings.Replace(query, "/*SLICE:shelfConfigIds*/?", "NULL", 1)
+	}
+	rows, err := q.db.QueryContext(ctx, query, queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListCargosByShelfConfigIdsRow
+	for rows.Next() {
+		var i ListCargosByShelfConfigIdsRow
+		if err := rows.Scan(
+			&i.Shelfconfigid,
+			&i.CargoID,
+			&i.CargoStatus,
+			&i.CargoMetadata,
+			&i.CargoCustomID,
+			&i.CustomCargoMetadataID,
+			&i.CargoUpdatedAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listCargosByStackIds = `-- name: ListCargosByStackIds :many
 SELECT 
     stack_config_id,
     id as cargo_id,
     status as cargo_status,
     metadata as cargo_metadata,
-    custom_id as cargo_custom_id
+    custom_id as cargo_custom_id,
+    custom_cargo_metadata_id,
+    updatedAt as cargo_updated_at
 FROM cargo_info 
-WHERE stack_config_id IN (/*SLICE:stackIds*/?)
+WHERE stack_config_id IN (/*SLICE:stackIds*/?) AND status = 'AT_LOCATION'
 `
 
 type ListCargosByStackIdsRow struct {
-	StackConfigID sql.NullString
-	CargoID       string
-	CargoStatus   CargoInfoStatus
-	CargoMetadata json.RawMessage
-	CargoCustomID sql.NullString
+	StackConfigID         sql.NullString
+	CargoID               string
+	CargoStatus           CargoInfoStatus
+	CargoMetadata         json.RawMessage
+	CargoCustomID         sql.NullString
+	CustomCargoMetadataID sql.NullString
+	CargoUpdatedAt        time.Time
 }
 
 // sqlc 支援傳入 slice: WHERE stack_config_id IN (?)
@@ -170,6 +435,8 @@ func (q *Queries) ListCargosByStackIds(ctx context.Context, stackids []sql.NullS
 			&i.CargoStatus,
 			&i.CargoMetadata,
 			&i.CargoCustomID,
+			&i.CustomCargoMetadataID,
+			&i.CargoUpdatedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -184,6 +451,358 @@ func (q *Queries) ListCargosByStackIds(ctx context.Context, stackids []sql.NullS
 	return items, nil
 }
 
+const listCustomCargoMetadata = `-- name: ListCustomCargoMetadata :many
+SELECT id, is_default, custom_name, format, unique_key
+FROM custom_cargo_metadata
+`
+
+func (q *Queries) ListCustomCargoMetadata(ctx context.Context) ([]CustomCargoMetadatum, error) {
+	rows, err := q.db.QueryContext(ctx, listCustomCargoMetadata)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []CustomCargoMetadatum
+	for rows.Next() {
+		var i CustomCargoMetadatum
+		if err := rows.Scan(
+			&i.ID,
+			&i.IsDefault,
+			&i.CustomName,
+			&i.Format,
+			&i.UniqueKey,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMachines = `-- name: ListMachines :many
+SELECT
+    loc.locationId AS locationId,
+    loc.name,
+    loc.areaType,
+    dir.yaw
+FROM Loc loc
+ LEFT JOIN Direction dir ON loc.dirId = dir.id
+ WHERE loc.mission_script_id = ?
+   AND loc.areaType IN ('ROBOTIC_ARM', 'PALLETIZER', 'ROTATE_TABLE')
+`
+
+type ListMachinesRow struct {
+	Locationid string
+	Name       sql.NullString
+	Areatype   LocAreatype
+	Yaw        sql.NullFloat64
+}
+
+func (q *Queries) ListMachines(ctx context.Context, missionScriptID sql.NullString) ([]ListMachinesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listMachines, missionScriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMachinesRow
+	for rows.Next() {
+		var i ListMachinesRow
+		if err := rows.Scan(
+			&i.Locationid,
+			&i.Name,
+			&i.Areatype,
+			&i.Yaw,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listMockWcsStations = `-- name: ListMockWcsStations :many
+SELECT
+    mws.id,
+    mws.machine_type,
+    mws.delay_ms,
+    mws.is_enabled,
+    loc.locationId AS locationId
+FROM mock_wcs_station mws
+ JOIN Loc loc ON loc.id = mws.sourceId
+ WHERE loc.mission_script_id = ?
+`
+
+type ListMockWcsStationsRow struct {
+	ID          string
+	MachineType MockWcsStationMachineType
+	DelayMs     int32
+	IsEnabled   bool
+	Locationid  string
+}
+
+func (q *Queries) ListMockWcsStations(ctx context.Context, missionScriptID sql.NullString) ([]ListMockWcsStationsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listMockWcsStations, missionScriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListMockWcsStationsRow
+	for rows.Next() {
+		var i ListMockWcsStationsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.MachineType,
+			&i.DelayMs,
+			&i.IsEnabled,
+			&i.Locationid,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listPeripheralGroups = `-- name: ListPeripheralGroups :many
+SELECT id, name, description, mission_script_id
+FROM peripheral_group
+WHERE mission_script_id = ?
+`
+
+func (q *Queries) ListPeripheralGroups(ctx context.Context, missionScriptID string) ([]PeripheralGroup, error) {
+	rows, err := q.db.QueryContext(ctx, listPeripheralGroups, missionScriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []PeripheralGroup
+	for rows.Next() {
+		var i PeripheralGroup
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.Description,
+			&i.MissionScriptID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listShelfLevels = `-- name: ListShelfLevels :many
+SELECT
+    loc.locationId AS locationId,
+    shelf.id AS shelf_id,
+    cat.name AS category_name,
+    cat.shelf_style,
+    sc.id AS shelf_config_id,
+    sc.level,
+    sc.hasCargo AS has_cargo,
+    sc.disable AS shelf_disable,
+    sc.cargo_limit,
+    sh.height,
+    peripheral_name.id as peripheral_id,
+    peripheral_name.name as peripheral_name,
+    peripheral_name.description as peripheral_desc
+FROM Loc loc
+ JOIN Shelf shelf ON loc.shelfId = shelf.id
+ LEFT JOIN ShelfCategory cat ON shelf.shelfCategoryId = cat.id
+ JOIN ShelfConfig sc ON sc.shelfId = shelf.id
+ JOIN ShelfHeight sh ON sc.shelfHeightId = sh.id
+ JOIN peripheral_name ON sc.name = peripheral_name.id
+ WHERE loc.mission_script_id = ?
+ ORDER BY loc.locationId, sc.level
+`
+
+type ListShelfLevelsRow struct {
+	Locationid     string
+	ShelfID        string
+	CategoryName   sql.NullString
+	ShelfStyle     sql.NullString
+	ShelfConfigID  string
+	Level          int32
+	HasCargo       bool
+	ShelfDisable   bool
+	CargoLimit     int32
+	Height         int32
+	PeripheralID   string
+	PeripheralName sql.NullString
+	PeripheralDesc string
+}
+
+func (q *Queries) ListShelfLevels(ctx context.Context, missionScriptID sql.NullString) ([]ListShelfLevelsRow, error) {
+	rows, err := q.db.QueryContext(ctx, listShelfLevels, missionScriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListShelfLevelsRow
+	for rows.Next() {
+		var i ListShelfLevelsRow
+		if err := rows.Scan(
+			&i.Locationid,
+			&i.ShelfID,
+			&i.CategoryName,
+			&i.ShelfStyle,
+			&i.ShelfConfigID,
+			&i.Level,
+			&i.HasCargo,
+			&i.ShelfDisable,
+			&i.CargoLimit,
+			&i.Height,
+			&i.PeripheralID,
+			&i.PeripheralName,
+			&i.PeripheralDesc,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listTimelines = `-- name: ListTimelines :many
+SELECT
+    t.id,
+    t.timestamp,
+    t.event_type,
+    t.type,
+    t.shift_peripheral_id,
+    t.simulation_result_id,
+    tsc.peripheral_name_id AS spawn_peripheral_id,
+    tsc.spawn_cargo_info_id,
+    spg.peripheral_group_id AS spawn_group_id,
+    spg.range AS spawn_range,
+    spg.active_interval AS spawn_interval,
+    spg.is_spawn_all,
+    spg.spawn_number,
+    spg.spawn_cargo_info_id AS group_spawn_cargo_info_id,
+    shg.peripheral_group_id AS shift_group_id,
+    shg.range AS shift_range,
+    shg.active_interval AS shift_interval,
+    shg.is_shift_all,
+    shg.shift_number
+FROM timeline t
+ LEFT JOIN timeline_spawn_cargo tsc ON tsc.timeline_id = t.id
+ LEFT JOIN spawn_cargo_peripheral_group_bridge spg ON spg.timeline_id = t.id
+ LEFT JOIN shift_cargo_peripheral_group_bridge shg ON shg.timeline_id = t.id
+ WHERE t.script_id = ? AND t.is_enable = 1
+ ORDER BY t.style_row, t.id
+`
+
+type ListTimelinesRow struct {
+	ID                    string
+	Timestamp             string
+	EventType             TimelineEventType
+	Type                  TimelineType
+	ShiftPeripheralID     sql.NullString
+	SimulationResultID    sql.NullString
+	SpawnPeripheralID     sql.NullString
+	SpawnCargoInfoID      sql.NullString
+	SpawnGroupID          sql.NullString
+	SpawnRange            sql.NullString
+	SpawnInterval         sql.NullInt32
+	IsSpawnAll            sql.NullBool
+	SpawnNumber           sql.NullInt32
+	GroupSpawnCargoInfoID sql.NullString
+	ShiftGroupID          sql.NullString
+	ShiftRange            sql.NullString
+	ShiftInterval         sql.NullInt32
+	IsShiftAll            sql.NullBool
+	ShiftNumber           sql.NullInt32
+}
+
+func (q *Queries) ListTimelines(ctx context.Context, scriptID string) ([]ListTimelinesRow, error) {
+	rows, err := q.db.QueryContext(ctx, listTimelines, scriptID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ListTimelinesRow
+	for rows.Next() {
+		var i ListTimelinesRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Timestamp,
+			&i.EventType,
+			&i.Type,
+			&i.ShiftPeripheralID,
+			&i.SimulationResultID,
+			&i.SpawnPeripheralID,
+			&i.SpawnCargoInfoID,
+			&i.SpawnGroupID,
+			&i.SpawnRange,
+			&i.SpawnInterval,
+			&i.IsSpawnAll,
+			&i.SpawnNumber,
+			&i.GroupSpawnCargoInfoID,
+			&i.ShiftGroupID,
+			&i.ShiftRange,
+			&i.ShiftInterval,
+			&i.IsShiftAll,
+			&i.ShiftNumber,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const moveCargo = `-- name: MoveCargo :execresult
+UPDATE cargo_info
+SET stack_config_id = ?, updatedAt = CURRENT_TIMESTAMP(3)
+WHERE id = ? AND stack_config_id = ?
+`
+
+type MoveCargoParams struct {
+	ToStackID   sql.NullString
+	CargoID     string
+	FromStackID sql.NullString
+}
+
+func (q *Queries) MoveCargo(ctx context.Context, arg MoveCargoParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, moveCargo, arg.ToStackID, arg.CargoID, arg.FromStackID)
+}
+
 const oneStack = `-- name: OneStack :one
 SELECT 
     ms.id,
@@ -197,8 +816,15 @@ SELECT
     -- cargo_info.metadata as cargo_metadata,
     -- cargo_info.custom_id as cargo_custom_id,
     -- cargo_info.custom_cargo_metadata_id as custom_cargo_metadata_id,
+    peripheral_name.id as peripheral_id,
     peripheral_name.name as peripheral_name,
-    peripheral_name.description as peripheral_desc
+    peripheral_name.description as peripheral_desc,
+    peripheral_name.group_id as group_id,
+    peripheral_name.load_priority,
+    peripheral_name.offload_priority,
+    loc.x,
+    loc.y,
+    loc.placement_priority
 FROM mission_script ms
  JOIN Loc loc ON ms.id = loc.mission_script_id
  JOIN mock_wcs_station mws ON loc.id = mws.sourceId
@@ -213,14 +839,21 @@ type OneStackParams struct {
 }
 
 type OneStackRow struct {
-	ID             string
-	Locationid     string
-	Stackid        string
-	StackDisable   bool
-	StackHeights   json.RawMessage
-	StackCount     int32
-	PeripheralName sql.NullString
-	PeripheralDesc string
+	ID                string
+	Locationid        string
+	Stackid           string
+	StackDisable      bool
+	StackHeights      json.RawMessage
+	StackCount        int32
+	PeripheralID      string
+	PeripheralName    sql.NullString
+	PeripheralDesc    string
+	GroupID           sql.NullString
+	LoadPriority      int32
+	OffloadPriority   int32
+	X                 float64
+	Y                 float64
+	PlacementPriority int32
 }
 
 // JOIN cargo_info ON stack.id = cargo_info.stack_config_id
@@ -234,8 +867,97 @@ func (q *Queries) OneStack(ctx context.Context, arg OneStackParams) (OneStackRow
 		&i.StackDisable,
 		&i.StackHeights,
 		&i.StackCount,
+		&i.PeripheralID,
 		&i.PeripheralName,
 		&i.PeripheralDesc,
+		&i.GroupID,
+		&i.LoadPriority,
+		&i.OffloadPriority,
+		&i.X,
+		&i.Y,
+		&i.PlacementPriority,
 	)
 	return i, err
 }
+
+const updateCargoLifecycle = `-- name: UpdateCargoLifecycle :execresult
+UPDATE cargo_info
+SET status = ?, owner = ?, stack_config_id = ?,
+    shelfConfigId = ?, updatedAt = CURRENT_TIMESTAMP(3)
+WHERE id = ? AND status = ?
+`
+
+type UpdateCargoLifecycleParams struct {
+	Status        CargoInfoStatus
+	Owner         CargoInfoOwner
+	StackConfigID sql.NullString
+	ShelfConfigID sql.NullString
+	ID            string
+	FromStatus    CargoInfoStatus
+}
+
+// 只有狀態還是 from 時才更新，避免蓋掉資料庫裡不同的狀態
+func (q *Queries) UpdateCargoLifecycle(ctx context.Context, arg UpdateCargoLifecycleParams) (sql.Result, error) {
+	return q.db.ExecContext(ctx, updateCargoLifecycle,
+		arg.Status,
+		arg.Owner,
+		arg.StackConfigID,
+		arg.ShelfConfigID,
+		arg.ID,
+		arg.FromStatus,
+	)
+}
+
+const updateShelfHasCargo = `-- name: UpdateShelfHasCargo :exec
+UPDATE ShelfConfig
+SET hasCargo = ?
+WHERE id = ?
+`
+
+type UpdateShelfHasCargoParams struct {
+	HasCargo bool
+	ID       string
+}
+
+func (q *Queries) UpdateShelfHasCargo(ctx context.Context, arg UpdateShelfHasCargoParams) error {
+	_, err := q.db.ExecContext(ctx, updateShelfHasCargo, arg.HasCargo, arg.ID)
+	return err
+}
+
+const upsertSimulationResult = `-- name: UpsertSimulationResult :exec
+INSERT INTO simulation_result (
+    id, amr_stat, startTime, endTime, total_cargos_carried,
+    mission_success_rate, total_mission_count, completed_missions
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+    startTime = VALUES(startTime),
+    endTime = VALUES(endTime),
+    total_cargos_carried = VALUES(total_cargos_carried),
+    total_mission_count = VALUES(total_mission_count),
+    completed_missions = VALUES(completed_missions)
+`
+
+type UpsertSimulationResultParams struct {
+	ID                 string
+	AmrStat            json.RawMessage
+	Starttime          string
+	Endtime            string
+	TotalCargosCarried int32
+	MissionSuccessRate int32
+	TotalMissionCount  int32
+	CompletedMissions  int32
+}
+
+func (q *Queries) UpsertSimulationResult(ctx context.Context, arg UpsertSimulationResultParams) error {
+	_, err := q.db.ExecContext(ctx, upsertSimulationResult,
+		arg.ID,
+		arg.AmrStat,
+		arg.Starttime,
+		arg.Endtime,
+		arg.TotalCargosCarried,
+		arg.MissionSuccessRate,
+		arg.TotalMissionCount,
+		arg.CompletedMissions,
+	)
+	return err
+}
@@ -0,0 +1,22 @@
+package infra
+
+import "context"
+
+// ActorSystem is reported when no actor was attached to the context
+const ActorSystem = "system"
+
+type actorKey struct{}
+
+// WithActor attaches who triggered an operation (operator, API client,
+// timeline...) to ctx, so that journals and history can record it
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor attached by WithActor, or ActorSystem
+func ActorFrom(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return ActorSystem
+}
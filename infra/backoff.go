@@ -0,0 +1,51 @@
+package infra
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Backoff computes exponential retry delays with jitter
+type Backoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	Multiplier float64
+	Jitter     float64 // 0.2 means the delay is randomised by ±20%
+}
+
+// DefaultBackoff returns the backoff used when none is configured
+func DefaultBackoff() Backoff {
+	return Backoff{
+		Base:       500 * time.Millisecond,
+		Max:        30 * time.Second,
+		Multiplier: 1.6,
+		Jitter:     0.2,
+	}
+}
+
+// Duration returns the delay before the given retry attempt (starting at 0)
+func (b Backoff) Duration(attempt int) time.Duration {
+	if b.Base <= 0 {
+		return 0
+	}
+
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+
+	delay := float64(b.Base) * math.Pow(multiplier, float64(attempt))
+	if b.Max > 0 && delay > float64(b.Max) {
+		delay = float64(b.Max)
+	}
+
+	if b.Jitter > 0 {
+		delay *= 1 + b.Jitter*(rand.Float64()*2-1)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	return time.Duration(delay)
+}
@@ -0,0 +1,294 @@
+package infra
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock abstracts time so a simulation can run faster than the wall clock
+// and tests can step time by hand. Durations passed in are simulated time.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+	Sleep(d time.Duration)
+}
+
+// Timer is the Clock counterpart of *time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// Ticker is the Clock counterpart of *time.Ticker
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// NewClock returns the real clock for scale 1 and a scaled clock otherwise
+func NewClock(scale float64) Clock {
+	if scale <= 0 || scale == 1 {
+		return NewRealClock()
+	}
+	return NewScaledClock(scale)
+}
+
+// ---- real ----
+
+type realClock struct{}
+
+// NewRealClock returns a Clock backed by the time package
+func NewRealClock() Clock { return realClock{} }
+
+func (realClock) Now() time.Time                         { return time.Now() }
+func (realClock) Since(t time.Time) time.Duration        { return time.Since(t) }
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+func (realClock) Sleep(d time.Duration)                  { time.Sleep(d) }
+
+func (realClock) NewTimer(d time.Duration) Timer   { return realTimer{time.NewTimer(d)} }
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTimer struct{ t *time.Timer }
+
+func (t realTimer) C() <-chan time.Time        { return t.t.C }
+func (t realTimer) Stop() bool                 { return t.t.Stop() }
+func (t realTimer) Reset(d time.Duration) bool { return t.t.Reset(d) }
+
+type realTicker struct{ t *time.Ticker }
+
+func (t realTicker) C() <-chan time.Time { return t.t.C }
+func (t realTicker) Stop()               { t.t.Stop() }
+
+// ---- scaled ----
+
+// ScaledClock runs scale times faster than the wall clock, starting from
+// the wall clock time it was created at. A 10x clock fires a 1 minute
+// timer after 6 real seconds and Now advances 10 minutes per real minute.
+type ScaledClock struct {
+	scale float64
+	start time.Time // wall clock at creation, also the simulated origin
+}
+
+func NewScaledClock(scale float64) *ScaledClock {
+	return &ScaledClock{scale: scale, start: time.Now()}
+}
+
+func (c *ScaledClock) Scale() float64 { return c.scale }
+
+// real converts a simulated duration to wall clock
+func (c *ScaledClock) real(d time.Duration) time.Duration {
+	return time.Duration(float64(d) / c.scale)
+}
+
+func (c *ScaledClock) Now() time.Time {
+	return c.start.Add(time.Duration(float64(time.Since(c.start)) * c.scale))
+}
+
+func (c *ScaledClock) Since(t time.Time) time.Duration { return c.Now().Sub(t) }
+
+func (c *ScaledClock) Sleep(d time.Duration) { time.Sleep(c.real(d)) }
+
+func (c *ScaledClock) After(d time.Duration) <-chan time.Time { return c.NewTimer(d).C() }
+
+func (c *ScaledClock) NewTimer(d time.Duration) Timer {
+	t := &scaledTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.t = time.AfterFunc(c.real(d), t.fire)
+	return t
+}
+
+type scaledTimer struct {
+	clock *ScaledClock
+	ch    chan time.Time
+	t     *time.Timer
+}
+
+func (t *scaledTimer) fire() {
+	select {
+	case t.ch <- t.clock.Now():
+	default:
+	}
+}
+
+func (t *scaledTimer) C() <-chan time.Time        { return t.ch }
+func (t *scaledTimer) Stop() bool                 { return t.t.Stop() }
+func (t *scaledTimer) Reset(d time.Duration) bool { return t.t.Reset(t.clock.real(d)) }
+
+func (c *ScaledClock) NewTicker(d time.Duration) Ticker {
+	t := &scaledTicker{
+		ch:   make(chan time.Time, 1),
+		t:    time.NewTicker(c.real(d)),
+		done: make(chan struct{}),
+	}
+	go func() {
+		for {
+			select {
+			case <-t.done:
+				return
+			case <-t.t.C:
+				select {
+				case t.ch <- c.Now():
+				default:
+				}
+			}
+		}
+	}()
+	return t
+}
+
+type scaledTicker struct {
+	ch   chan time.Time
+	t    *time.Ticker
+	done chan struct{}
+	once sync.Once
+}
+
+func (t *scaledTicker) C() <-chan time.Time { return t.ch }
+
+func (t *scaledTicker) Stop() {
+	t.once.Do(func() {
+		t.t.Stop()
+		close(t.done)
+	})
+}
+
+// ---- manual ----
+
+// ManualClock only moves when Advance or Set is called, which fires every
+// timer and ticker that became due in deadline order. Use it for
+// deterministic tests and step-by-step simulations.
+type ManualClock struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*manualWaiter
+}
+
+type manualWaiter struct {
+	clock    *ManualClock
+	deadline time.Time
+	period   time.Duration // > 0 for tickers
+	ch       chan time.Time
+	active   bool
+}
+
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *ManualClock) Since(t time.Time) time.Duration { return c.Now().Sub(t) }
+
+// Advance moves the clock forward by d and fires what became due
+func (c *ManualClock) Advance(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set moves the clock to t and fires what became due. Moving backwards
+// only changes Now.
+func (c *ManualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for {
+		w := c.nextDue(t)
+		if w == nil {
+			break
+		}
+		c.now = w.deadline
+		select {
+		case w.ch <- w.deadline:
+		default:
+		}
+		if w.period > 0 {
+			w.deadline = w.deadline.Add(w.period)
+		} else {
+			c.remove(w)
+		}
+	}
+	c.now = t
+}
+
+// Waiters reports how many timers and tickers are pending, so a test can
+// wait until the code under test has armed its timer before advancing
+func (c *ManualClock) Waiters() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.waiters)
+}
+
+// nextDue returns the earliest waiter due at or before t; caller holds mu
+func (c *ManualClock) nextDue(t time.Time) *manualWaiter {
+	sort.SliceStable(c.waiters, func(i, j int) bool {
+		return c.waiters[i].deadline.Before(c.waiters[j].deadline)
+	})
+	if len(c.waiters) == 0 || c.waiters[0].deadline.After(t) {
+		return nil
+	}
+	return c.waiters[0]
+}
+
+// remove drops w from the waiters; caller holds mu
+func (c *ManualClock) remove(w *manualWaiter) bool {
+	if !w.active {
+		return false
+	}
+	w.active = false
+	for i, o := range c.waiters {
+		if o == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (c *ManualClock) add(d, period time.Duration) *manualWaiter {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	w := &manualWaiter{clock: c, deadline: c.now.Add(d), period: period, ch: make(chan time.Time, 1), active: true}
+	c.waiters = append(c.waiters, w)
+	return w
+}
+
+func (c *ManualClock) NewTimer(d time.Duration) Timer { return c.add(d, 0) }
+
+func (c *ManualClock) NewTicker(d time.Duration) Ticker { return manualTicker{c.add(d, d)} }
+
+func (c *ManualClock) After(d time.Duration) <-chan time.Time { return c.NewTimer(d).C() }
+
+// Sleep blocks until another goroutine advances the clock past d
+func (c *ManualClock) Sleep(d time.Duration) { <-c.After(d) }
+
+func (w *manualWaiter) C() <-chan time.Time { return w.ch }
+
+func (w *manualWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+func (w *manualWaiter) Reset(d time.Duration) bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+
+	wasActive := w.clock.remove(w)
+	w.deadline = w.clock.now.Add(d)
+	w.active = true
+	w.clock.waiters = append(w.clock.waiters, w)
+	return wasActive
+}
+
+type manualTicker struct{ w *manualWaiter }
+
+func (t manualTicker) C() <-chan time.Time { return t.w.ch }
+func (t manualTicker) Stop()               { t.w.Stop() }
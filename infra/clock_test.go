@@ -0,0 +1,224 @@
+package infra
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// fired returns what ch received without blocking
+func fired(ch <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-ch:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestManualClockTimers(t *testing.T) {
+	tests := []struct {
+		name    string
+		timers  []time.Duration
+		steps   []time.Duration // Advance calls, in order
+		want    []bool          // whether each timer fired
+		waiters int
+	}{
+		{
+			name:    "nothing due",
+			timers:  []time.Duration{time.Second},
+			steps:   []time.Duration{999 * time.Millisecond},
+			want:    []bool{false},
+			waiters: 1,
+		},
+		{
+			name:    "exactly on the deadline",
+			timers:  []time.Duration{time.Second},
+			steps:   []time.Duration{time.Second},
+			want:    []bool{true},
+			waiters: 0,
+		},
+		{
+			name:    "one advance fires every due timer",
+			timers:  []time.Duration{3 * time.Second, time.Second, 2 * time.Second},
+			steps:   []time.Duration{5 * time.Second},
+			want:    []bool{true, true, true},
+			waiters: 0,
+		},
+		{
+			name:    "small steps fire timers one by one",
+			timers:  []time.Duration{2 * time.Second, 4 * time.Second},
+			steps:   []time.Duration{time.Second, time.Second, time.Second},
+			want:    []bool{true, false},
+			waiters: 1,
+		},
+		{
+			name:    "zero duration is due immediately",
+			timers:  []time.Duration{0},
+			steps:   []time.Duration{0},
+			want:    []bool{true},
+			waiters: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewManualClock(epoch)
+
+			timers := make([]Timer, len(tt.timers))
+			for i, d := range tt.timers {
+				timers[i] = c.NewTimer(d)
+			}
+
+			var total time.Duration
+			for _, d := range tt.steps {
+				c.Advance(d)
+				total += d
+			}
+			if got := c.Now(); !got.Equal(epoch.Add(total)) {
+				t.Errorf("Now = %v, want %v", got, epoch.Add(total))
+			}
+
+			for i, timer := range timers {
+				at, ok := fired(timer.C())
+				if ok != tt.want[i] {
+					t.Errorf("timer %d fired = %v, want %v", i, ok, tt.want[i])
+				}
+				// the deadline is sent, not the time Advance moved to
+				if ok && !at.Equal(epoch.Add(tt.timers[i])) {
+					t.Errorf("timer %d fired at %v, want %v", i, at, epoch.Add(tt.timers[i]))
+				}
+			}
+			if got := c.Waiters(); got != tt.waiters {
+				t.Errorf("Waiters = %d, want %d", got, tt.waiters)
+			}
+		})
+	}
+}
+
+// Set fires everything due, each with its own deadline; a ticker whose
+// buffer is full drops the extra tick
+func TestManualClockSetOrdering(t *testing.T) {
+	c := NewManualClock(epoch)
+
+	ticker := c.NewTicker(time.Second)
+	defer ticker.Stop()
+	timer := c.NewTimer(1500 * time.Millisecond)
+
+	c.Set(epoch.Add(2 * time.Second))
+
+	// the ticker buffers one tick, so the one at 2s is dropped
+	if at, ok := fired(timer.C()); !ok || !at.Equal(epoch.Add(1500*time.Millisecond)) {
+		t.Errorf("timer = %v %v, want fired at 1.5s", at, ok)
+	}
+	if at, ok := fired(ticker.C()); !ok || !at.Equal(epoch.Add(time.Second)) {
+		t.Errorf("ticker = %v %v, want fired at 1s", at, ok)
+	}
+	if !c.Now().Equal(epoch.Add(2 * time.Second)) {
+		t.Errorf("Now = %v, want 2s", c.Now())
+	}
+
+	// moving backwards only changes Now
+	c.Set(epoch)
+	if !c.Now().Equal(epoch) {
+		t.Errorf("Now after moving backwards = %v, want %v", c.Now(), epoch)
+	}
+	if _, ok := fired(ticker.C()); ok {
+		t.Error("ticker fired after moving backwards")
+	}
+}
+
+func TestManualClockTickerRearms(t *testing.T) {
+	c := NewManualClock(epoch)
+	ticker := c.NewTicker(time.Second)
+
+	for i := 1; i <= 3; i++ {
+		c.Advance(time.Second)
+		at, ok := fired(ticker.C())
+		if !ok || !at.Equal(epoch.Add(time.Duration(i)*time.Second)) {
+			t.Fatalf("tick %d = %v %v, want %v", i, at, ok, epoch.Add(time.Duration(i)*time.Second))
+		}
+		if got := c.Waiters(); got != 1 {
+			t.Fatalf("Waiters after tick %d = %d, want 1", i, got)
+		}
+	}
+
+	ticker.Stop()
+	if got := c.Waiters(); got != 0 {
+		t.Errorf("Waiters after Stop = %d, want 0", got)
+	}
+	c.Advance(time.Second)
+	if _, ok := fired(ticker.C()); ok {
+		t.Error("ticker fired after Stop")
+	}
+}
+
+func TestManualClockStopReset(t *testing.T) {
+	c := NewManualClock(epoch)
+	timer := c.NewTimer(time.Second)
+
+	if !timer.Stop() {
+		t.Error("Stop on a pending timer = false, want true")
+	}
+	if timer.Stop() {
+		t.Error("second Stop = true, want false")
+	}
+	c.Advance(2 * time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("stopped timer fired")
+	}
+
+	// Reset counts from the current time
+	if timer.Reset(time.Second) {
+		t.Error("Reset on a stopped timer = true, want false")
+	}
+	if got := c.Waiters(); got != 1 {
+		t.Errorf("Waiters after Reset = %d, want 1", got)
+	}
+	if !timer.Reset(3 * time.Second) {
+		t.Error("Reset on a pending timer = false, want true")
+	}
+	if got := c.Waiters(); got != 1 {
+		t.Errorf("Waiters after second Reset = %d, want 1", got)
+	}
+
+	c.Advance(2 * time.Second)
+	if _, ok := fired(timer.C()); ok {
+		t.Fatal("timer fired before the reset deadline")
+	}
+	c.Advance(time.Second)
+	at, ok := fired(timer.C())
+	if !ok || !at.Equal(epoch.Add(5*time.Second)) {
+		t.Errorf("timer = %v %v, want fired at 5s", at, ok)
+	}
+	if got := c.Waiters(); got != 0 {
+		t.Errorf("Waiters after firing = %d, want 0", got)
+	}
+}
+
+func TestManualClockSleep(t *testing.T) {
+	c := NewManualClock(epoch)
+
+	done := make(chan struct{})
+	go func() {
+		c.Sleep(time.Minute)
+		close(done)
+	}()
+
+	// wait for Sleep to arm its timer before advancing
+	deadline := time.Now().Add(time.Second)
+	for c.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Sleep never armed a timer")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	c.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Sleep did not return after Advance")
+	}
+}
@@ -8,21 +8,43 @@ import (
 // EventHandler is a function type that handles events
 type EventHandler func(data interface{})
 
+// subscription pairs a handler with its ID so it can be removed again
+type subscription struct {
+	id      int
+	handler EventHandler
+}
+
 // EventBus manages event subscriptions and publishing
 type EventBus struct {
-	mu         sync.RWMutex
-	handlers   map[string][]EventHandler
-	handlerIDs map[string]map[int]EventHandler
-	nextID     int
+	mu       sync.RWMutex
+	handlers map[string][]subscription
+	nextID   int
+	metrics  Metrics
 }
 
 // New creates a new EventBus instance
 func New() *EventBus {
 	return &EventBus{
-		handlers:   make(map[string][]EventHandler),
-		handlerIDs: make(map[string]map[int]EventHandler),
-		nextID:     0,
+		handlers: make(map[string][]subscription),
+		nextID:   0,
+		metrics:  NopMetrics{},
+	}
+}
+
+// SetMetrics sets where publish and drop counts are reported
+func (eb *EventBus) SetMetrics(m Metrics) {
+	eb.mu.Lock()
+	defer eb.mu.Unlock()
+	eb.metrics = m
+}
+
+// record reports a publish, or a drop when nobody is subscribed
+func (eb *EventBus) record(event string, handlers int, m Metrics) {
+	if handlers == 0 {
+		m.EventDropped(event)
+		return
 	}
+	m.EventPublished(event, handlers)
 }
 
 // Subscribe registers a handler for a specific event
@@ -31,14 +53,8 @@ func (eb *EventBus) Subscribe(event string, handler EventHandler) int {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	eb.handlers[event] = append(eb.handlers[event], handler)
-
-	if eb.handlerIDs[event] == nil {
-		eb.handlerIDs[event] = make(map[int]EventHandler)
-	}
-
 	id := eb.nextID
-	eb.handlerIDs[event][id] = handler
+	eb.handlers[event] = append(eb.handlers[event], subscription{id: id, handler: handler})
 	eb.nextID++
 
 	return id
@@ -49,38 +65,35 @@ func (eb *EventBus) Unsubscribe(event string, id int) error {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	eventHandlers, exists := eb.handlerIDs[event]
+	subs, exists := eb.handlers[event]
 	if !exists {
 		return fmt.Errorf("event '%s' not found", event)
 	}
 
-	handler, exists := eventHandlers[id]
-	if !exists {
-		return fmt.Errorf("subscription ID %d not found for event '%s'", id, event)
-	}
-
-	delete(eventHandlers, id)
-
-	// Remove from handlers slice
-	handlers := eb.handlers[event]
-	for i, h := range handlers {
-		if &h == &handler {
-			eb.handlers[event] = append(handlers[:i], handlers[i+1:]...)
-			break
+	for i, s := range subs {
+		if s.id == id {
+			// Copy so a Publish iterating the old slice is not affected
+			remaining := make([]subscription, 0, len(subs)-1)
+			remaining = append(remaining, subs[:i]...)
+			eb.handlers[event] = append(remaining, subs[i+1:]...)
+			return nil
 		}
 	}
 
-	return nil
+	return fmt.Errorf("subscription ID %d not found for event '%s'", id, event)
 }
 
 // Publish sends an event to all subscribed handlers
 func (eb *EventBus) Publish(event string, data interface{}) {
 	eb.mu.RLock()
 	handlers := eb.handlers[event]
+	m := eb.metrics
 	eb.mu.RUnlock()
 
-	for _, handler := range handlers {
-		go handler(data)
+	eb.record(event, len(handlers), m)
+
+	for _, s := range handlers {
+		go s.handler(data)
 	}
 }
 
@@ -88,10 +101,13 @@ func (eb *EventBus) Publish(event string, data interface{}) {
 func (eb *EventBus) PublishSync(event string, data interface{}) {
 	eb.mu.RLock()
 	handlers := eb.handlers[event]
+	m := eb.metrics
 	eb.mu.RUnlock()
 
-	for _, handler := range handlers {
-		handler(data)
+	eb.record(event, len(handlers), m)
+
+	for _, s := range handlers {
+		s.handler(data)
 	}
 }
 
@@ -101,7 +117,6 @@ func (eb *EventBus) Clear(event string) {
 	defer eb.mu.Unlock()
 
 	delete(eb.handlers, event)
-	delete(eb.handlerIDs, event)
 }
 
 // ClearAll removes all handlers for all events
@@ -109,6 +124,5 @@ func (eb *EventBus) ClearAll() {
 	eb.mu.Lock()
 	defer eb.mu.Unlock()
 
-	eb.handlers = make(map[string][]EventHandler)
-	eb.handlerIDs = make(map[string]map[int]EventHandler)
+	eb.handlers = make(map[string][]subscription)
 }
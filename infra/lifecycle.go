@@ -0,0 +1,156 @@
+package infra
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// StopFunc releases a resource during shutdown
+type StopFunc func(ctx context.Context) error
+
+type stopHook struct {
+	name string
+	fn   StopFunc
+}
+
+// Lifecycle owns the root context of the service. It cancels the context on
+// SIGINT/SIGTERM or when a supervised goroutine fails, then waits for the
+// goroutines and runs the stop hooks within the shutdown deadline.
+type Lifecycle struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	timeout time.Duration
+	logger  Logger
+
+	wg    sync.WaitGroup
+	mu    sync.Mutex
+	hooks []stopHook
+	errs  []error
+}
+
+// NewLifecycle creates a lifecycle listening for SIGINT and SIGTERM
+func NewLifecycle(shutdownTimeout time.Duration, logger Logger) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	if logger != nil {
+		logger = logger.With(FieldComponent, "lifecycle")
+	}
+
+	l := &Lifecycle{
+		ctx:     ctx,
+		cancel:  cancel,
+		timeout: shutdownTimeout,
+		logger:  logger,
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		defer signal.Stop(sigCh)
+
+		select {
+		case sig := <-sigCh:
+			if l.logger != nil {
+				l.logger.Info("received %s, shutting down", sig)
+			}
+			l.cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return l
+}
+
+// Context returns the root context, cancelled when shutdown starts
+func (l *Lifecycle) Context() context.Context {
+	return l.ctx
+}
+
+// Go runs fn in a supervised goroutine. If fn returns an error before
+// shutdown, the whole service is shut down.
+func (l *Lifecycle) Go(name string, fn func(ctx context.Context) error) {
+	l.wg.Add(1)
+
+	go func() {
+		defer l.wg.Done()
+
+		err := fn(l.ctx)
+		if err == nil || errors.Is(err, context.Canceled) {
+			return
+		}
+
+		if l.ctx.Err() == nil {
+			if l.logger != nil {
+				l.logger.Error("%s stopped unexpectedly: %v", name, err)
+			}
+			l.addErr(fmt.Errorf("%s: %w", name, err))
+			l.cancel()
+		}
+	}()
+}
+
+// OnStop registers a hook run after the goroutines have finished.
+// Hooks run in reverse registration order.
+func (l *Lifecycle) OnStop(name string, fn StopFunc) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.hooks = append(l.hooks, stopHook{name: name, fn: fn})
+}
+
+// Shutdown starts the shutdown sequence
+func (l *Lifecycle) Shutdown() {
+	l.cancel()
+}
+
+// Wait blocks until shutdown starts, then waits for the goroutines and runs
+// the stop hooks. It returns the errors of failed goroutines and hooks.
+func (l *Lifecycle) Wait() error {
+	<-l.ctx.Done()
+
+	deadline, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		l.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-deadline.Done():
+		l.addErr(fmt.Errorf("goroutines still running after %v", l.timeout))
+	}
+
+	l.mu.Lock()
+	hooks := l.hooks
+	l.mu.Unlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h := hooks[i]
+		if err := h.fn(deadline); err != nil {
+			l.addErr(fmt.Errorf("stop %s: %w", h.name, err))
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.logger != nil {
+		l.logger.Info("shutdown complete")
+	}
+	return errors.Join(l.errs...)
+}
+
+func (l *Lifecycle) addErr(err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errs = append(l.errs, err)
+}
@@ -0,0 +1,126 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// Common field keys for contextual logging
+const (
+	FieldLocationID = "locationId"
+	FieldScriptID   = "scriptId"
+	FieldRequestID  = "requestId"
+	FieldTopic      = "topic"
+	FieldComponent  = "component"
+	FieldActor      = "actor"
+	FieldTimelineID = "timelineId"
+)
+
+// Logger interface for logging. Messages are printf-style; structured
+// fields are attached with With and passed as key/value pairs.
+type Logger interface {
+	Debug(msg string, args ...interface{})
+	Info(msg string, args ...interface{})
+	Warn(msg string, args ...interface{})
+	Error(msg string, args ...interface{})
+	With(fields ...interface{}) Logger
+}
+
+// DefaultLogger is a simple console logger
+type DefaultLogger struct {
+	fields string
+}
+
+func (l *DefaultLogger) Debug(msg string, args ...interface{}) {
+	fmt.Printf("[DEBUG] "+msg+l.fields+"\n", args...)
+}
+
+func (l *DefaultLogger) Info(msg string, args ...interface{}) {
+	fmt.Printf("[INFO] "+msg+l.fields+"\n", args...)
+}
+
+func (l *DefaultLogger) Warn(msg string, args ...interface{}) {
+	fmt.Printf("[WARN] "+msg+l.fields+"\n", args...)
+}
+
+func (l *DefaultLogger) Error(msg string, args ...interface{}) {
+	fmt.Printf("[ERROR] "+msg+l.fields+"\n", args...)
+}
+
+func (l *DefaultLogger) With(fields ...interface{}) Logger {
+	var b strings.Builder
+	b.WriteString(l.fields)
+	for i := 0; i+1 < len(fields); i += 2 {
+		// fields 會接在格式字串後面，% 要跳脫
+		b.WriteString(strings.ReplaceAll(fmt.Sprintf(" %v=%v", fields[i], fields[i+1]), "%", "%%"))
+	}
+	return &DefaultLogger{fields: b.String()}
+}
+
+// SlogLogger implements Logger on top of log/slog
+type SlogLogger struct {
+	l *slog.Logger
+}
+
+// NewSlogLogger creates a logger writing to w. level is one of
+// debug, info, warn, error; format is json or text.
+func NewSlogLogger(w io.Writer, level string, format string) (*SlogLogger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q", level)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "json", "":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q (want json or text)", format)
+	}
+
+	return &SlogLogger{l: slog.New(h)}, nil
+}
+
+// Slog returns the underlying slog.Logger, e.g. for slog.SetDefault
+func (s *SlogLogger) Slog() *slog.Logger {
+	return s.l
+}
+
+func (s *SlogLogger) Debug(msg string, args ...interface{}) {
+	s.log(slog.LevelDebug, msg, args)
+}
+
+func (s *SlogLogger) Info(msg string, args ...interface{}) {
+	s.log(slog.LevelInfo, msg, args)
+}
+
+func (s *SlogLogger) Warn(msg string, args ...interface{}) {
+	s.log(slog.LevelWarn, msg, args)
+}
+
+func (s *SlogLogger) Error(msg string, args ...interface{}) {
+	s.log(slog.LevelError, msg, args)
+}
+
+func (s *SlogLogger) With(fields ...interface{}) Logger {
+	return &SlogLogger{l: s.l.With(fields...)}
+}
+
+func (s *SlogLogger) log(level slog.Level, msg string, args []interface{}) {
+	ctx := context.Background()
+	if !s.l.Enabled(ctx, level) {
+		return
+	}
+
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	s.l.Log(ctx, level, msg)
+}
@@ -0,0 +1,22 @@
+package infra
+
+import "time"
+
+// Metrics receives instrumentation from the buses.
+// Implementations must be safe for concurrent use.
+type Metrics interface {
+	EventPublished(event string, handlers int)
+	EventDropped(event string)
+	RequestCompleted(topic string, duration time.Duration, err error)
+	RequestTimeout(topic string)
+	RequestRetry(topic string)
+}
+
+// NopMetrics discards all measurements
+type NopMetrics struct{}
+
+func (NopMetrics) EventPublished(string, int)                    {}
+func (NopMetrics) EventDropped(string)                           {}
+func (NopMetrics) RequestCompleted(string, time.Duration, error) {}
+func (NopMetrics) RequestTimeout(string)                         {}
+func (NopMetrics) RequestRetry(string)                           {}
@@ -2,6 +2,7 @@ package infra
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -46,34 +47,16 @@ type RequestResponseBus struct {
 	nextID           int
 	timeout          time.Duration
 	logger           Logger
-}
-
-// Logger interface for logging
-type Logger interface {
-	Debug(msg string, args ...interface{})
-	Info(msg string, args ...interface{})
-	Error(msg string, args ...interface{})
-}
-
-// DefaultLogger is a simple console logger
-type DefaultLogger struct{}
-
-func (l *DefaultLogger) Debug(msg string, args ...interface{}) {
-	fmt.Printf("[DEBUG] "+msg+"\n", args...)
-}
-
-func (l *DefaultLogger) Info(msg string, args ...interface{}) {
-	fmt.Printf("[INFO] "+msg+"\n", args...)
-}
-
-func (l *DefaultLogger) Error(msg string, args ...interface{}) {
-	fmt.Printf("[ERROR] "+msg+"\n", args...)
+	metrics          Metrics
+	clock            Clock
 }
 
 // Config holds configuration for the request-response bus
 type Config struct {
 	DefaultTimeout time.Duration
 	Logger         Logger
+	Metrics        Metrics
+	Clock          Clock // defaults to the real clock
 }
 
 // New creates a new RequestResponseBus with default configuration
@@ -86,6 +69,13 @@ func NewReqBus() *RequestResponseBus {
 
 // NewWithConfig creates a new RequestResponseBus with custom configuration
 func NewWithConfig(config Config) *RequestResponseBus {
+	if config.Metrics == nil {
+		config.Metrics = NopMetrics{}
+	}
+	if config.Clock == nil {
+		config.Clock = NewRealClock()
+	}
+
 	return &RequestResponseBus{
 		handlers:         make(map[string]*RequestSubscription),
 		pendingRequests:  make(map[string]chan Response),
@@ -93,6 +83,8 @@ func NewWithConfig(config Config) *RequestResponseBus {
 		nextID:           0,
 		timeout:          config.DefaultTimeout,
 		logger:           config.Logger,
+		metrics:          config.Metrics,
+		clock:            config.Clock,
 	}
 }
 
@@ -111,7 +103,7 @@ func (rb *RequestResponseBus) RegisterHandler(topic string, handler RequestHandl
 	rb.nextID++
 
 	if rb.logger != nil {
-		rb.logger.Debug("Registered handler for topic: %s (ID: %d)", topic, sub.ID)
+		rb.logger.With(FieldTopic, topic).Debug("Registered handler for topic: %s (ID: %d)", topic, sub.ID)
 	}
 
 	return sub.ID
@@ -129,7 +121,7 @@ func (rb *RequestResponseBus) UnregisterHandler(topic string) error {
 	delete(rb.handlers, topic)
 
 	if rb.logger != nil {
-		rb.logger.Debug("Unregistered handler for topic: %s", topic)
+		rb.logger.With(FieldTopic, topic).Debug("Unregistered handler for topic: %s", topic)
 	}
 
 	return nil
@@ -151,7 +143,7 @@ func (rb *RequestResponseBus) RequestWithTimeout(ctx context.Context, topic stri
 	req := Request{
 		Topic:     topic,
 		Data:      data,
-		Timestamp: time.Now(),
+		Timestamp: rb.clock.Now(),
 		ID:        fmt.Sprintf("%s-%d", topic, time.Now().UnixNano()),
 	}
 
@@ -164,7 +156,8 @@ func (rb *RequestResponseBus) RequestWithTimeout(ctx context.Context, topic stri
 		return nil, fmt.Errorf("no handler registered for topic '%s'", topic)
 	}
 
-	// Create response channel
+	// Create response channel. It is buffered and never closed, so a handler
+	// that finishes after a timeout can still send without blocking or panicking
 	responseChan := make(chan Response, 1)
 
 	rb.mu.Lock()
@@ -176,13 +169,22 @@ func (rb *RequestResponseBus) RequestWithTimeout(ctx context.Context, topic stri
 		rb.mu.Lock()
 		delete(rb.pendingRequests, req.ID)
 		rb.mu.Unlock()
-		close(responseChan)
 	}()
 
+	// Requests made while serving an HTTP request log under its X-Request-ID
+	requestID := RequestIDFrom(ctx)
+	if requestID == "" {
+		requestID = req.ID
+	}
+	var logger Logger
+	if rb.logger != nil {
+		logger = rb.logger.With(FieldTopic, topic, FieldRequestID, requestID)
+	}
+
 	// Execute handler in goroutine
 	go func() {
-		if rb.logger != nil {
-			rb.logger.Debug("Processing request: %s (ID: %s)", req.Topic, req.ID)
+		if logger != nil {
+			logger.Debug("Processing request: %s (ID: %s)", req.Topic, req.ID)
 		}
 
 		data, err := handler.Handler(ctx, req)
@@ -191,31 +193,44 @@ func (rb *RequestResponseBus) RequestWithTimeout(ctx context.Context, topic stri
 			RequestID: req.ID,
 			Data:      data,
 			Error:     err,
-			Timestamp: time.Now(),
+			Timestamp: rb.clock.Now(),
 		}
 
 		// Send response
 		select {
 		case responseChan <- response:
-		case <-time.After(1 * time.Second):
-			if rb.logger != nil {
-				rb.logger.Error("Response channel timeout for request %s", req.ID)
+		case <-rb.clock.After(1 * time.Second):
+			if logger != nil {
+				logger.Error("Response channel timeout for request %s", req.ID)
 			}
 		}
 	}()
 
-	// Wait for response or timeout
-	ctx, cancel := context.WithTimeout(ctx, timeout)
-	defer cancel()
+	// Wait for response or timeout, measured on the bus clock
+	timer := rb.clock.NewTimer(timeout)
+	defer timer.Stop()
 
 	select {
 	case response := <-responseChan:
-		if rb.logger != nil {
-			rb.logger.Debug("Received response for request: %s", req.ID)
+		if logger != nil {
+			logger.Debug("Received response for request: %s", req.ID)
 		}
+		rb.metrics.RequestCompleted(topic, rb.clock.Since(req.Timestamp), response.Error)
 		return &response, nil
+	case <-timer.C():
+		err := fmt.Errorf("request timeout after %v: %w", timeout, context.DeadlineExceeded)
+		rb.metrics.RequestTimeout(topic)
+		rb.metrics.RequestCompleted(topic, rb.clock.Since(req.Timestamp), err)
+		return nil, err
 	case <-ctx.Done():
-		return nil, fmt.Errorf("request timeout after %v: %w", timeout, ctx.Err())
+		// Only a deadline counts as a timeout; a cancelled caller just gave up
+		err := fmt.Errorf("request cancelled: %w", ctx.Err())
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("request timeout: %w", ctx.Err())
+			rb.metrics.RequestTimeout(topic)
+		}
+		rb.metrics.RequestCompleted(topic, rb.clock.Since(req.Timestamp), err)
+		return nil, err
 	}
 }
 
@@ -231,7 +246,7 @@ func (rb *RequestResponseBus) RequestAsyncWithTimeout(ctx context.Context, topic
 		if err != nil {
 			callback(ctx, Response{
 				Error:     err,
-				Timestamp: time.Now(),
+				Timestamp: rb.clock.Now(),
 			})
 			return
 		}
@@ -253,7 +268,7 @@ func (rb *RequestResponseBus) Broadcast(topic string, data interface{}) ([]*Resp
 	req := Request{
 		Topic:     topic,
 		Data:      data,
-		Timestamp: time.Now(),
+		Timestamp: rb.clock.Now(),
 		ID:        fmt.Sprintf("%s-%d", topic, time.Now().UnixNano()),
 	}
 
@@ -264,7 +279,7 @@ func (rb *RequestResponseBus) Broadcast(topic string, data interface{}) ([]*Resp
 		RequestID: req.ID,
 		Data:      responseData,
 		Error:     err,
-		Timestamp: time.Now(),
+		Timestamp: rb.clock.Now(),
 	}
 
 	return []*Response{response}, nil
@@ -395,9 +410,10 @@ func (rb *RequestResponseBus) RequestWithOptions(ctx context.Context, topic stri
 	for i := 0; i < retries; i++ {
 		if i > 0 {
 			if rb.logger != nil {
-				rb.logger.Debug("Retrying request to %s (attempt %d/%d)", topic, i+1, retries)
+				rb.logger.With(FieldTopic, topic).Debug("Retrying request to %s (attempt %d/%d)", topic, i+1, retries)
 			}
-			time.Sleep(opts.RetryDelay)
+			rb.metrics.RequestRetry(topic)
+			rb.clock.Sleep(opts.RetryDelay)
 		}
 
 		response, err := rb.RequestWithTimeout(ctx, topic, data, timeout)
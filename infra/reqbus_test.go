@@ -0,0 +1,109 @@
+package infra
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countMetrics counts request outcomes per topic
+type countMetrics struct {
+	NopMetrics
+
+	mu        sync.Mutex
+	completed int
+	timeouts  int
+}
+
+func (m *countMetrics) RequestCompleted(string, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.completed++
+}
+
+func (m *countMetrics) RequestTimeout(string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.timeouts++
+}
+
+func (m *countMetrics) counts() (completed, timeouts int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.completed, m.timeouts
+}
+
+func TestRequestTimeouts(t *testing.T) {
+	expired := func() context.Context {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		t.Cleanup(cancel)
+		return ctx
+	}
+	cancelled := func() context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		timeout  time.Duration
+		want     error
+		timeouts int
+	}{
+		{"bus timeout", context.Background(), 10 * time.Millisecond, context.DeadlineExceeded, 1},
+		{"caller deadline", expired(), time.Minute, context.DeadlineExceeded, 1},
+		{"caller cancelled", cancelled(), time.Minute, context.Canceled, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &countMetrics{}
+			rb := NewWithConfig(Config{DefaultTimeout: tt.timeout, Metrics: m})
+
+			// The handler finishes after the caller gave up; its late reply must be dropped
+			release := make(chan struct{})
+			done := make(chan struct{})
+			rb.RegisterHandler("slow", func(ctx context.Context, req Request) (interface{}, error) {
+				defer close(done)
+				<-release
+				return "late", nil
+			})
+
+			_, err := rb.RequestWithContext(tt.ctx, "slow", nil)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Request = %v, want %v", err, tt.want)
+			}
+			close(release)
+			<-done
+
+			if completed, timeouts := m.counts(); completed != 1 || timeouts != tt.timeouts {
+				t.Fatalf("metrics = %d completed, %d timeouts; want 1 and %d", completed, timeouts, tt.timeouts)
+			}
+		})
+	}
+}
+
+func TestRequestLogsCallerRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := NewSlogLogger(&buf, "debug", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rb := NewWithConfig(Config{DefaultTimeout: time.Second, Logger: logger})
+	rb.RegisterHandler("echo", func(ctx context.Context, req Request) (interface{}, error) {
+		return req.Data, nil
+	})
+
+	ctx := WithRequestID(context.Background(), "req-abc")
+	if _, err := rb.RequestWithContext(ctx, "echo", "hi"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), FieldRequestID+"=req-abc") {
+		t.Fatalf("log does not carry the caller's request ID:\n%s", buf.String())
+	}
+}
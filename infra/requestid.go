@@ -0,0 +1,34 @@
+package infra
+
+import "context"
+
+type requestIDKey struct{}
+
+type loggerKey struct{}
+
+// WithRequestID attaches the ID of the incoming request (e.g. the HTTP
+// X-Request-ID header) to ctx, so that everything it triggers can be
+// correlated in the logs
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFrom returns the ID attached by WithRequestID, or "" when none was
+func RequestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// WithLogger attaches a request-scoped logger, usually one carrying
+// FieldRequestID, to ctx
+func WithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, loggerKey{}, logger)
+}
+
+// LoggerFrom returns the logger attached by WithLogger, or fallback
+func LoggerFrom(ctx context.Context, fallback Logger) Logger {
+	if logger, ok := ctx.Value(loggerKey{}).(Logger); ok && logger != nil {
+		return logger
+	}
+	return fallback
+}
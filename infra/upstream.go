@@ -0,0 +1,269 @@
+package infra
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Events published on the EventBus when the upstream connection changes state
+const (
+	EventUpstreamConnected    = "upstream.connected"
+	EventUpstreamDisconnected = "upstream.disconnected"
+)
+
+// TLSConfig holds optional TLS / mTLS settings loaded from files
+type TLSConfig struct {
+	Enabled    bool
+	CAFile     string
+	CertFile   string // CertFile and KeyFile together enable mTLS
+	KeyFile    string
+	ServerName string
+}
+
+// UpstreamConfig holds configuration for the upstream gRPC client.
+// Keepalive pings are also sent without an active stream, so KeepaliveTime
+// must not be shorter than the server's keepalive.EnforcementPolicy MinTime
+// (5m on a stock grpc-go server), otherwise the server closes the connection
+// with GOAWAY too_many_pings.
+type UpstreamConfig struct {
+	Target           string
+	Backoff          Backoff
+	KeepaliveTime    time.Duration
+	KeepaliveTimeout time.Duration
+	TLS              TLSConfig
+}
+
+// DefaultUpstreamConfig returns the configuration used when none is provided
+func DefaultUpstreamConfig() UpstreamConfig {
+	return UpstreamConfig{
+		Target:           "localhost:50051",
+		Backoff:          DefaultBackoff(),
+		KeepaliveTime:    5 * time.Minute,
+		KeepaliveTimeout: 20 * time.Second,
+	}
+}
+
+// UpstreamState is the payload of the upstream connection events
+type UpstreamState struct {
+	Target    string
+	State     string
+	Connected bool
+	Timestamp time.Time
+}
+
+// UpstreamStats holds counters about the upstream connection
+type UpstreamStats struct {
+	Connected     bool
+	Disconnects   uint64
+	SessionErrors uint64
+	LastError     string
+}
+
+// UpstreamSession runs one session (e.g. one stream) on the shared connection.
+// It should block until the session ends and return the reason.
+type UpstreamSession func(ctx context.Context, conn *grpc.ClientConn) error
+
+// UpstreamClient owns a single grpc.ClientConn to the upstream server and
+// re-runs sessions on it with exponential backoff
+type UpstreamClient struct {
+	cfg    UpstreamConfig
+	conn   *grpc.ClientConn
+	bus    *EventBus
+	logger Logger
+
+	mu    sync.RWMutex
+	stats UpstreamStats
+}
+
+// NewUpstreamClient creates the client connection. The connection is lazy,
+// so this does not fail when the server is down.
+func NewUpstreamClient(cfg UpstreamConfig, bus *EventBus, logger Logger) (*UpstreamClient, error) {
+	creds, err := cfg.TLS.credentials()
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := grpc.NewClient(cfg.Target,
+		grpc.WithTransportCredentials(creds),
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                cfg.KeepaliveTime,
+			Timeout:             cfg.KeepaliveTimeout,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithConnectParams(grpc.ConnectParams{
+			Backoff: backoff.Config{
+				BaseDelay:  cfg.Backoff.Base,
+				Multiplier: cfg.Backoff.Multiplier,
+				Jitter:     cfg.Backoff.Jitter,
+				MaxDelay:   cfg.Backoff.Max,
+			},
+			MinConnectTimeout: 5 * time.Second,
+		}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create upstream client for %s: %w", cfg.Target, err)
+	}
+
+	if logger != nil {
+		logger = logger.With(FieldComponent, "upstream", "target", cfg.Target)
+	}
+
+	return &UpstreamClient{
+		cfg:    cfg,
+		conn:   conn,
+		bus:    bus,
+		logger: logger,
+	}, nil
+}
+
+// Conn returns the shared client connection
+func (c *UpstreamClient) Conn() *grpc.ClientConn {
+	return c.conn
+}
+
+// Stats returns a copy of the connection counters
+func (c *UpstreamClient) Stats() UpstreamStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.stats
+}
+
+// Run watches the connection state and runs session until ctx is cancelled.
+// When session returns, it is retried after a backoff delay.
+func (c *UpstreamClient) Run(ctx context.Context, session UpstreamSession) error {
+	go c.watchState(ctx)
+
+	attempt := 0
+	for {
+		started := time.Now()
+		err := session(ctx, c.conn)
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			c.mu.Lock()
+			c.stats.SessionErrors++
+			c.stats.LastError = err.Error()
+			c.mu.Unlock()
+		}
+
+		// 連線穩定跑過一段時間就重新計算退避
+		if time.Since(started) > c.cfg.Backoff.Max {
+			attempt = 0
+		}
+
+		delay := c.cfg.Backoff.Duration(attempt)
+		attempt++
+
+		if c.logger != nil {
+			c.logger.Warn("upstream session to %s ended: %v, retrying in %v", c.cfg.Target, err, delay)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Close closes the underlying connection
+func (c *UpstreamClient) Close() error {
+	return c.conn.Close()
+}
+
+func (c *UpstreamClient) watchState(ctx context.Context) {
+	c.conn.Connect()
+
+	state := c.conn.GetState()
+	for {
+		c.setState(state)
+
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		state = c.conn.GetState()
+	}
+}
+
+func (c *UpstreamClient) setState(state connectivity.State) {
+	connected := state == connectivity.Ready
+
+	c.mu.Lock()
+	changed := c.stats.Connected != connected
+	c.stats.Connected = connected
+	if changed && !connected {
+		c.stats.Disconnects++
+	}
+	c.mu.Unlock()
+
+	if !changed {
+		return
+	}
+
+	event := EventUpstreamDisconnected
+	if connected {
+		event = EventUpstreamConnected
+		if c.logger != nil {
+			c.logger.Info("upstream connected: %s", c.cfg.Target)
+		}
+	} else if c.logger != nil {
+		c.logger.Error("upstream disconnected: %s (state %s)", c.cfg.Target, state)
+	}
+
+	if c.bus != nil {
+		c.bus.Publish(event, UpstreamState{
+			Target:    c.cfg.Target,
+			State:     state.String(),
+			Connected: connected,
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+func (t TLSConfig) credentials() (credentials.TransportCredentials, error) {
+	if !t.Enabled {
+		return insecure.NewCredentials(), nil
+	}
+
+	tlsCfg := &tls.Config{
+		ServerName: t.ServerName,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return credentials.NewTLS(tlsCfg), nil
+}
@@ -0,0 +1,104 @@
+package initial
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeRedis 是記憶體版的 Redis，給測試或沒有 Redis 的環境使用
+type FakeRedis struct {
+	mu      sync.RWMutex
+	data    map[string]string
+	subs    map[string][]chan Message
+	pingErr error
+}
+
+func NewFakeRedis() *FakeRedis {
+	return &FakeRedis{
+		data: make(map[string]string),
+		subs: make(map[string][]chan Message),
+	}
+}
+
+// Set 寫入 key，並像開啟 keyspace notification 的 Redis 一樣送出 set 事件
+func (f *FakeRedis) Set(key string, val string) {
+	f.mu.Lock()
+	f.data[key] = val
+	f.mu.Unlock()
+
+	f.Publish(f.KeyspaceChannel(key), "set")
+}
+
+// Publish 送訊息給訂閱該頻道的 Subscribe
+func (f *FakeRedis) Publish(channel string, payload string) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	for _, ch := range f.subs[channel] {
+		select {
+		case ch <- Message{Channel: channel, Payload: payload}:
+		default:
+		}
+	}
+}
+
+// SetPingErr 設定後 Ping 會回傳這個錯誤，用來模擬 Redis 斷線
+func (f *FakeRedis) SetPingErr(err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.pingErr = err
+}
+
+func (f *FakeRedis) Get(_ context.Context, key string) (string, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+
+	val, ok := f.data[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return val, nil
+}
+
+func (f *FakeRedis) Ping(_ context.Context) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.pingErr
+}
+
+func (f *FakeRedis) Close() error {
+	return nil
+}
+
+func (f *FakeRedis) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	ch := make(chan Message, 16)
+
+	f.mu.Lock()
+	for _, c := range channels {
+		f.subs[c] = append(f.subs[c], ch)
+	}
+	f.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, c := range channels {
+			subs := f.subs[c]
+			for i, s := range subs {
+				if s == ch {
+					f.subs[c] = append(subs[:i], subs[i+1:]...)
+					break
+				}
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
+
+func (f *FakeRedis) KeyspaceChannel(key string) string {
+	return "__keyspace@0__:" + key
+}
@@ -1,17 +1,149 @@
 package initial
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/config"
+	"kenmec/peripheral/jimmy/infra"
+	"time"
+
 	"github.com/redis/go-redis/v9"
 )
 
-var Rdb *redis.Client
+// ErrNotFound 代表 key 不存在
+var ErrNotFound = errors.New("redis: key not found")
+
+// Message 是 pub/sub 收到的訊息
+type Message struct {
+	Channel string
+	Payload string
+}
+
+// Redis 是服務會用到的 Redis 操作，透過介面注入方便測試時替換成 FakeRedis
+type Redis interface {
+	Get(ctx context.Context, key string) (string, error)
+	Ping(ctx context.Context) error
+	Close() error
+
+	// Subscribe 訂閱頻道，ctx 取消時回傳的 channel 會被關閉
+	Subscribe(ctx context.Context, channels ...string) (<-chan Message, error)
+	// KeyspaceChannel 回傳 key 的 keyspace notification 頻道名稱
+	KeyspaceChannel(key string) string
+}
+
+// redisLogger 把 go-redis 內部的訊息轉到 infra.Logger
+type redisLogger struct {
+	logger infra.Logger
+}
+
+func (l redisLogger) Printf(_ context.Context, format string, v ...interface{}) {
+	l.logger.Warn(format, v...)
+}
+
+// SetLogger 讓 go-redis 內部的連線訊息也走服務的 logger
+func SetLogger(logger infra.Logger) {
+	redis.SetLogger(redisLogger{logger: logger.With(infra.FieldComponent, "redis")})
+}
+
+type redisClient struct {
+	rdb *redis.Client
+	db  int
+}
 
-func init() {
-	// 1. 初始化 Redis 客戶端
-	Rdb = redis.NewClient(&redis.Options{
-		Addr:     "localhost:6379", // Redis 伺服器位址
-		Password: "",               // 如果沒有設定密碼就留空
-		DB:       0,                // 使用預設的 DB 0
+// NewRedis 依設定建立 Redis 客戶端，並在回傳前 ping 成功，失敗會依退避時間重試
+func NewRedis(ctx context.Context, cfg config.RedisConfig, logger infra.Logger) (Redis, error) {
+	rdb := redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,     // Redis 伺服器位址
+		Password: cfg.Password, // 如果沒有設定密碼就留空
+		DB:       cfg.DB,
 	})
 
+	c := &redisClient{rdb: rdb, db: cfg.DB}
+	if logger != nil {
+		logger = logger.With(infra.FieldComponent, "redis")
+	}
+	backoff := infra.DefaultBackoff()
+
+	var err error
+	for attempt := 0; attempt <= cfg.ConnectRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoff.Duration(attempt - 1)
+			if logger != nil {
+				logger.Warn("redis ping %s failed: %v, retrying in %v", cfg.Addr, err, delay)
+			}
+
+			select {
+			case <-ctx.Done():
+				rdb.Close()
+				return nil, ctx.Err()
+			case <-time.After(delay):
+			}
+		}
+
+		if err = c.Ping(ctx); err == nil {
+			return c, nil
+		}
+		if ctx.Err() != nil {
+			rdb.Close()
+			return nil, ctx.Err()
+		}
+	}
+
+	rdb.Close()
+	return nil, fmt.Errorf("redis %s unreachable after %d retries: %w", cfg.Addr, cfg.ConnectRetries, err)
+}
+
+func (c *redisClient) Get(ctx context.Context, key string) (string, error) {
+	val, err := c.rdb.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrNotFound
+	}
+	return val, err
+}
+
+func (c *redisClient) Ping(ctx context.Context) error {
+	return c.rdb.Ping(ctx).Err()
+}
+
+func (c *redisClient) Close() error {
+	return c.rdb.Close()
+}
+
+// Subscribe 需要 Redis 開啟 notify-keyspace-events (至少 K$) 才收得到 keyspace 事件
+func (c *redisClient) Subscribe(ctx context.Context, channels ...string) (<-chan Message, error) {
+	ps := c.rdb.Subscribe(ctx, channels...)
+	if _, err := ps.Receive(ctx); err != nil {
+		ps.Close()
+		return nil, err
+	}
+
+	out := make(chan Message)
+	go func() {
+		defer close(out)
+		defer ps.Close()
+
+		ch := ps.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case out <- Message{Channel: msg.Channel, Payload: msg.Payload}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (c *redisClient) KeyspaceChannel(key string) string {
+	return fmt.Sprintf("__keyspace@%d__:%s", c.db, key)
 }
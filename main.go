@@ -1,76 +1,429 @@
 package main
 
 import (
-	// "database/sql"
-	// "kenmec/peripheral/jimmy/infra"
-	// "log"
-
 	"context"
 	"database/sql"
-	"kenmec/peripheral/jimmy/db"
-	_ "kenmec/peripheral/jimmy/initial"
+	"errors"
+	"flag"
+	"kenmec/peripheral/jimmy/api"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/config"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/initial"
+	"kenmec/peripheral/jimmy/metrics"
 	"kenmec/peripheral/jimmy/peripheral"
 	stackpb "kenmec/peripheral/jimmy/protoGen"
+	"kenmec/peripheral/jimmy/timeline"
+	"kenmec/peripheral/jimmy/wcs"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sync/atomic"
 	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
 
-	dsn := "root:kenmec123@tcp(127.0.0.1:3306)/test_p2?parseTime=true"
-	dbconn, err := sql.Open("mysql", dsn)
+	cfg, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		log.Fatal("設定檔載入失敗:", err)
+	}
 
+	slogger, err := infra.NewSlogLogger(os.Stdout, cfg.Log.Level, cfg.Log.Format)
 	if err != nil {
-		log.Fatal("資料庫連線失敗:", err)
+		log.Fatal("建立 logger 失敗:", err)
 	}
+	// 標準 log 套件與 go-redis 的輸出也走同一個 logger
+	slog.SetDefault(slogger.Slog())
+
+	var logger infra.Logger = slogger
+	initial.SetLogger(logger)
+
+	// SIGINT/SIGTERM 會取消 root context，所有元件都從這裡拿 context
+	lc := infra.NewLifecycle(cfg.Lifecycle.ShutdownTimeout, logger)
+	ctx := lc.Context()
 
-	queries := db.New(dbconn)
+	dbconn, err := sql.Open("mysql", cfg.Database.DSN)
 
-	// eb := infra.New()
+	if err != nil {
+		fatal(logger, "資料庫連線失敗: %v", err)
+	}
+	dbconn.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+	dbconn.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+	dbconn.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+	lc.OnStop("mysql", func(context.Context) error { return dbconn.Close() })
 
-	psm := peripheral.NewStackManager(queries)
+	rdb, err := initial.NewRedis(ctx, cfg.Redis, logger)
+	if err != nil {
+		fatal(logger, "Redis 連線失敗: %v", err)
+	}
+	lc.OnStop("redis", func(context.Context) error { return rdb.Close() })
 
-	// m.PrintDebug()
+	mt := metrics.New()
 
-	for {
-		grpcConn, err := grpc.NewClient("localhost:50051",
-			grpc.WithTransportCredentials(insecure.NewCredentials()))
+	eb := infra.New()
+	eb.SetMetrics(mt)
 
+	// simulator.scale 不是 1 時整個模擬用加速的時間跑
+	clock := infra.NewClock(cfg.Simulator.Scale)
+	if cfg.Simulator.Scale != 1 {
+		logger.Info("模擬時間加速 %gx", cfg.Simulator.Scale)
+	}
+
+	snapshots := peripheral.NewSnapshotStore(cfg.Snapshot.Dir)
+
+	// 資料庫連不上時改用最後一次自動寫入的快照啟動，輸送帶也從快照還原
+	var snap *peripheral.StateSnapshot
+	psm, err := peripheral.NewStackManager(dbconn, rdb, eb, logger)
+	if err != nil {
+		var snapErr error
+		snap, snapErr = snapshots.Load(peripheral.LatestSnapshot)
+		if snapErr != nil {
+			fatal(logger, "載入堆疊失敗: %v (快照: %v)", err, snapErr)
+		}
+		logger.With(infra.FieldScriptID, snap.ScriptID).
+			Warn("載入堆疊失敗: %v，改用 %s 的快照", err, snap.CreatedAt.Format(time.RFC3339))
+		psm = peripheral.NewStackManagerFromSnapshot(snap, dbconn, rdb, eb, logger)
+	}
+
+	psm.SetIndexedMetadataKey(cfg.Cargo.IndexMetadataKey)
+	psm.SetClock(clock)
+
+	// 貨物每次改變狀態都寫回 cargo_info.status / owner 並留下 cargo_history
+	lifecycle := cargo.NewRecorder(dbconn, logger)
+	psm.SetLifecycle(lifecycle)
+
+	if cfg.Journal.Path != "" {
+		journal, err := peripheral.OpenJournal(cfg.Journal.Path, logger)
 		if err != nil {
-			log.Printf("連線失敗，5秒後重試... %v", err)
-			time.Sleep(5 * time.Second)
-			continue
+			fatal(logger, "開啟 journal 失敗: %v", err)
+		}
+		psm.SetJournal(infra.WithActor(ctx, "startup"), journal)
+		lc.OnStop("journal", func(context.Context) error { return journal.Close() })
+	}
+
+	if cfg.Snapshot.Interval > 0 {
+		lc.Go("snapshot", func(ctx context.Context) error {
+			return psm.RunSnapshots(ctx, snapshots, cfg.Snapshot.Interval)
+		})
+	}
+
+	conveyors := peripheral.NewConveyorManager(dbconn, eb, logger)
+	conveyors.SetClock(clock)
+	conveyors.SetLifecycle(lifecycle)
+	if err := conveyors.Load(ctx, psm.ScriptID()); err != nil {
+		logger.Warn("載入輸送帶失敗: %v", err)
+		if snap != nil && snap.Conveyors != nil {
+			conveyors.Restore(snap)
+		}
+	}
+	psm.SetConveyors(conveyors)
+
+	// 堆疊指令經過 mock WCS，套用 mock_wcs_station 的延遲、停用與注入的故障
+	stations := wcs.New(dbconn, logger, cfg.MockWCS.Timeout)
+	stations.SetClock(clock)
+	if err := stations.Load(ctx, psm.ScriptID()); err != nil {
+		logger.Warn("載入 mock WCS 站點失敗: %v", err)
+	}
+
+	// ROBOTIC_ARM、PALLETIZER、ROTATE_TABLE 位置的機台模擬，狀態跟著堆疊一起推送
+	machines := peripheral.NewMachineManager(dbconn, psm, eb, logger, peripheral.MachineParams{
+		ArmCycleTime: cfg.Machines.ArmCycleTime,
+		PatternCount: cfg.Machines.PalletizerPatternCount,
+		LayerSize:    cfg.Machines.PalletizerLayerSize,
+		RotateSpeed:  cfg.Machines.RotateSpeed,
+	})
+	machines.SetClock(clock)
+	machines.SetLifecycle(lifecycle)
+	if err := machines.Load(ctx, psm.ScriptID()); err != nil {
+		logger.Warn("載入機台失敗: %v", err)
+	}
+	psm.SetMachines(machines)
+
+	// 多層貨架 (ShelfConfig) 也跟著堆疊一起推送
+	shelves := peripheral.NewShelfManager(dbconn, eb, logger)
+	shelves.SetClock(clock)
+	shelves.SetLifecycle(lifecycle)
+	if err := shelves.Load(ctx, psm.ScriptID()); err != nil {
+		logger.Warn("載入貨架失敗: %v", err)
+	}
+	psm.SetShelves(shelves)
+
+	// STORAGE、DISPATCH、STANDBY、EXTRA 位置的佔用，AMR 進入前先 claim
+	areas := peripheral.NewAreaManager(dbconn, eb, logger, cfg.Areas.DefaultTTL)
+	areas.SetClock(clock)
+	if err := areas.Load(ctx, psm.ScriptID()); err != nil {
+		logger.Warn("載入位置失敗: %v", err)
+	}
+	psm.SetAreas(areas)
+	lc.Go("area-sweeper", func(ctx context.Context) error {
+		return areas.Run(ctx, cfg.Areas.SweepInterval)
+	})
+
+	tl := timeline.New(dbconn, psm, conveyors, eb, logger)
+	if err := tl.SetClock(clock); err != nil {
+		fatal(logger, "設定時間軸時鐘失敗: %v", err)
+	}
+	// 時間軸生成與移走 (包含輸送帶) 也經過 mock WCS，透過 request bus 送出，bus.request_timeout 後失敗
+	requests := infra.NewWithConfig(infra.Config{
+		DefaultTimeout: cfg.Bus.RequestTimeout,
+		Logger:         logger,
+		Metrics:        mt,
+	})
+	stations.Serve(requests)
+	tl.SetStations(stations)
+	tl.SetRequests(requests)
+	lc.Go("timeline", tl.Run)
+
+	// 時間軸執行期間的統計，停止時寫入 simulation_result，要在自動開始前訂閱
+	results := timeline.NewCollector(dbconn, psm, eb, logger, cfg.Timeline.ResultDir, cfg.Timeline.SampleInterval)
+	results.SetClock(clock)
+	lc.Go("simulation-result", results.Run)
+	lc.OnStop("timeline", func(ctx context.Context) error {
+		// 關機時結束這次執行，統計在 Run 結束後才進佇列，由這裡寫入
+		if err := tl.Stop(); err != nil && !errors.Is(err, timeline.ErrNotRunning) {
+			return err
+		}
+		return results.Flush(ctx)
+	})
+
+	if cfg.Timeline.AutoStart {
+		if err := tl.Start(ctx); err != nil {
+			logger.Warn("啟動時間軸失敗: %v", err)
+		}
+	}
+
+	// 切換腳本後輸送帶跟著重新載入，舊腳本的時間軸停止
+	eb.Subscribe(peripheral.EventScriptChanged, func(data interface{}) {
+		change, ok := data.(peripheral.ScriptChanged)
+		if !ok {
+			return
+		}
+		if err := conveyors.Load(ctx, change.NewScriptID); err != nil {
+			logger.Warn("載入輸送帶失敗: %v", err)
+		}
+		if err := stations.Load(ctx, change.NewScriptID); err != nil {
+			logger.Warn("載入 mock WCS 站點失敗: %v", err)
+		}
+		if err := machines.Load(ctx, change.NewScriptID); err != nil {
+			logger.Warn("載入機台失敗: %v", err)
 		}
+		if err := shelves.Load(ctx, change.NewScriptID); err != nil {
+			logger.Warn("載入貨架失敗: %v", err)
+		}
+		if err := areas.Load(ctx, change.NewScriptID); err != nil {
+			logger.Warn("載入位置失敗: %v", err)
+		}
+		_ = tl.Stop()
+		if cfg.Timeline.AutoStart {
+			if err := tl.Start(ctx); err != nil {
+				logger.Warn("啟動時間軸失敗: %v", err)
+			}
+		}
+	})
+
+	// 操作員在 UI 切換腳本時重新載入堆疊
+	lc.Go("script-watcher", func(ctx context.Context) error {
+		return psm.WatchScript(infra.WithActor(ctx, "script-watcher"), eb, cfg.Redis.ScriptChannel)
+	})
+
+	upstream, err := infra.NewUpstreamClient(cfg.Upstream.ClientConfig(), eb, logger)
+	if err != nil {
+		fatal(logger, "建立 gRPC 連線失敗: %v", err)
+	}
+	lc.OnStop("upstream", func(context.Context) error { return upstream.Close() })
+
+	mt.RegisterStacks(psm)
+	mt.RegisterUpstream(upstream)
 
-		gClient := stackpb.NewStackServiceClient(grpcConn)
+	health := api.NewHealth(2 * time.Second)
+	health.AddCheck("mysql", dbconn.PingContext)
+	health.AddCheck("redis", rdb.Ping)
+	health.AddCheck("upstream", func(context.Context) error {
+		if !upstream.Stats().Connected {
+			return errors.New("upstream stream not connected")
+		}
+		return nil
+	})
+	health.AddCheck("script", func(context.Context) error {
+		if psm.ScriptID() == "" {
+			return errors.New("no mission script loaded")
+		}
+		return nil
+	})
 
-		stream, err := gClient.PushStacks(context.Background())
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", mt.Handler())
+	health.Register(mux)
+	stackHandler := api.NewStackHandler(psm)
+	stackHandler.SetStations(stations)
+	stackHandler.Register(mux)
+	api.NewWCSHandler(stations).Register(mux)
+	machineHandler := api.NewMachineHandler(machines)
+	machineHandler.SetStations(stations)
+	machineHandler.Register(mux)
+	shelfHandler := api.NewShelfHandler(shelves)
+	shelfHandler.SetStations(stations)
+	shelfHandler.Register(mux)
+	api.NewAreaHandler(areas).Register(mux)
+	api.RegisterOpenAPI(mux)
+	feed := api.NewFeed(psm, eb, logger)
+	feed.SetAreas(areas)
+	feed.Register(mux)
+	api.NewSnapshotHandler(psm, snapshots).Register(mux)
+	api.NewTimelineHandler(tl).Register(mux)
+	api.NewSimulationHandler(results).Register(mux)
+	api.RegisterDebug(mux, func() interface{} {
+		return map[string]interface{}{
+			"scriptId":  psm.ScriptID(),
+			"stacks":    psm.Snapshot(),
+			"conveyors": conveyors.Snapshot(),
+			"stations":  stations.Stations(),
+			"machines":  machines.Snapshot(),
+			"shelves":   shelves.Snapshot(),
+			"areas":     areas.Snapshot(),
+			"timeline":  tl.Status(),
+			"upstream":  upstream.Stats(),
+		}
+	})
+
+	srv := &http.Server{
+		Addr:    cfg.HTTP.Addr,
+		Handler: api.WithRequestID(mux, logger),
+		// 關機時取消所有 request 的 context，讓 /feed 這種長連線結束
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	lc.Go("http", func(ctx context.Context) error {
+		// 關機時停止接受新連線讓 ListenAndServe 返回，進行中的 request 由 OnStop 在期限內等待
+		stop := context.AfterFunc(ctx, func() { _ = srv.Shutdown(context.WithoutCancel(ctx)) })
+		defer stop()
+
+		if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	})
+	lc.OnStop("http", srv.Shutdown)
+
+	if cfg.GRPC.Addr != "" {
+		lis, err := net.Listen("tcp", cfg.GRPC.Addr)
 		if err != nil {
-			log.Printf("建立串流失敗，重試中... %v", err)
-			grpcConn.Close()
-			time.Sleep(5 * time.Second)
-			continue
+			fatal(logger, "gRPC server 監聽失敗: %v", err)
 		}
 
-		err = runSendLoop(stream, psm)
+		gsrv := grpc.NewServer()
+		stackpb.RegisterCargoServiceServer(gsrv, api.NewCargoServer(psm))
+
+		lc.Go("grpc", func(ctx context.Context) error {
+			stop := context.AfterFunc(ctx, gsrv.GracefulStop)
+			defer stop()
+
+			return gsrv.Serve(lis)
+		})
+		lc.OnStop("grpc", func(ctx context.Context) error {
+			// GracefulStop 會等進行中的 RPC，超過關機期限就強制停止
+			stopped := make(chan struct{})
+			go func() {
+				gsrv.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				gsrv.Stop()
+			}
+			return nil
+		})
+	}
+
+	gClient := stackpb.NewStackServiceClient(upstream.Conn())
+
+	// 同一條 ClientConn 重複使用，串流斷掉時依退避時間重建串流
+	lc.Go("upstream", func(ctx context.Context) error {
+		return upstream.Run(ctx, func(ctx context.Context, _ *grpc.ClientConn) error {
+			// 串流本身不跟著 root context 取消，關機時才來得及送最後一次快照
+			streamCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+			defer cancel()
 
-		// 如果 runSendLoop 回傳錯誤，代表串流斷了
-		log.Printf("串流中斷: %v，準備重新連線...", err)
-		grpcConn.Close()
-		time.Sleep(2 * time.Second)
+			// 還在等連線時收到關機就直接放棄，串流建立後才脫離 root context
+			stop := context.AfterFunc(ctx, cancel)
+			stream, err := gClient.PushStacks(streamCtx, grpc.WaitForReady(true))
+			if err != nil || !stop() {
+				return err
+			}
+
+			return runSendLoop(ctx, stream, psm, eb, cfg.Simulator.PushInterval, mt)
+		})
+	})
 
+	if err := lc.Wait(); err != nil {
+		fatal(logger, "關機過程發生錯誤: %v", err)
 	}
+}
 
+func fatal(logger infra.Logger, msg string, args ...interface{}) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
 
-func runSendLoop(stream stackpb.StackService_PushStacksClient, m *peripheral.YFYStackManager) error {
+func runSendLoop(ctx context.Context, stream stackpb.StackService_PushStacksClient, m *peripheral.YFYStackManager, eb *infra.EventBus, interval time.Duration, mt *metrics.Metrics) error {
+
+	// 新的串流先送一次完整資料
+	m.Mu.Lock()
+	m.IsDirty = true
+	m.Mu.Unlock()
+
+	// 跟 live feed 用同一個變動通知，有變動就立刻推送，ticker 只是保底
+	wake := make(chan struct{}, 1)
+	subID := eb.Subscribe(peripheral.EventStackChanged, func(interface{}) {
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	defer eb.Unsubscribe(peripheral.EventStackChanged, subID)
+
+	// 機台與貨架不經過堆疊，自己標記 dirty。Publish 是非同步的，可以拿 Mu
+	markDirty := func(interface{}) {
+		m.Mu.Lock()
+		m.IsDirty = true
+		m.Mu.Unlock()
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	}
+	machineSubID := eb.Subscribe(peripheral.EventMachineChanged, markDirty)
+	defer eb.Unsubscribe(peripheral.EventMachineChanged, machineSubID)
+	shelfSubID := eb.Subscribe(peripheral.EventShelfChanged, markDirty)
+	defer eb.Unsubscribe(peripheral.EventShelfChanged, shelfSubID)
+	// 位置佔用是同步發出的，handler 持有 areas 的鎖，不能再拿 m.Mu，只記下來給迴圈處理
+	var areaDirty atomic.Bool
+	areaSubID := eb.Subscribe(peripheral.EventAreaChanged, func(interface{}) {
+		areaDirty.Store(true)
+		select {
+		case wake <- struct{}{}:
+		default:
+		}
+	})
+	defer eb.Unsubscribe(peripheral.EventAreaChanged, areaSubID)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
 	for {
 		m.Mu.Lock()
+		if areaDirty.Swap(false) {
+			m.IsDirty = true
+		}
 		if m.IsDirty {
 
 			if err := stream.Send(m.ToProto()); err != nil {
@@ -78,8 +431,31 @@ func runSendLoop(stream stackpb.StackService_PushStacksClient, m *peripheral.YFY
 				return err
 			}
 			m.IsDirty = false
+			mt.MessageSent()
 		}
 		m.Mu.Unlock()
-		time.Sleep(1 * time.Second)
+
+		select {
+		case <-ctx.Done():
+			return closeStream(stream, m, mt)
+		case <-wake:
+		case <-ticker.C:
+		}
 	}
 }
+
+// closeStream 關機時送出最後一次完整快照，再用 CloseAndRecv 正常結束串流
+func closeStream(stream stackpb.StackService_PushStacksClient, m *peripheral.YFYStackManager, mt *metrics.Metrics) error {
+	m.Mu.Lock()
+	err := stream.Send(m.ToProto())
+	m.IsDirty = false
+	m.Mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+	mt.MessageSent()
+
+	_, err = stream.CloseAndRecv()
+	return err
+}
@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "peripheral"
+
+// Metrics owns the Prometheus registry of the service and implements
+// infra.Metrics for the event and request buses
+type Metrics struct {
+	registry *prometheus.Registry
+
+	eventsPublished *prometheus.CounterVec
+	eventsDropped   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	requestTimeouts *prometheus.CounterVec
+	requestRetries  *prometheus.CounterVec
+	messagesSent    prometheus.Counter
+}
+
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		eventsPublished: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "eventbus_published_total",
+			Help:      "Events published on the EventBus with at least one subscriber.",
+		}, []string{"event"}),
+		eventsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "eventbus_dropped_total",
+			Help:      "Events published on the EventBus with no subscriber.",
+		}, []string{"event"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "reqbus_request_duration_seconds",
+			Help:      "Latency of RequestResponseBus requests.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}, []string{"topic", "result"}),
+		requestTimeouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reqbus_timeouts_total",
+			Help:      "RequestResponseBus requests that timed out.",
+		}, []string{"topic"}),
+		requestRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reqbus_retries_total",
+			Help:      "RequestResponseBus request retries.",
+		}, []string{"topic"}),
+		messagesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_messages_sent_total",
+			Help:      "Snapshots sent on the upstream PushStacks stream.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.eventsPublished,
+		m.eventsDropped,
+		m.requestDuration,
+		m.requestTimeouts,
+		m.requestRetries,
+		m.messagesSent,
+	)
+
+	return m
+}
+
+// Handler serves the registry in the Prometheus exposition format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry returns the underlying registry so other packages can add collectors
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// RegisterStacks exports per-location stack gauges read at scrape time
+func (m *Metrics) RegisterStacks(ysm *peripheral.YFYStackManager) {
+	m.registry.MustRegister(newStackCollector(ysm))
+}
+
+// RegisterUpstream exports the upstream connection counters
+func (m *Metrics) RegisterUpstream(up *infra.UpstreamClient) {
+	m.registry.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "upstream_connected",
+			Help:      "1 when the upstream gRPC connection is ready.",
+		}, func() float64 {
+			if up.Stats().Connected {
+				return 1
+			}
+			return 0
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_disconnects_total",
+			Help:      "Times the upstream gRPC connection left the ready state.",
+		}, func() float64 {
+			return float64(up.Stats().Disconnects)
+		}),
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "upstream_stream_reconnects_total",
+			Help:      "Times the PushStacks stream ended and was re-created.",
+		}, func() float64 {
+			return float64(up.Stats().SessionErrors)
+		}),
+	)
+}
+
+// MessageSent counts one snapshot sent upstream
+func (m *Metrics) MessageSent() {
+	m.messagesSent.Inc()
+}
+
+func (m *Metrics) EventPublished(event string, handlers int) {
+	m.eventsPublished.WithLabelValues(event).Inc()
+}
+
+func (m *Metrics) EventDropped(event string) {
+	m.eventsDropped.WithLabelValues(event).Inc()
+}
+
+func (m *Metrics) RequestCompleted(topic string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	m.requestDuration.WithLabelValues(topic, result).Observe(duration.Seconds())
+}
+
+func (m *Metrics) RequestTimeout(topic string) {
+	m.requestTimeouts.WithLabelValues(topic).Inc()
+}
+
+func (m *Metrics) RequestRetry(topic string) {
+	m.requestRetries.WithLabelValues(topic).Inc()
+}
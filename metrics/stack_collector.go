@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"kenmec/peripheral/jimmy/peripheral"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// stackCollector reads the stack manager on every scrape, so locations
+// removed by DeleteStack or a script change disappear from the output
+type stackCollector struct {
+	ysm *peripheral.YFYStackManager
+
+	cargoCount  *prometheus.Desc
+	capacity    *prometheus.Desc
+	utilisation *prometheus.Desc
+	reserved    *prometheus.Desc
+	reservedSum *prometheus.Desc
+}
+
+func newStackCollector(ysm *peripheral.YFYStackManager) *stackCollector {
+	labels := []string{"location_id", "name"}
+
+	return &stackCollector{
+		ysm: ysm,
+		cargoCount: prometheus.NewDesc(namespace+"_stack_cargo_count",
+			"Cargo currently on the stack.", labels, nil),
+		capacity: prometheus.NewDesc(namespace+"_stack_capacity",
+			"Stack capacity (stack_count).", labels, nil),
+		utilisation: prometheus.NewDesc(namespace+"_stack_utilisation_ratio",
+			"Cargo count divided by capacity.", labels, nil),
+		reserved: prometheus.NewDesc(namespace+"_stack_reserved",
+			"1 when the stack is reserved by a booker.", labels, nil),
+		reservedSum: prometheus.NewDesc(namespace+"_stack_reservations",
+			"Number of stacks currently reserved.", nil, nil),
+	}
+}
+
+func (c *stackCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.cargoCount
+	ch <- c.capacity
+	ch <- c.utilisation
+	ch <- c.reserved
+	ch <- c.reservedSum
+}
+
+func (c *stackCollector) Collect(ch chan<- prometheus.Metric) {
+	reservations := 0
+
+	for _, s := range c.ysm.StackStats() {
+		utilisation := 0.0
+		if s.Capacity > 0 {
+			utilisation = float64(s.CargoCount) / float64(s.Capacity)
+		}
+
+		reserved := 0.0
+		if s.Reserved {
+			reserved = 1
+			reservations++
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.cargoCount, prometheus.GaugeValue, float64(s.CargoCount), s.LocationID, s.Name)
+		ch <- prometheus.MustNewConstMetric(c.capacity, prometheus.GaugeValue, float64(s.Capacity), s.LocationID, s.Name)
+		ch <- prometheus.MustNewConstMetric(c.utilisation, prometheus.GaugeValue, utilisation, s.LocationID, s.Name)
+		ch <- prometheus.MustNewConstMetric(c.reserved, prometheus.GaugeValue, reserved, s.LocationID, s.Name)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.reservedSum, prometheus.GaugeValue, float64(reservations))
+}
@@ -0,0 +1,105 @@
+package peripheral
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrAreaNotFound    = errors.New("area not found")
+	ErrAreaOccupied    = errors.New("area is occupied")
+	ErrClaimNotFound   = errors.New("claim not found")
+	ErrInvalidOccupant = errors.New("invalid occupant")
+)
+
+// EventAreaChanged 在位置的佔用變動後同步發出 (PublishSync)，內容是 AreaChange。
+// 每個事件都是完整的位置狀態，非同步發出可能讓先後順序顛倒。
+// handler 在持有 AreaManager.Mu 的情況下被呼叫，不可再呼叫 manager 的方法，也不可阻塞
+const EventAreaChanged = "area.changed"
+
+// PeripheralArea 是 live feed 的週邊類型，給訂閱端依類型過濾
+const PeripheralArea = "area"
+
+// AreaType 是 Loc.areaType 中沒有設備、只需要佔用管理的種類
+type AreaType string
+
+const (
+	AreaStorage  AreaType = "STORAGE"
+	AreaDispatch AreaType = "DISPATCH"
+	AreaStandby  AreaType = "STANDBY"
+	AreaExtra    AreaType = "EXTRA"
+)
+
+// OccupantKind 是佔用位置的是 AMR 還是貨物
+type OccupantKind string
+
+const (
+	OccupantRobot OccupantKind = "robot"
+	OccupantCargo OccupantKind = "cargo"
+)
+
+func (k OccupantKind) valid() bool {
+	return k == OccupantRobot || k == OccupantCargo
+}
+
+// Claim 是一筆佔用，Holder 是 AMR 或貨物的 id
+type Claim struct {
+	Holder    string       `json:"holder"`
+	Kind      OccupantKind `json:"kind"`
+	ClaimedAt time.Time    `json:"claimedAt"`
+	// ExpiresAt 之後佔用自動釋放，零值表示不會過期
+	ExpiresAt time.Time `json:"expiresAt,omitzero"`
+}
+
+func (c Claim) expired(now time.Time) bool {
+	return !c.ExpiresAt.IsZero() && !now.Before(c.ExpiresAt)
+}
+
+// Area 是一個 STORAGE、DISPATCH、STANDBY 或 EXTRA 位置，同時最多 Capacity 筆佔用
+type Area struct {
+	LocationID string   `json:"locationId"`
+	Name       string   `json:"name"`
+	Type       AreaType `json:"type"`
+	// Capacity 是 peripheral_name.quantity，沒有對應或為 0 時視為 1
+	Capacity int     `json:"capacity"`
+	Claims   []Claim `json:"claims"`
+}
+
+// AreaChange 是 EventAreaChanged 的內容，Type 為 ChangeReset 時 Area 是 nil
+type AreaChange struct {
+	Type       ChangeType
+	LocationID string
+	Area       *Area
+	Timestamp  time.Time
+}
+
+// !! ------  呼叫下面的方法記得用上層的mutex --- !!
+
+func (a *Area) clone() Area {
+	c := *a
+	c.Claims = append([]Claim(nil), a.Claims...)
+	return c
+}
+
+// claim 找出 holder 的佔用
+func (a *Area) claim(holder string) int {
+	for i, c := range a.Claims {
+		if c.Holder == holder {
+			return i
+		}
+	}
+	return -1
+}
+
+// expire 移除 now 時已過期的佔用，回傳是否有移除
+func (a *Area) expire(now time.Time) bool {
+	kept := a.Claims[:0]
+	for _, c := range a.Claims {
+		if !c.expired(now) {
+			kept = append(kept, c)
+		}
+	}
+	removed := len(kept) != len(a.Claims)
+	a.Claims = kept
+	return removed
+}
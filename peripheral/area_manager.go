@@ -0,0 +1,287 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	stackpb "kenmec/peripheral/jimmy/protoGen"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AreaManager 管理目前腳本一般位置 (STORAGE、DISPATCH、STANDBY、EXTRA) 的佔用，只存在記憶體。
+// AMR 先 Claim 再進入，離開後 Release；有 TTL 的佔用過期後由 Run 釋放
+type AreaManager struct {
+	infoMap map[string]*Area // locationId -> 位置
+	db      *db.Queries
+	bus     *infra.EventBus
+	logger  infra.Logger
+	clock   infra.Clock
+	// defaultTTL 是 Claim 沒指定 ttl 時使用的值，0 表示不會過期
+	defaultTTL time.Duration
+
+	Mu sync.Mutex
+}
+
+func NewAreaManager(conn *sql.DB, bus *infra.EventBus, logger infra.Logger, defaultTTL time.Duration) *AreaManager {
+	m := &AreaManager{
+		infoMap:    make(map[string]*Area),
+		bus:        bus,
+		logger:     logger.With(infra.FieldComponent, "area-manager"),
+		clock:      infra.NewRealClock(),
+		defaultTTL: defaultTTL,
+	}
+	if conn != nil {
+		m.db = db.New(conn)
+	}
+	return m
+}
+
+// SetClock 替換佔用時間與過期判斷的時間來源，nil 表示使用真實時間
+func (m *AreaManager) SetClock(c infra.Clock) {
+	if c == nil {
+		c = infra.NewRealClock()
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.clock = c
+}
+
+// SetAreas 讓 ToProto 一併輸出位置的佔用
+func (m *YFYStackManager) SetAreas(am *AreaManager) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.areas = am
+}
+
+// Load 讀出腳本的所有一般位置並整個替換，原本的佔用一併清除
+func (m *AreaManager) Load(ctx context.Context, scriptId string) error {
+	if m.db == nil {
+		return nil
+	}
+
+	rows, err := m.db.ListAreas(ctx, sql.NullString{String: scriptId, Valid: true})
+	if err != nil {
+		return fmt.Errorf("load areas of script %s: %w", scriptId, err)
+	}
+
+	infoMap := make(map[string]*Area, len(rows))
+	for _, r := range rows {
+		infoMap[r.Locationid] = &Area{
+			LocationID: r.Locationid,
+			Name:       r.Name.String,
+			Type:       AreaType(r.Areatype),
+			Capacity:   max(int(r.Quantity.Int32), 1),
+		}
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.infoMap = infoMap
+	if m.bus != nil {
+		m.bus.PublishSync(EventAreaChanged, AreaChange{Type: ChangeReset, Timestamp: m.clock.Now()})
+	}
+	return nil
+}
+
+// Snapshot 回傳目前所有位置的複本，已過期還沒被 Run 清掉的佔用不算
+func (m *AreaManager) Snapshot() map[string]Area {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	now := m.clock.Now()
+	out := make(map[string]Area, len(m.infoMap))
+	for locID, a := range m.infoMap {
+		c := a.clone()
+		c.expire(now)
+		out[locID] = c
+	}
+	return out
+}
+
+// Area 回傳單一位置的複本
+func (m *AreaManager) Area(locID string) (Area, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	a, err := m.area(locID)
+	if err != nil {
+		return Area{}, err
+	}
+	c := a.clone()
+	c.expire(m.clock.Now())
+	return c, nil
+}
+
+// area 找出 locID 的位置，呼叫前必須持有 Mu
+func (m *AreaManager) area(locID string) (*Area, error) {
+	a, ok := m.infoMap[locID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrAreaNotFound, locID)
+	}
+	return a, nil
+}
+
+// changed 通知訂閱者，呼叫前必須持有 Mu
+func (m *AreaManager) changed(a *Area) {
+	if m.bus == nil {
+		return
+	}
+	c := a.clone()
+	m.bus.PublishSync(EventAreaChanged, AreaChange{
+		Type:       ChangeUpsert,
+		LocationID: a.LocationID,
+		Area:       &c,
+		Timestamp:  m.clock.Now(),
+	})
+}
+
+// Claim 讓 holder 佔用位置 ttl 的時間，ttl 為 0 時使用預設值，負數表示不會過期。
+// holder 為空字串時使用 context 的操作者。holder 已經佔用時只更新過期時間，
+// 位置已滿時回傳 ErrAreaOccupied
+func (m *AreaManager) Claim(ctx context.Context, locID, holder string, kind OccupantKind, ttl time.Duration) (Area, error) {
+	if !kind.valid() {
+		return Area{}, fmt.Errorf("%w: kind %q", ErrInvalidOccupant, kind)
+	}
+	if holder == "" {
+		holder = infra.ActorFrom(ctx)
+	}
+	if ttl == 0 {
+		ttl = m.defaultTTL
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	a, err := m.area(locID)
+	if err != nil {
+		return Area{}, err
+	}
+
+	now := m.clock.Now()
+	expired := a.expire(now)
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = now.Add(ttl)
+	}
+
+	if i := a.claim(holder); i >= 0 {
+		a.Claims[i].Kind = kind
+		a.Claims[i].ExpiresAt = expiresAt
+	} else {
+		if len(a.Claims) >= a.Capacity {
+			// 佔用失敗，但剛才清掉的過期佔用仍要通知
+			if expired {
+				m.changed(a)
+			}
+			holders := make([]string, 0, len(a.Claims))
+			for _, c := range a.Claims {
+				holders = append(holders, c.Holder)
+			}
+			return Area{}, fmt.Errorf("%w: %s by %s", ErrAreaOccupied, locID, strings.Join(holders, ", "))
+		}
+		a.Claims = append(a.Claims, Claim{Holder: holder, Kind: kind, ClaimedAt: now, ExpiresAt: expiresAt})
+	}
+
+	m.changed(a)
+	return a.clone(), nil
+}
+
+// Release 釋放 holder 的佔用，holder 沒有佔用時回傳 ErrClaimNotFound。
+// holder 為空字串時釋放所有佔用，給操作員排除卡住的位置
+func (m *AreaManager) Release(ctx context.Context, locID, holder string) (Area, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	a, err := m.area(locID)
+	if err != nil {
+		return Area{}, err
+	}
+
+	expired := a.expire(m.clock.Now())
+	if holder == "" {
+		a.Claims = nil
+	} else {
+		i := a.claim(holder)
+		if i < 0 {
+			if expired {
+				m.changed(a)
+			}
+			return Area{}, fmt.Errorf("%w: %s on %s", ErrClaimNotFound, holder, locID)
+		}
+		a.Claims = append(a.Claims[:i], a.Claims[i+1:]...)
+	}
+
+	m.changed(a)
+	return a.clone(), nil
+}
+
+// Run 每隔 interval 釋放過期的佔用並通知訂閱者，直到 ctx 取消
+func (m *AreaManager) Run(ctx context.Context, interval time.Duration) error {
+	m.Mu.Lock()
+	ticker := m.clock.NewTicker(interval)
+	m.Mu.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C():
+			m.expire()
+		}
+	}
+}
+
+func (m *AreaManager) expire() {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	now := m.clock.Now()
+	for locID, a := range m.infoMap {
+		if a.expire(now) {
+			m.logger.With(infra.FieldLocationID, locID).Debug("area claim expired")
+			m.changed(a)
+		}
+	}
+}
+
+// ToProto 轉成推送給上游的格式，key 是 locationId
+func (m *AreaManager) ToProto() map[string]*stackpb.Area {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	now := m.clock.Now()
+	out := make(map[string]*stackpb.Area, len(m.infoMap))
+	for locID, a := range m.infoMap {
+		pb := &stackpb.Area{
+			Name:     a.Name,
+			AreaType: string(a.Type),
+			Capacity: int32(a.Capacity),
+		}
+		for _, c := range a.Claims {
+			if c.expired(now) {
+				continue
+			}
+			pc := &stackpb.AreaClaim{
+				Holder:      c.Holder,
+				Kind:        string(c.Kind),
+				ClaimedAtMs: c.ClaimedAt.UnixMilli(),
+			}
+			if !c.ExpiresAt.IsZero() {
+				pc.ExpiresAtMs = c.ExpiresAt.UnixMilli()
+			}
+			pb.Claims = append(pb.Claims, pc)
+		}
+		out[locID] = pb
+	}
+	return out
+}
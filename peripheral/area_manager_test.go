@@ -0,0 +1,90 @@
+package peripheral
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// TestAreaReleaseNotifiesExpired 釋放失敗時，順便清掉的過期佔用仍要通知訂閱者
+func TestAreaReleaseNotifiesExpired(t *testing.T) {
+	bus := infra.New()
+	changes := make(chan AreaChange, 8)
+	bus.Subscribe(EventAreaChanged, func(data interface{}) { changes <- data.(AreaChange) })
+
+	clock := infra.NewManualClock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	m := NewAreaManager(nil, bus, testLogger(t), 0)
+	m.SetClock(clock)
+	m.infoMap["A1"] = &Area{LocationID: "A1", Type: AreaStorage, Capacity: 1}
+
+	next := func() AreaChange {
+		t.Helper()
+		select {
+		case c := <-changes:
+			return c
+		case <-time.After(time.Second):
+			t.Fatal("no area change")
+		}
+		return AreaChange{}
+	}
+
+	ctx := context.Background()
+	if _, err := m.Claim(ctx, "A1", "r1", OccupantRobot, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if c := next(); len(c.Area.Claims) != 1 {
+		t.Fatalf("change after claim has %v, want r1", c.Area.Claims)
+	}
+
+	clock.Advance(10 * time.Second)
+	if _, err := m.Release(ctx, "A1", "r2"); !errors.Is(err, ErrClaimNotFound) {
+		t.Fatalf("Release of r2 = %v, want ErrClaimNotFound", err)
+	}
+	if c := next(); c.LocationID != "A1" || len(c.Area.Claims) != 0 {
+		t.Fatalf("change after the failed release = %s with %v, want A1 without claims", c.LocationID, c.Area.Claims)
+	}
+
+	// 沒有東西過期就不通知
+	if _, err := m.Release(ctx, "A1", "r2"); !errors.Is(err, ErrClaimNotFound) {
+		t.Fatalf("second Release of r2 = %v, want ErrClaimNotFound", err)
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change %+v", c)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestAreaChangesInOrder 每個事件都是完整的狀態，訂閱者收到的順序要跟變動的順序一樣
+func TestAreaChangesInOrder(t *testing.T) {
+	bus := infra.New()
+	var claims []int
+	bus.Subscribe(EventAreaChanged, func(data interface{}) {
+		claims = append(claims, len(data.(AreaChange).Area.Claims))
+	})
+
+	m := NewAreaManager(nil, bus, testLogger(t), 0)
+	m.infoMap["A1"] = &Area{LocationID: "A1", Type: AreaStorage, Capacity: 1}
+
+	ctx := context.Background()
+	for range 50 {
+		if _, err := m.Claim(ctx, "A1", "r1", OccupantRobot, -1); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := m.Release(ctx, "A1", "r1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(claims) != 100 {
+		t.Fatalf("got %d changes, want 100", len(claims))
+	}
+	for i, n := range claims {
+		if n != 1-i%2 {
+			t.Fatalf("change %d has %d claims, want %d", i, n, 1-i%2)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package peripheral
+
+import "time"
+
+// EventStackChanged 在堆疊有變動時同步發出 (PublishSync)，
+// handler 在持有 Mu 的情況下被呼叫，不可再呼叫 manager 的方法，也不可阻塞
+const EventStackChanged = "stack.changed"
+
+// PeripheralStack 是 StackChange.Peripheral 的值，給訂閱端依週邊類型過濾
+const PeripheralStack = "stack"
+
+type ChangeType string
+
+const (
+	ChangeUpsert ChangeType = "upsert" // 新增或更新，Stack 為變動後的複本
+	ChangeDelete ChangeType = "delete"
+	ChangeReset  ChangeType = "reset" // 整份資料換掉 (例如切換腳本)，訂閱端應重新取完整快照
+	// ChangeTransfer 是貨物在兩個堆疊間移動，LocationID/Stack 是目的地，From* 是來源
+	ChangeTransfer ChangeType = "transfer"
+)
+
+type StackChange struct {
+	Type       ChangeType
+	Peripheral string
+	LocationID string
+	ScriptID   string
+	Stack      *YFYStack
+	Timestamp  time.Time
+
+	FromLocationID string
+	FromStack      *YFYStack
+}
+
+// changed 標記 locID 有變動並通知訂閱者，呼叫前必須持有 Mu
+func (m *YFYStackManager) changed(locID string) {
+	m.IsDirty = true
+	m.reindex(locID)
+	if m.bus == nil {
+		return
+	}
+
+	change := StackChange{
+		Type:       ChangeUpsert,
+		Peripheral: PeripheralStack,
+		LocationID: locID,
+		ScriptID:   m.scriptId,
+		Timestamp:  m.now(),
+	}
+
+	if s, ok := m.infoMap[locID]; ok {
+		c := s.clone()
+		change.Stack = &c
+	} else {
+		change.Type = ChangeDelete
+	}
+
+	m.bus.PublishSync(EventStackChanged, change)
+}
+
+// transferred 以一筆 ChangeTransfer 通知兩個堆疊的變動，呼叫前必須持有 Mu
+func (m *YFYStackManager) transferred(fromLoc, toLoc string) {
+	m.IsDirty = true
+	m.reindex(fromLoc)
+	m.reindex(toLoc)
+	if m.bus == nil {
+		return
+	}
+
+	from, to := m.infoMap[fromLoc].clone(), m.infoMap[toLoc].clone()
+	m.bus.PublishSync(EventStackChanged, StackChange{
+		Type:           ChangeTransfer,
+		Peripheral:     PeripheralStack,
+		LocationID:     toLoc,
+		ScriptID:       m.scriptId,
+		Stack:          &to,
+		Timestamp:      m.now(),
+		FromLocationID: fromLoc,
+		FromStack:      &from,
+	})
+}
+
+// reset 通知訂閱者整份資料已替換，呼叫前必須持有 Mu
+func (m *YFYStackManager) reset() {
+	m.IsDirty = true
+	if m.index != nil {
+		m.rebuildIndex(m.index.metaKey)
+	}
+	if m.bus == nil {
+		return
+	}
+
+	m.bus.PublishSync(EventStackChanged, StackChange{
+		Type:       ChangeReset,
+		Peripheral: PeripheralStack,
+		ScriptID:   m.scriptId,
+		Timestamp:  m.now(),
+	})
+}
@@ -1,12 +1,56 @@
 package peripheral
 
+// EventConveyorChanged 在輸送帶上的貨物變動後發出 (Publish)，內容是 Conveyor 的複本
+const EventConveyorChanged = "conveyor.changed"
 
+// Conveyor 是輸送帶，一次只放一個貨物
+type Conveyor struct {
+	ConveyorID   string `json:"conveyorId"`   // conveyor_config.id
+	PeripheralID string `json:"peripheralId"` // peripheral_name.id
+	Name         string `json:"name"`
+	Description  string `json:"description"`
 
-type Conveyor struct{
-	name string
-	description string
+	Disable bool   `json:"disable"`
+	Booker  string `json:"booker"`
 
-	disable bool
-	booker string
+	IsSpawnCargo bool `json:"isSpawnCargo"` // conveyor_config.is_spawn_cargo
+	ActiveShift  bool `json:"activeShift"`  // conveyor_config.active_shift
 
-}
\ No newline at end of file
+	Cargo *CargoData `json:"cargo,omitempty"` // nil 表示沒有貨物
+}
+
+// !! ------  呼叫下面的方法記得用上層的mutex --- !!
+
+func (c *Conveyor) clone() Conveyor {
+	out := *c
+	if c.Cargo != nil {
+		cargo := *c.Cargo
+		out.Cargo = &cargo
+	}
+	return out
+}
+
+// Put 把貨物放上輸送帶
+func (c *Conveyor) Put(cargo CargoData) error {
+	if c.Disable {
+		return ErrConveyorDisabled
+	}
+	if c.Cargo != nil {
+		return ErrConveyorOccupied
+	}
+	c.Cargo = &cargo
+	return nil
+}
+
+// Take 取走輸送帶上的貨物
+func (c *Conveyor) Take() (CargoData, error) {
+	if c.Disable {
+		return CargoData{}, ErrConveyorDisabled
+	}
+	if c.Cargo == nil {
+		return CargoData{}, ErrConveyorEmpty
+	}
+	cargo := *c.Cargo
+	c.Cargo = nil
+	return cargo, nil
+}
@@ -1,7 +1,215 @@
 package peripheral
 
-type ConveyorManager struct{
-	infoMap map[string]Conveyor
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"sync"
+)
 
+// ConveyorManager 管理目前腳本的輸送帶，只存在記憶體
+type ConveyorManager struct {
+	infoMap  map[string]*Conveyor // locationId -> 輸送帶
+	db       *db.Queries
+	bus      *infra.EventBus
+	logger   infra.Logger
+	scriptId string
+	clock    infra.Clock
+	// lifecycle 寫入貨物的狀態轉換，nil 表示只檢查不寫入
+	lifecycle *cargo.Recorder
+
+	Mu sync.Mutex
+}
+
+func NewConveyorManager(conn *sql.DB, bus *infra.EventBus, logger infra.Logger) *ConveyorManager {
+	m := &ConveyorManager{
+		infoMap: make(map[string]*Conveyor),
+		bus:     bus,
+		logger:  logger.With(infra.FieldComponent, "conveyor-manager"),
+		clock:   infra.NewRealClock(),
+	}
+	if conn != nil {
+		m.db = db.New(conn)
+	}
+	return m
+}
+
+// SetClock 替換放置時間的時間來源，nil 表示使用真實時間
+func (m *ConveyorManager) SetClock(c infra.Clock) {
+	if c == nil {
+		c = infra.NewRealClock()
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.clock = c
+}
+
+// SetConveyors 讓快照一併保存與還原輸送帶
+func (m *YFYStackManager) SetConveyors(cm *ConveyorManager) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.conveyors = cm
+}
+
+// Load 讀出腳本的所有輸送帶並整個替換，失敗時保留舊資料
+func (m *ConveyorManager) Load(ctx context.Context, scriptId string) error {
+	if m.db == nil {
+		return nil
+	}
+
+	rows, err := m.db.AllConveyor(ctx, sql.NullString{String: scriptId, Valid: true})
+	if err != nil {
+		return fmt.Errorf("load conveyors of script %s: %w", scriptId, err)
+	}
+
+	infoMap := make(map[string]*Conveyor, len(rows))
+	for _, r := range rows {
+		c := &Conveyor{
+			ConveyorID:   r.ConveyorID,
+			PeripheralID: r.PeripheralID,
+			Name:         r.PeripheralName.String,
+			Description:  r.PeripheralDesc,
+			Disable:      r.ConveyorDisable,
+			Booker:       noBooker,
+			IsSpawnCargo: r.IsSpawnCargo,
+			ActiveShift:  r.ActiveShift,
+		}
+		if r.HasCargo {
+			// conveyor_config 只記錄有沒有貨，不知道是哪一個
+			c.Cargo = &CargoData{Status: "AT_LOCATION"}
+		}
+		infoMap[r.Locationid] = c
+	}
+
+	m.Mu.Lock()
+	m.infoMap = infoMap
+	m.scriptId = scriptId
+	m.Mu.Unlock()
+	return nil
+}
+
+// Restore 以快照的輸送帶整個取代目前的輸送帶 (包含腳本 ID)
+func (m *ConveyorManager) Restore(snap *StateSnapshot) {
+	infoMap := make(map[string]*Conveyor, len(snap.Conveyors))
+	for locID, c := range snap.Conveyors {
+		c := c.clone()
+		infoMap[locID] = &c
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.infoMap = infoMap
+	m.scriptId = snap.ScriptID
 }
 
+// Snapshot 回傳目前所有輸送帶的複本
+func (m *ConveyorManager) Snapshot() map[string]Conveyor {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make(map[string]Conveyor, len(m.infoMap))
+	for locID, c := range m.infoMap {
+		out[locID] = c.clone()
+	}
+	return out
+}
+
+// changed 通知訂閱者，呼叫前必須持有 Mu
+func (m *ConveyorManager) changed(c *Conveyor) {
+	if m.bus != nil {
+		m.bus.Publish(EventConveyorChanged, c.clone())
+	}
+}
+
+// byPeripheral 找出 peripheral_name.id 對應的輸送帶，呼叫前必須持有 Mu
+func (m *ConveyorManager) byPeripheral(peripheralID string) (string, *Conveyor, error) {
+	for locID, c := range m.infoMap {
+		if c.PeripheralID == peripheralID {
+			return locID, c, nil
+		}
+	}
+	return "", nil, fmt.Errorf("%w: %s", ErrPeripheralNotFound, peripheralID)
+}
+
+// holds 回傳放著 cargoID 的輸送帶，沒有時回傳空字串
+func (m *ConveyorManager) holds(cargoID string) string {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	for locID, c := range m.infoMap {
+		if c.Cargo != nil && c.Cargo.ID == cargoID {
+			return locID
+		}
+	}
+	return ""
+}
+
+// LocationOf 回傳 peripheralID 輸送帶的 locationId，不是輸送帶回傳 ErrPeripheralNotFound
+func (m *ConveyorManager) LocationOf(peripheralID string) (string, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	locID, _, err := m.byPeripheral(peripheralID)
+	return locID, err
+}
+
+// SpawnCargo 在 peripheralID 的輸送帶上產生貨物，不是輸送帶回傳 ErrPeripheralNotFound
+func (m *ConveyorManager) SpawnCargo(ctx context.Context, peripheralID string, c CargoData) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	locID, conv, err := m.byPeripheral(peripheralID)
+	if err != nil {
+		return err
+	}
+	c, mv, err := arrive(c, cargo.EventSpawn, cargo.Holder{Kind: cargo.HolderConveyor, ID: locID})
+	if err != nil {
+		return fmt.Errorf("conveyor %s: %w", locID, err)
+	}
+	if c.PlacedAt.IsZero() {
+		c.PlacedAt = m.clock.Now()
+	}
+	next := conv.clone()
+	if err := next.Put(c); err != nil {
+		return fmt.Errorf("conveyor %s: %w", locID, err)
+	}
+	if err := recordMoves(ctx, m.lifecycle, mv); err != nil {
+		return fmt.Errorf("conveyor %s: %w", locID, err)
+	}
+	*conv = next
+	m.changed(conv)
+	return nil
+}
+
+// ShiftCargo 把 peripheralID 輸送帶上的貨物移出場域，不是輸送帶回傳 ErrPeripheralNotFound
+func (m *ConveyorManager) ShiftCargo(ctx context.Context, peripheralID string) (CargoData, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	locID, conv, err := m.byPeripheral(peripheralID)
+	if err != nil {
+		return CargoData{}, err
+	}
+	next := conv.clone()
+	taken, err := next.Take()
+	if err != nil {
+		return CargoData{}, fmt.Errorf("conveyor %s: %w", locID, err)
+	}
+	c, mv, err := leave(taken, cargo.EventShift, cargo.Holder{})
+	if err != nil {
+		return CargoData{}, fmt.Errorf("conveyor %s: %w", locID, err)
+	}
+	if err := recordMoves(ctx, m.lifecycle, mv); err != nil {
+		return CargoData{}, fmt.Errorf("conveyor %s: %w", locID, err)
+	}
+	*conv = next
+	m.changed(conv)
+	return c, nil
+}
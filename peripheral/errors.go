@@ -0,0 +1,28 @@
+package peripheral
+
+import "errors"
+
+var (
+	ErrStackNotFound = errors.New("stack not found")
+	ErrStackExists   = errors.New("stack already exists")
+	ErrStackDisabled = errors.New("stack is disabled")
+	ErrStackFull     = errors.New("stack is full")
+	ErrStackEmpty    = errors.New("stack is empty")
+	ErrCargoExists   = errors.New("cargo already on stack")
+	ErrCargoNotFound = errors.New("cargo not found")
+	ErrCargoNotOnTop = errors.New("cargo is not on top of stack")
+	ErrSameStack     = errors.New("source and destination are the same stack")
+	ErrCargoMoved    = errors.New("cargo is on another stack in the database")
+
+	ErrPeripheralNotFound = errors.New("peripheral not found")
+	ErrConveyorDisabled   = errors.New("conveyor is disabled")
+	ErrConveyorOccupied   = errors.New("conveyor already has cargo")
+	ErrConveyorEmpty      = errors.New("conveyor has no cargo")
+
+	ErrMachineNotFound  = errors.New("machine not found")
+	ErrWrongMachineType = errors.New("command not supported by this machine type")
+	ErrMachineBusy      = errors.New("machine is busy")
+	ErrMachineFault     = errors.New("machine is in fault state")
+	ErrPalletFull       = errors.New("pallet pattern is complete")
+	ErrPalletEmpty      = errors.New("pallet is empty")
+)
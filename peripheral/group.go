@@ -0,0 +1,228 @@
+package peripheral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"sort"
+)
+
+var ErrGroupNotFound = errors.New("peripheral group not found")
+
+// PeripheralGroup 是一筆 peripheral_group，成員是 Placement.GroupID 相同的堆疊
+type PeripheralGroup struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+}
+
+// GroupSummary 是群組的彙總狀態，讓派車端能以群組為單位決定要不要派車
+type GroupSummary struct {
+	GroupID string
+	Name    string
+
+	Members    int // 堆疊數
+	Disabled   int // 停用的堆疊數
+	Reserved   int // 被預約的堆疊數
+	Capacity   int // 所有堆疊的容量總和
+	CargoCount int
+	// FreeSlots 只算可以放貨的空位 (啟用且沒被預約的堆疊)
+	FreeSlots int
+}
+
+// Available 表示群組裡還有可以放貨的空位
+func (g GroupSummary) Available() bool {
+	return g.FreeSlots > 0
+}
+
+// loadGroups 讀出腳本的所有 peripheral_group
+func loadGroups(ctx context.Context, q *db.Queries, scriptId string) (map[string]PeripheralGroup, error) {
+	rows, err := q.ListPeripheralGroups(ctx, scriptId)
+	if err != nil {
+		return nil, fmt.Errorf("load peripheral groups of script %s: %w", scriptId, err)
+	}
+
+	groups := make(map[string]PeripheralGroup, len(rows))
+	for _, r := range rows {
+		groups[r.ID] = PeripheralGroup{
+			ID:          r.ID,
+			Name:        r.Name,
+			Description: r.Description.String,
+		}
+	}
+	return groups, nil
+}
+
+// Groups 回傳目前腳本的群組，依 ID 排序。
+// 沒有從資料庫載入 (例如用快照啟動) 時，從堆疊的 GroupID 整理出來，沒有名稱
+func (m *YFYStackManager) Groups() []PeripheralGroup {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	groups := m.groupMap()
+	out := make([]PeripheralGroup, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, g)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// groupMap 回傳已載入的群組加上堆疊上出現但沒載入的群組，呼叫前必須持有 Mu
+func (m *YFYStackManager) groupMap() map[string]PeripheralGroup {
+	groups := make(map[string]PeripheralGroup, len(m.groups))
+	for id, g := range m.groups {
+		groups[id] = g
+	}
+	for _, s := range m.infoMap {
+		id := s.Placement.GroupID
+		if _, ok := groups[id]; id != "" && !ok {
+			groups[id] = PeripheralGroup{ID: id}
+		}
+	}
+	return groups
+}
+
+// summarize 彙總群組狀態，呼叫前必須持有 Mu
+func (m *YFYStackManager) summarize(g PeripheralGroup) GroupSummary {
+	sum := GroupSummary{GroupID: g.ID, Name: g.Name}
+
+	for _, s := range m.infoMap {
+		if s.Placement.GroupID != g.ID {
+			continue
+		}
+
+		sum.Members++
+		sum.Capacity += s.StackCount
+		sum.CargoCount += len(s.Cargo)
+
+		switch {
+		case s.Disable:
+			sum.Disabled++
+		case s.reserved():
+			sum.Reserved++
+		default:
+			sum.FreeSlots += max(s.StackCount-len(s.Cargo), 0)
+		}
+	}
+	return sum
+}
+
+// GroupSummaries 回傳所有群組的彙總狀態，依 ID 排序
+func (m *YFYStackManager) GroupSummaries() []GroupSummary {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	return m.groupSummaries()
+}
+
+func (m *YFYStackManager) groupSummaries() []GroupSummary {
+	groups := m.groupMap()
+	out := make([]GroupSummary, 0, len(groups))
+	for _, g := range groups {
+		out = append(out, m.summarize(g))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].GroupID < out[j].GroupID })
+	return out
+}
+
+// GroupSummary 回傳單一群組的彙總狀態
+func (m *YFYStackManager) GroupSummary(groupID string) (GroupSummary, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	g, ok := m.groupMap()[groupID]
+	if !ok {
+		return GroupSummary{}, fmt.Errorf("%w: %s", ErrGroupNotFound, groupID)
+	}
+	return m.summarize(g), nil
+}
+
+// GroupHasFreeSlot 表示群組裡是否還有可以放貨的空位
+func (m *YFYStackManager) GroupHasFreeSlot(groupID string) (bool, error) {
+	sum, err := m.GroupSummary(groupID)
+	if err != nil {
+		return false, err
+	}
+	return sum.Available(), nil
+}
+
+// members 回傳群組的堆疊位置，依 LocationID 排序。呼叫前必須持有 Mu
+func (m *YFYStackManager) members(groupID string) ([]string, error) {
+	if _, ok := m.groupMap()[groupID]; !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, groupID)
+	}
+
+	var locIDs []string
+	for locID, s := range m.infoMap {
+		if s.Placement.GroupID == groupID {
+			locIDs = append(locIDs, locID)
+		}
+	}
+	sort.Strings(locIDs)
+	return locIDs, nil
+}
+
+// DisableGroup 停用 (或啟用) 群組裡的所有堆疊，狀態沒變的堆疊不記錄
+func (m *YFYStackManager) DisableGroup(ctx context.Context, groupID string, disable bool) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	locIDs, err := m.members(groupID)
+	if err != nil {
+		return err
+	}
+
+	typ := JournalStackEnable
+	if disable {
+		typ = JournalStackDisable
+	}
+
+	for _, locID := range locIDs {
+		s := m.infoMap[locID]
+		if s.Disable == disable {
+			continue
+		}
+		s.Disable = disable
+		m.recordStack(ctx, typ, locID)
+		m.changed(locID)
+	}
+	return nil
+}
+
+// ClearGroup 把群組裡所有堆疊的貨物移出場域，回傳被移除的貨物。
+// 有任何一個貨物不能移出時整個群組都不動
+func (m *YFYStackManager) ClearGroup(ctx context.Context, groupID string) ([]CargoData, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	locIDs, err := m.members(groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []CargoData
+	for _, locID := range locIDs {
+		all = append(all, m.infoMap[locID].Cargo...)
+	}
+	removed, moves, err := shiftAll(all)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordMoves(ctx, m.lifecycle, moves...); err != nil {
+		return nil, err
+	}
+
+	for _, locID := range locIDs {
+		s := m.infoMap[locID]
+		if len(s.Cargo) == 0 {
+			continue
+		}
+
+		s.UpdateAllCargo([]CargoData{})
+		m.record(ctx, JournalEntry{Type: JournalCargoReplaced, LocationID: locID, Reason: "clear group " + groupID})
+		m.changed(locID)
+	}
+	return removed, nil
+}
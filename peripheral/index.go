@@ -0,0 +1,218 @@
+package peripheral
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+)
+
+// CargoKey 是 FindCargo 的查詢方式
+type CargoKey string
+
+const (
+	ByCargoID  CargoKey = "id"
+	ByCustomID CargoKey = "customId"
+	ByMetadata CargoKey = "metadata" // 以 SetIndexedMetadataKey 指定的 metadata 欄位查詢
+)
+
+// CargoLocation 是 FindCargo 的結果
+type CargoLocation struct {
+	LocationID string
+	Position   int // 0 是最底層
+	Cargo      CargoData
+}
+
+// cargoIndex 記錄每個貨物在哪個堆疊，位置則在查詢時從堆疊內容算出 (堆疊很矮)
+type cargoIndex struct {
+	metaKey string
+
+	byID     map[string]string          // cargo ID -> locID
+	byCustom map[string]string          // custom ID -> cargo ID
+	byMeta   map[string]map[string]bool // metadata 值 -> cargo ID 集合
+	byLoc    map[string][]CargoData     // 每個堆疊上次建索引時的貨物，更新時用來移除舊索引
+}
+
+func newCargoIndex(metaKey string) *cargoIndex {
+	return &cargoIndex{
+		metaKey:  metaKey,
+		byID:     make(map[string]string),
+		byCustom: make(map[string]string),
+		byMeta:   make(map[string]map[string]bool),
+		byLoc:    make(map[string][]CargoData),
+	}
+}
+
+// metaValue 取出索引用的 metadata 值，字串會去掉引號，其他型別用原始 JSON
+func (ix *cargoIndex) metaValue(c CargoData) string {
+	if ix.metaKey == "" || len(c.Metadata) == 0 {
+		return ""
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(c.Metadata, &values); err != nil {
+		return ""
+	}
+
+	raw, ok := values[ix.metaKey]
+	if !ok || string(raw) == "null" {
+		return ""
+	}
+	if s, err := strconv.Unquote(string(raw)); err == nil {
+		return s
+	}
+	return string(raw)
+}
+
+// update 把 locID 的索引換成 cargo (nil 表示堆疊已刪除)
+func (ix *cargoIndex) update(locID string, cargo []CargoData) {
+	for _, c := range ix.byLoc[locID] {
+		// 同一個貨物可能已經被別的堆疊的索引蓋掉，只移除還指向這裡的
+		if ix.byID[c.ID] != locID {
+			continue
+		}
+		delete(ix.byID, c.ID)
+
+		if c.CustomID != "" && ix.byCustom[c.CustomID] == c.ID {
+			delete(ix.byCustom, c.CustomID)
+		}
+		if v := ix.metaValue(c); v != "" {
+			delete(ix.byMeta[v], c.ID)
+			if len(ix.byMeta[v]) == 0 {
+				delete(ix.byMeta, v)
+			}
+		}
+	}
+
+	if cargo == nil {
+		delete(ix.byLoc, locID)
+		return
+	}
+
+	ix.byLoc[locID] = cargo
+	for _, c := range cargo {
+		ix.byID[c.ID] = locID
+
+		if c.CustomID != "" {
+			ix.byCustom[c.CustomID] = c.ID
+		}
+		if v := ix.metaValue(c); v != "" {
+			if ix.byMeta[v] == nil {
+				ix.byMeta[v] = make(map[string]bool)
+			}
+			ix.byMeta[v][c.ID] = true
+		}
+	}
+}
+
+// cargoIDs 依查詢方式找出符合的貨物 ID
+func (ix *cargoIndex) cargoIDs(key CargoKey, value string) []string {
+	switch key {
+	case ByCargoID:
+		if _, ok := ix.byID[value]; ok {
+			return []string{value}
+		}
+	case ByCustomID:
+		if id, ok := ix.byCustom[value]; ok {
+			return []string{id}
+		}
+	case ByMetadata:
+		ids := make([]string, 0, len(ix.byMeta[value]))
+		for id := range ix.byMeta[value] {
+			ids = append(ids, id)
+		}
+		return ids
+	}
+	return nil
+}
+
+// reindex 重建 locID 的索引，呼叫前必須持有 Mu
+func (m *YFYStackManager) reindex(locID string) {
+	if m.index == nil {
+		return
+	}
+
+	var cargo []CargoData
+	if s, ok := m.infoMap[locID]; ok {
+		// Cargo 可能被原地修改 (Pop 會縮短 slice)，索引要留自己的複本
+		cargo = append([]CargoData{}, s.Cargo...)
+	}
+	m.index.update(locID, cargo)
+}
+
+// rebuildIndex 重建全部索引，呼叫前必須持有 Mu
+func (m *YFYStackManager) rebuildIndex(metaKey string) {
+	m.index = newCargoIndex(metaKey)
+	for locID := range m.infoMap {
+		m.reindex(locID)
+	}
+}
+
+// placed 找出貨物放在哪個位置，沒有放著時回傳空字串。堆疊查索引，
+// 輸送帶、貨架與疊棧機直接找。AMR 或手臂拿著的貨物不算，呼叫前必須持有 Mu
+func (m *YFYStackManager) placed(cargoID string) string {
+	if cargoID == "" {
+		return ""
+	}
+	if m.index == nil {
+		m.rebuildIndex("")
+	}
+	if locID, ok := m.index.byID[cargoID]; ok {
+		return "stack " + locID
+	}
+	if m.conveyors != nil {
+		if locID := m.conveyors.holds(cargoID); locID != "" {
+			return "conveyor " + locID
+		}
+	}
+	if m.shelves != nil {
+		if locID := m.shelves.holds(cargoID); locID != "" {
+			return "shelf " + locID
+		}
+	}
+	if m.machines != nil {
+		if locID := m.machines.holds(cargoID); locID != "" {
+			return "machine " + locID
+		}
+	}
+	return ""
+}
+
+// SetIndexedMetadataKey 設定 ByMetadata 查詢用的 metadata 欄位並重建索引
+func (m *YFYStackManager) SetIndexedMetadataKey(key string) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.rebuildIndex(key)
+}
+
+// FindCargo 找出貨物所在的堆疊與層數，找不到回傳 ErrCargoNotFound
+func (m *YFYStackManager) FindCargo(key CargoKey, value string) ([]CargoLocation, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.index == nil {
+		m.rebuildIndex("")
+	}
+
+	var out []CargoLocation
+	for _, id := range m.index.cargoIDs(key, value) {
+		locID := m.index.byID[id]
+
+		s, ok := m.infoMap[locID]
+		if !ok {
+			continue
+		}
+		for pos, c := range s.Cargo {
+			if c.ID == id {
+				out = append(out, CargoLocation{LocationID: locID, Position: pos, Cargo: c})
+				break
+			}
+		}
+	}
+
+	if len(out) == 0 {
+		return nil, ErrCargoNotFound
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationID < out[j].LocationID })
+	return out, nil
+}
@@ -0,0 +1,337 @@
+package peripheral
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kenmec/peripheral/jimmy/infra"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JournalEventType 是 journal 裡的領域事件種類
+type JournalEventType string
+
+const (
+	// JournalStateLoaded 記錄整份狀態 (啟動、切換腳本、還原快照)，replay 從這裡重新開始
+	JournalStateLoaded  JournalEventType = "StateLoaded"
+	JournalStackAdded   JournalEventType = "StackAdded"
+	JournalStackDeleted JournalEventType = "StackDeleted"
+	JournalStackUpdated JournalEventType = "StackUpdated"
+	JournalStackDisable JournalEventType = "StackDisabled"
+	JournalStackEnable  JournalEventType = "StackEnabled"
+	JournalCargoPushed  JournalEventType = "CargoPushed"
+	JournalCargoPopped  JournalEventType = "CargoPopped"
+	// JournalCargoReplaced 是 UpdateCargo 整份替換貨物
+	JournalCargoReplaced JournalEventType = "CargoReplaced"
+	// JournalCargoTransferred 是貨物從 LocationID 的最上層移到 ToLocationID 的最上層
+	JournalCargoTransferred JournalEventType = "CargoTransferred"
+	// JournalStackReserved/JournalStackReleased 是 Booker 變動，Stack 帶變動後的堆疊
+	JournalStackReserved JournalEventType = "StackReserved"
+	JournalStackReleased JournalEventType = "StackReleased"
+)
+
+var (
+	ErrJournalMismatch    = errors.New("journal does not match state")
+	ErrJournalPartialLine = errors.New("journal ends with a partial line")
+)
+
+// JournalEntry 是 journal 的一行 (JSON Lines)。
+// Stack 是事件發生後該堆疊的完整內容 (StackAdded/StackUpdated/StackDisabled/StackEnabled/StackReserved/StackReleased)，
+// Stacks 只有 StateLoaded 才有。
+// Timestamp 是真實時間 (replay 的 until 用它比較)，SimTime 是時間軸倍速下的模擬時間，沒有設定時鐘時省略
+type JournalEntry struct {
+	Seq          uint64              `json:"seq"`
+	Type         JournalEventType    `json:"type"`
+	Timestamp    time.Time           `json:"timestamp"`
+	SimTime      time.Time           `json:"simTime,omitzero"`
+	Actor        string              `json:"actor"`
+	ScriptID     string              `json:"scriptId"`
+	LocationID   string              `json:"locationId,omitempty"`
+	ToLocationID string              `json:"toLocationId,omitempty"`
+	Cargo        []CargoData         `json:"cargo,omitempty"`
+	Stack        *YFYStack           `json:"stack,omitempty"`
+	Stacks       map[string]YFYStack `json:"stacks,omitempty"`
+	Reason       string              `json:"reason,omitempty"`
+}
+
+// Journal 是只會往後寫的事件紀錄檔
+type Journal struct {
+	mu  sync.Mutex
+	f   *os.File
+	seq uint64
+}
+
+// OpenJournal 開啟 (或建立) journal，序號接續檔案裡最後一筆。
+// 當機留下寫到一半的最後一行會先截掉，否則下一筆會接在殘缺的內容後面
+func OpenJournal(path string, logger infra.Logger) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create journal dir: %w", err)
+	}
+
+	var last uint64
+	end, err := readJournal(path, func(e JournalEntry) error {
+		last = e.Seq
+		return nil
+	})
+	partial := errors.Is(err, ErrJournalPartialLine)
+	if err != nil && !partial && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open journal: %w", err)
+	}
+
+	if partial {
+		info, err := f.Stat()
+		if err == nil {
+			err = f.Truncate(end)
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("truncate journal: %w", err)
+		}
+		logger.With(infra.FieldComponent, "journal").
+			Warn("journal %s ends with a partial line, discarded %d bytes after offset %d", path, info.Size()-end, end)
+	}
+
+	return &Journal{f: f, seq: last}, nil
+}
+
+// Append 補上序號後寫入一行
+func (j *Journal) Append(e JournalEntry) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	e.Seq = j.seq + 1
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encode journal entry: %w", err)
+	}
+
+	if _, err := j.f.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("write journal: %w", err)
+	}
+	j.seq = e.Seq
+	return nil
+}
+
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.f.Close()
+}
+
+// ReadJournal 依序讀出每一筆，fn 回傳 io.EOF 會提早結束且不當成錯誤。
+// 沒有換行的行 (當機時寫到一半) 不會交給 fn，回傳 ErrJournalPartialLine
+func ReadJournal(path string, fn func(JournalEntry) error) error {
+	_, err := readJournal(path, fn)
+	return err
+}
+
+// readJournal 同 ReadJournal，另外回傳最後一個完整行結束的位置
+func readJournal(path string, fn func(JournalEntry) error) (int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var end int64
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				return end, fmt.Errorf("%w: line %d has %d bytes without newline", ErrJournalPartialLine, line, len(data))
+			}
+			return end, nil
+		}
+		if err != nil {
+			return end, fmt.Errorf("read journal: %w", err)
+		}
+
+		var e JournalEntry
+		if err := json.Unmarshal(data, &e); err != nil {
+			return end, fmt.Errorf("journal line %d: %w", line, err)
+		}
+		end += int64(len(data))
+
+		if err := fn(e); err != nil {
+			if errors.Is(err, io.EOF) {
+				return end, nil
+			}
+			return end, err
+		}
+	}
+}
+
+// ReplayJournal 從頭套用 journal 到 until (含) 為止，until 為零值表示全部。
+// 寫到一半的最後一行不套用
+func ReplayJournal(path string, until time.Time) (*StateSnapshot, error) {
+	snap := &StateSnapshot{
+		Version: SnapshotVersion,
+		Stacks:  map[string]YFYStack{},
+	}
+
+	err := ReadJournal(path, func(e JournalEntry) error {
+		if !until.IsZero() && e.Timestamp.After(until) {
+			return io.EOF
+		}
+		return ApplyJournal(snap, e)
+	})
+	if err != nil && !errors.Is(err, ErrJournalPartialLine) {
+		return nil, err
+	}
+
+	return snap, nil
+}
+
+// ApplyJournal 把一筆事件套用到 snap
+func ApplyJournal(snap *StateSnapshot, e JournalEntry) error {
+	snap.CreatedAt = e.Timestamp
+
+	switch e.Type {
+	case JournalStateLoaded:
+		snap.ScriptID = e.ScriptID
+		snap.Stacks = make(map[string]YFYStack, len(e.Stacks))
+		for locID, s := range e.Stacks {
+			snap.Stacks[locID] = s.clone()
+		}
+		return nil
+
+	case JournalStackAdded, JournalStackUpdated, JournalStackDisable, JournalStackEnable,
+		JournalStackReserved, JournalStackReleased:
+		if e.Stack == nil {
+			return fmt.Errorf("%w: seq %d %s without stack", ErrJournalMismatch, e.Seq, e.Type)
+		}
+		snap.Stacks[e.LocationID] = e.Stack.clone()
+		return nil
+
+	case JournalStackDeleted:
+		delete(snap.Stacks, e.LocationID)
+		return nil
+
+	case JournalCargoTransferred:
+		return applyTransfer(snap, e)
+	}
+
+	s, ok := snap.Stacks[e.LocationID]
+	if !ok {
+		return fmt.Errorf("%w: seq %d %s on unknown stack %s", ErrJournalMismatch, e.Seq, e.Type, e.LocationID)
+	}
+	s = s.clone()
+
+	switch e.Type {
+	case JournalCargoPushed:
+		s.Cargo = append(s.Cargo, e.Cargo...)
+
+	case JournalCargoPopped:
+		n := len(s.Cargo) - len(e.Cargo)
+		if n < 0 {
+			return fmt.Errorf("%w: seq %d pops more cargo than %s holds", ErrJournalMismatch, e.Seq, e.LocationID)
+		}
+		for i, c := range e.Cargo {
+			if s.Cargo[n+i].ID != c.ID {
+				return fmt.Errorf("%w: seq %d pops %s but top of %s is %s", ErrJournalMismatch, e.Seq, c.ID, e.LocationID, s.Cargo[n+i].ID)
+			}
+		}
+		s.Cargo = s.Cargo[:n]
+
+	case JournalCargoReplaced:
+		s.Cargo = append([]CargoData(nil), e.Cargo...)
+
+	default:
+		return fmt.Errorf("%w: seq %d has unknown type %q", ErrJournalMismatch, e.Seq, e.Type)
+	}
+
+	snap.Stacks[e.LocationID] = s
+	return nil
+}
+
+func applyTransfer(snap *StateSnapshot, e JournalEntry) error {
+	from, ok := snap.Stacks[e.LocationID]
+	if !ok {
+		return fmt.Errorf("%w: seq %d transfer from unknown stack %s", ErrJournalMismatch, e.Seq, e.LocationID)
+	}
+	to, ok := snap.Stacks[e.ToLocationID]
+	if !ok {
+		return fmt.Errorf("%w: seq %d transfer to unknown stack %s", ErrJournalMismatch, e.Seq, e.ToLocationID)
+	}
+	if len(e.Cargo) != 1 {
+		return fmt.Errorf("%w: seq %d transfer of %d cargo", ErrJournalMismatch, e.Seq, len(e.Cargo))
+	}
+
+	from, to = from.clone(), to.clone()
+	n := len(from.Cargo) - 1
+	if n < 0 || from.Cargo[n].ID != e.Cargo[0].ID {
+		return fmt.Errorf("%w: seq %d transfers %s which is not on top of %s", ErrJournalMismatch, e.Seq, e.Cargo[0].ID, e.LocationID)
+	}
+
+	// 用 journal 裡的貨物，PlacedAt 是移動的時間
+	to.Cargo = append(to.Cargo, e.Cargo[0])
+	from.Cargo = from.Cargo[:n]
+
+	snap.Stacks[e.LocationID] = from
+	snap.Stacks[e.ToLocationID] = to
+	return nil
+}
+
+// SetJournal 開始把變動寫到 j，並先寫一筆目前的完整狀態當作 replay 的起點
+func (m *YFYStackManager) SetJournal(ctx context.Context, j *Journal) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.journal = j
+	m.recordState(ctx, "startup")
+}
+
+// record 寫入 journal，呼叫前必須持有 Mu。
+// 寫入失敗只記 log，記憶體中的狀態已經改了，不能讓操作失敗
+func (m *YFYStackManager) record(ctx context.Context, e JournalEntry) {
+	if m.journal == nil {
+		return
+	}
+
+	e.Timestamp = time.Now()
+	if m.clock != nil {
+		e.SimTime = m.clock.Now()
+	}
+	e.Actor = infra.ActorFrom(ctx)
+	e.ScriptID = m.scriptId
+
+	if err := m.journal.Append(e); err != nil {
+		m.logger.With(infra.FieldLocationID, e.LocationID).
+			Error("journal %s failed: %v", e.Type, err)
+	}
+}
+
+// recordStack 記錄 locID 變動後的完整堆疊，呼叫前必須持有 Mu
+func (m *YFYStackManager) recordStack(ctx context.Context, typ JournalEventType, locID string) {
+	e := JournalEntry{Type: typ, LocationID: locID}
+	if s, ok := m.infoMap[locID]; ok {
+		c := s.clone()
+		e.Stack = &c
+	}
+	m.record(ctx, e)
+}
+
+// recordState 記錄整份狀態，呼叫前必須持有 Mu
+func (m *YFYStackManager) recordState(ctx context.Context, reason string) {
+	if m.journal == nil {
+		return
+	}
+
+	stacks := make(map[string]YFYStack, len(m.infoMap))
+	for locID, s := range m.infoMap {
+		stacks[locID] = s.clone()
+	}
+	m.record(ctx, JournalEntry{Type: JournalStateLoaded, Stacks: stacks, Reason: reason})
+}
@@ -0,0 +1,102 @@
+package peripheral
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/infra"
+)
+
+func testLogger(t *testing.T) infra.Logger {
+	t.Helper()
+	logger, err := infra.NewSlogLogger(io.Discard, "error", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return logger
+}
+
+func TestOpenJournalTruncatesPartialLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cargo.jsonl")
+
+	j, err := OpenJournal(path, testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, typ := range []JournalEventType{JournalStackAdded, JournalStackDeleted} {
+		if err := j.Append(JournalEntry{Type: typ, LocationID: "L1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	j.Close()
+
+	// 模擬當機時第三筆只寫了一半
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":3,"type":"Stack`)
+	f.Close()
+
+	err = ReadJournal(path, func(JournalEntry) error { return nil })
+	if !errors.Is(err, ErrJournalPartialLine) {
+		t.Fatalf("ReadJournal = %v, want ErrJournalPartialLine", err)
+	}
+
+	j, err = OpenJournal(path, testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Append(JournalEntry{Type: JournalStackAdded, LocationID: "L2"}); err != nil {
+		t.Fatal(err)
+	}
+	j.Close()
+
+	var seqs []uint64
+	err = ReadJournal(path, func(e JournalEntry) error {
+		seqs = append(seqs, e.Seq)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadJournal after reopen = %v", err)
+	}
+	if len(seqs) != 3 || seqs[2] != 3 {
+		t.Fatalf("seqs = %v, want [1 2 3]", seqs)
+	}
+}
+
+// TestJournalWallClock 時間軸用倍速時鐘時，Timestamp 仍是真實時間，模擬時間記在 SimTime
+func TestJournalWallClock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cargo.jsonl")
+	j, err := OpenJournal(path, testLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sim := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := testStacks(t, map[string]*YFYStack{"L1": stack(1)})
+	m.clock = infra.NewManualClock(sim)
+
+	before := time.Now()
+	m.SetJournal(context.Background(), j)
+	j.Close()
+
+	var got []JournalEntry
+	if err := ReadJournal(path, func(e JournalEntry) error {
+		got = append(got, e)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("got %d entries, want 1", len(got))
+	}
+	if e := got[0]; e.Timestamp.Before(before) || e.Timestamp.After(time.Now()) || !e.SimTime.Equal(sim) {
+		t.Errorf("Timestamp, SimTime = %v, %v, want wall clock and %v", e.Timestamp, e.SimTime, sim)
+	}
+}
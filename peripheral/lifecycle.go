@@ -0,0 +1,115 @@
+package peripheral
+
+import (
+	"context"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// SetLifecycle 讓堆疊上貨物的狀態轉換寫回 cargo_info 與 cargo_history，nil 表示只檢查不寫入
+func (m *YFYStackManager) SetLifecycle(r *cargo.Recorder) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.lifecycle = r
+}
+
+// SetLifecycle 讓輸送帶上貨物的狀態轉換寫回 cargo_info 與 cargo_history，nil 表示只檢查不寫入
+func (m *ConveyorManager) SetLifecycle(r *cargo.Recorder) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.lifecycle = r
+}
+
+// SetLifecycle 讓疊棧機上貨物的狀態轉換寫回 cargo_info 與 cargo_history，nil 表示只檢查不寫入
+func (m *MachineManager) SetLifecycle(r *cargo.Recorder) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.lifecycle = r
+}
+
+// SetLifecycle 讓貨架上貨物的狀態轉換寫回 cargo_info 與 cargo_history，nil 表示只檢查不寫入
+func (m *ShelfManager) SetLifecycle(r *cargo.Recorder) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.lifecycle = r
+}
+
+// arrive 檢查貨物放到位置 to 是否合法，回傳更新狀態與 holder 後的貨物。
+// 沒有狀態的貨物當作還沒生成
+func arrive(c CargoData, ev cargo.Event, to cargo.Holder) (CargoData, cargo.Move, error) {
+	from := cargo.Status(c.Status)
+	if from == "" {
+		from = cargo.StatusPreSpawn
+	}
+	return moveCargo(c, from, ev, to)
+}
+
+// leave 檢查貨物離開位置是否合法。已經放在位置上卻沒有狀態的貨物當作 AT_LOCATION
+func leave(c CargoData, ev cargo.Event, to cargo.Holder) (CargoData, cargo.Move, error) {
+	from := cargo.Status(c.Status)
+	if from == "" {
+		from = cargo.StatusAtLocation
+	}
+	return moveCargo(c, from, ev, to)
+}
+
+func moveCargo(c CargoData, from cargo.Status, ev cargo.Event, to cargo.Holder) (CargoData, cargo.Move, error) {
+	mv, err := cargo.Plan(c.ID, from, ev, to)
+	if err != nil {
+		return CargoData{}, cargo.Move{}, err
+	}
+	c.Status = string(mv.To)
+	c.Holder = to
+	return c, mv, nil
+}
+
+// robotOf 是 offload 後拿著貨物的 AMR，用 context 的操作者
+func robotOf(ctx context.Context) cargo.Holder {
+	return cargo.Holder{Kind: cargo.HolderRobot, ID: infra.ActorFrom(ctx)}
+}
+
+// shiftAll 檢查位置上的貨物能不能全部移出場域，回傳移出後的貨物。有一個不行就都不動
+func shiftAll(cs []CargoData) ([]CargoData, []cargo.Move, error) {
+	shifted := make([]CargoData, 0, len(cs))
+	moves := make([]cargo.Move, 0, len(cs))
+	for _, c := range cs {
+		c, mv, err := leave(c, cargo.EventShift, cargo.Holder{})
+		if err != nil {
+			return nil, nil, err
+		}
+		shifted = append(shifted, c)
+		moves = append(moves, mv)
+	}
+	return shifted, moves, nil
+}
+
+// recordMoves 在同一個交易寫入狀態轉換，呼叫端在修改記憶體前呼叫，失敗時記憶體不動。
+// 不知道是哪一個的貨物 (沒有 ID) 不寫入
+func recordMoves(ctx context.Context, r *cargo.Recorder, moves ...cargo.Move) error {
+	if r == nil {
+		return nil
+	}
+	return r.Record(ctx, knownMoves(moves)...)
+}
+
+// recordLevel 同 recordMoves，並在同一個交易寫入貨架層 l 的 hasCargo，l.Cargo 必須已經是修改後的內容
+func recordLevel(ctx context.Context, r *cargo.Recorder, l *ShelfLevel, moves ...cargo.Move) error {
+	if r == nil {
+		return nil
+	}
+	return r.RecordShelf(ctx, l.ConfigID, len(l.Cargo) > 0, knownMoves(moves)...)
+}
+
+func knownMoves(moves []cargo.Move) []cargo.Move {
+	known := make([]cargo.Move, 0, len(moves))
+	for _, mv := range moves {
+		if mv.CargoID != "" {
+			known = append(known, mv)
+		}
+	}
+	return known
+}
@@ -0,0 +1,195 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// execDB 記下每一個寫入的參數，所有更新都當作成功，查詢都沒有資料
+type execDB struct {
+	mu    sync.Mutex
+	execs map[string][][]driver.Value // sqlc 查詢名稱 -> 每次的參數
+}
+
+func (d *execDB) Connect(context.Context) (driver.Conn, error) { return execConn{d}, nil }
+func (d *execDB) Driver() driver.Driver                        { return d }
+func (d *execDB) Open(string) (driver.Conn, error)             { return execConn{d}, nil }
+
+// args 回傳 name 查詢每次寫入的參數
+func (d *execDB) args(name string) [][]driver.Value {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.execs[name]
+}
+
+type execConn struct{ d *execDB }
+
+func (c execConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c execConn) Close() error              { return nil }
+func (c execConn) Begin() (driver.Tx, error) { return emptyTx{}, nil }
+
+func (c execConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return emptyRows{}, nil
+}
+
+func (c execConn) ExecContext(_ context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	name, _, _ := strings.Cut(strings.TrimPrefix(query, "-- name: "), " ")
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		values[i] = a.Value
+	}
+
+	c.d.mu.Lock()
+	defer c.d.mu.Unlock()
+	if c.d.execs == nil {
+		c.d.execs = make(map[string][][]driver.Value)
+	}
+	c.d.execs[name] = append(c.d.execs[name], values)
+	return driver.RowsAffected(1), nil
+}
+
+// testRecorder 讓 lifecycle 寫到 execDB
+func testRecorder(t *testing.T) (*cargo.Recorder, *execDB) {
+	t.Helper()
+	fake := &execDB{}
+	conn := sql.OpenDB(fake)
+	t.Cleanup(func() { conn.Close() })
+	return cargo.NewRecorder(conn, testLogger(t)), fake
+}
+
+func TestStackLifecycle(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{"L1": stack(3)})
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	if err := m.PushCargo(ctx, "L1", CargoData{ID: "c1", Status: string(cargo.StatusPreSpawn)}); err != nil {
+		t.Fatal(err)
+	}
+	top := m.infoMap["L1"].Cargo[0]
+	if top.Status != string(cargo.StatusAtLocation) || top.Holder != (cargo.Holder{Kind: cargo.HolderStack, ID: "L1"}) {
+		t.Fatalf("pushed cargo is %s on %s, want AT_LOCATION on stack:L1", top.Status, top.Holder)
+	}
+
+	c, err := m.PopCargo(ctx, "L1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Status != string(cargo.StatusOnAMR) || c.Holder != (cargo.Holder{Kind: cargo.HolderRobot, ID: "amr-1"}) {
+		t.Fatalf("popped cargo is %s on %s, want ON_AMR on robot:amr-1", c.Status, c.Holder)
+	}
+
+	// 從 AMR 放回去是 load
+	if err := m.PushCargo(ctx, "L1", c); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.infoMap["L1"].Cargo[0].Status; got != string(cargo.StatusAtLocation) {
+		t.Fatalf("loaded cargo is %s, want AT_LOCATION", got)
+	}
+}
+
+func TestStackLifecycleRejectsIllegalMoves(t *testing.T) {
+	// 還沒生成的貨物不該出現在堆疊上，就算出現了也不能被取走
+	m := testStacks(t, map[string]*YFYStack{
+		"L1": stack(3, CargoData{ID: "c1", Status: string(cargo.StatusPreSpawn)}),
+	})
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	if _, err := m.PopCargo(ctx, "L1"); !errors.Is(err, cargo.ErrIllegalTransition) {
+		t.Fatalf("PopCargo of PRE_SPAWN cargo = %v, want ErrIllegalTransition", err)
+	}
+	if n := len(m.infoMap["L1"].Cargo); n != 1 {
+		t.Fatalf("stack has %d cargo after the rejected pop, want 1", n)
+	}
+
+	// 移出場域的貨物不能再放回來
+	if err := m.PushCargo(ctx, "L1", CargoData{ID: "c2", Status: string(cargo.StatusShift)}); !errors.Is(err, cargo.ErrIllegalTransition) {
+		t.Fatalf("PushCargo of SHIFT cargo = %v, want ErrIllegalTransition", err)
+	}
+	if n := len(m.infoMap["L1"].Cargo); n != 1 {
+		t.Fatalf("stack has %d cargo after the rejected push, want 1", n)
+	}
+}
+
+func TestStackLifecycleStackConfigID(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{
+		"L1": {StackID: "S1", StackCount: 3},
+		"L2": {StackID: "S2", StackCount: 3, Cargo: []CargoData{{ID: "c2", Status: string(cargo.StatusAtLocation)}}},
+	})
+	r, fake := testRecorder(t)
+	m.SetLifecycle(r)
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	if err := m.PushCargo(ctx, "L1", CargoData{ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	c, err := m.PopCargo(ctx, "L1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateCargo(ctx, "L2", []CargoData{c}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 放上堆疊寫入 stack_config_id，取走或移出場域時清除，重新載入時才不會回到原本的堆疊
+	want := []struct {
+		id    string
+		stack driver.Value
+	}{
+		{"c1", "S1"}, // spawn
+		{"c1", nil},  // offload
+		{"c1", "S2"}, // load
+		{"c2", nil},  // shift
+	}
+	got := fake.args("UpdateCargoLifecycle")
+	if len(got) != len(want) {
+		t.Fatalf("UpdateCargoLifecycle ran %d times, want %d", len(got), len(want))
+	}
+	for i, w := range want {
+		// 參數依序是 status, owner, stack_config_id, shelfConfigId, id, from_status
+		if got[i][4] != w.id || got[i][2] != w.stack {
+			t.Errorf("update %d = cargo %v on stack %v, want %s on %v", i, got[i][4], got[i][2], w.id, w.stack)
+		}
+	}
+}
+
+func TestPushRejectsCargoPlacedElsewhere(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{
+		"L1": stack(3, CargoData{ID: "c1", Status: string(cargo.StatusAtLocation)}),
+		"L2": stack(3),
+	})
+	m.SetConveyors(testConveyors(t, map[string]*Conveyor{
+		"C1": {Booker: noBooker, Cargo: &CargoData{ID: "c2", Status: string(cargo.StatusAtLocation)}},
+	}))
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	// 已經在別的堆疊或輸送帶上的貨物不能再放到 L2
+	for _, id := range []string{"c1", "c2"} {
+		if err := m.PushCargo(ctx, "L2", CargoData{ID: id, Status: string(cargo.StatusOnAMR)}); !errors.Is(err, ErrCargoExists) {
+			t.Errorf("PushCargo of %s = %v, want ErrCargoExists", id, err)
+		}
+		if err := m.UpdateCargo(ctx, "L2", []CargoData{{ID: id, Status: string(cargo.StatusOnAMR)}}); !errors.Is(err, ErrCargoExists) {
+			t.Errorf("UpdateCargo with %s = %v, want ErrCargoExists", id, err)
+		}
+	}
+	if n := len(m.infoMap["L2"].Cargo); n != 0 {
+		t.Fatalf("L2 has %d cargo after the rejected pushes, want 0", n)
+	}
+
+	// 取走之後就可以放到別的堆疊
+	c, err := m.PopCargo(ctx, "L1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PushCargo(ctx, "L2", c); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,113 @@
+package peripheral
+
+import (
+	"math"
+	"time"
+)
+
+// EventMachineChanged 在機台狀態改變後發出 (Publish)，內容是 Machine 的複本
+const EventMachineChanged = "machine.changed"
+
+// MachineType 是模擬的機台種類，值與 Loc.areaType 相同
+type MachineType string
+
+const (
+	MachineRoboticArm  MachineType = "ROBOTIC_ARM"
+	MachinePalletizer  MachineType = "PALLETIZER"
+	MachineRotateTable MachineType = "ROTATE_TABLE"
+)
+
+type MachineState string
+
+const (
+	MachineIdle  MachineState = "idle"
+	MachineBusy  MachineState = "busy"  // 手臂取放中、旋轉台旋轉中
+	MachineFull  MachineState = "full"  // 疊棧機已疊滿，等待放行
+	MachineFault MachineState = "fault" // 手臂放不下貨，等待 Reset
+)
+
+// Machine 是一台模擬機台，依 Type 只有對應的欄位有值
+type Machine struct {
+	LocationID string       `json:"locationId"`
+	Name       string       `json:"name"`
+	Type       MachineType  `json:"type"`
+	State      MachineState `json:"state"`
+	Error      string       `json:"error,omitempty"` // 最後一次失敗的原因
+	UpdatedAt  time.Time    `json:"updatedAt,omitzero"`
+
+	Arm         *Arm         `json:"arm,omitempty"`
+	Palletizer  *Palletizer  `json:"palletizer,omitempty"`
+	RotateTable *RotateTable `json:"rotateTable,omitempty"`
+}
+
+// Arm 是機械手臂，從 From 取貨放到 To，一個循環花 CycleTime，取與放各佔一半
+type Arm struct {
+	CycleTime time.Duration `json:"cycleTime"`
+	From      string        `json:"from,omitempty"`
+	To        string        `json:"to,omitempty"`
+	Holding   *CargoData    `json:"holding,omitempty"`
+	Cycles    int           `json:"cycles"` // 完成的取放次數
+}
+
+// Palletizer 是疊棧機，每層 LayerSize 個貨物，疊滿 PatternCount 層後等待放行
+type Palletizer struct {
+	PatternCount int         `json:"patternCount"`
+	LayerSize    int         `json:"layerSize"`
+	Cargo        []CargoData `json:"cargo"` // 目前板上的貨物，由下往上
+	Pallets      int         `json:"pallets"`
+}
+
+// Layers 是已經疊完的層數
+func (p *Palletizer) Layers() int {
+	return len(p.Cargo) / p.LayerSize
+}
+
+func (p *Palletizer) capacity() int {
+	return p.PatternCount * p.LayerSize
+}
+
+// RotateTable 是旋轉台，角度單位是度。沒有指定角度時轉到 Home (Direction.yaw)
+type RotateTable struct {
+	Angle  float64 `json:"angle"`
+	Target float64 `json:"target"` // 旋轉中的目標，停止時等於 Angle
+	Home   float64 `json:"home"`
+	Speed  float64 `json:"speed"` // 度/秒
+}
+
+// normalizeAngle 把角度換到 [0, 360)
+func normalizeAngle(a float64) float64 {
+	a = math.Mod(a, 360)
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+// rotateDuration 是往較近的方向從 from 轉到 to 所需的時間
+func (t *RotateTable) rotateDuration(from, to float64) time.Duration {
+	d := math.Abs(normalizeAngle(to) - normalizeAngle(from))
+	d = min(d, 360-d)
+	return time.Duration(d / t.Speed * float64(time.Second))
+}
+
+func (mc *Machine) clone() Machine {
+	c := *mc
+	if mc.Arm != nil {
+		arm := *mc.Arm
+		if arm.Holding != nil {
+			h := *arm.Holding
+			arm.Holding = &h
+		}
+		c.Arm = &arm
+	}
+	if mc.Palletizer != nil {
+		p := *mc.Palletizer
+		p.Cargo = append([]CargoData(nil), p.Cargo...)
+		c.Palletizer = &p
+	}
+	if mc.RotateTable != nil {
+		t := *mc.RotateTable
+		c.RotateTable = &t
+	}
+	return c
+}
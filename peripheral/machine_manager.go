@@ -0,0 +1,519 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	stackpb "kenmec/peripheral/jimmy/protoGen"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MachineParams 是資料庫沒有記錄的機台參數，所有同種機台共用
+type MachineParams struct {
+	ArmCycleTime time.Duration
+	PatternCount int     // 疊棧機疊滿一板的層數
+	LayerSize    int     // 疊棧機每層的貨物數
+	RotateSpeed  float64 // 旋轉台速度，度/秒
+}
+
+// MachineManager 模擬目前腳本的機械手臂、疊棧機與旋轉台，只存在記憶體。
+// 需要時間的指令立刻回傳 busy，完成後在背景更新狀態並發出 EventMachineChanged
+type MachineManager struct {
+	infoMap map[string]*Machine // locationId -> 機台
+	db      *db.Queries
+	stacks  *YFYStackManager
+	bus     *infra.EventBus
+	logger  infra.Logger
+	clock   infra.Clock
+	params  MachineParams
+	// lifecycle 寫入疊棧機上貨物的狀態轉換，nil 表示只檢查不寫入
+	lifecycle *cargo.Recorder
+
+	Mu sync.Mutex
+}
+
+func NewMachineManager(conn *sql.DB, stacks *YFYStackManager, bus *infra.EventBus, logger infra.Logger, params MachineParams) *MachineManager {
+	m := &MachineManager{
+		infoMap: make(map[string]*Machine),
+		stacks:  stacks,
+		bus:     bus,
+		logger:  logger.With(infra.FieldComponent, "machine-manager"),
+		clock:   infra.NewRealClock(),
+		params:  params,
+	}
+	if conn != nil {
+		m.db = db.New(conn)
+	}
+	return m
+}
+
+// SetClock 替換取放與旋轉用的時間來源，nil 表示使用真實時間
+func (m *MachineManager) SetClock(c infra.Clock) {
+	if c == nil {
+		c = infra.NewRealClock()
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.clock = c
+}
+
+// SetMachines 讓 ToProto 一併輸出機台狀態
+func (m *YFYStackManager) SetMachines(mm *MachineManager) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.machines = mm
+}
+
+// Load 讀出腳本所有 areaType 是機台的位置並整個替換，進行中的指令會被放棄
+func (m *MachineManager) Load(ctx context.Context, scriptId string) error {
+	if m.db == nil {
+		return nil
+	}
+
+	rows, err := m.db.ListMachines(ctx, sql.NullString{String: scriptId, Valid: true})
+	if err != nil {
+		return fmt.Errorf("load machines of script %s: %w", scriptId, err)
+	}
+
+	infoMap := make(map[string]*Machine, len(rows))
+	for _, r := range rows {
+		mc := &Machine{
+			LocationID: r.Locationid,
+			Name:       r.Name.String,
+			Type:       MachineType(r.Areatype),
+			State:      MachineIdle,
+		}
+		switch mc.Type {
+		case MachineRoboticArm:
+			mc.Arm = &Arm{CycleTime: m.params.ArmCycleTime}
+		case MachinePalletizer:
+			mc.Palletizer = &Palletizer{PatternCount: m.params.PatternCount, LayerSize: m.params.LayerSize}
+		case MachineRotateTable:
+			home := normalizeAngle(r.Yaw.Float64)
+			mc.RotateTable = &RotateTable{Angle: home, Target: home, Home: home, Speed: m.params.RotateSpeed}
+		}
+		infoMap[r.Locationid] = mc
+	}
+
+	m.Mu.Lock()
+	m.infoMap = infoMap
+	m.Mu.Unlock()
+	return nil
+}
+
+// Snapshot 回傳所有機台的複本
+func (m *MachineManager) Snapshot() map[string]Machine {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make(map[string]Machine, len(m.infoMap))
+	for locID, mc := range m.infoMap {
+		out[locID] = mc.clone()
+	}
+	return out
+}
+
+// holds 回傳板上放著 cargoID 的疊棧機，沒有時回傳空字串。手臂拿著的貨物正在搬運，不算
+func (m *MachineManager) holds(cargoID string) string {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	for locID, mc := range m.infoMap {
+		if mc.Palletizer == nil {
+			continue
+		}
+		for _, c := range mc.Palletizer.Cargo {
+			if c.ID == cargoID {
+				return locID
+			}
+		}
+	}
+	return ""
+}
+
+// Machine 回傳單一機台的複本
+func (m *MachineManager) Machine(locID string) (Machine, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	mc, err := m.get(locID, "")
+	if err != nil {
+		return Machine{}, err
+	}
+	return mc.clone(), nil
+}
+
+// get 找出 locID 的機台並檢查種類，typ 為空字串時不檢查，呼叫前必須持有 Mu
+func (m *MachineManager) get(locID string, typ MachineType) (*Machine, error) {
+	mc, ok := m.infoMap[locID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrMachineNotFound, locID)
+	}
+	if typ != "" && mc.Type != typ {
+		return nil, fmt.Errorf("%w: %s is %s", ErrWrongMachineType, locID, mc.Type)
+	}
+	return mc, nil
+}
+
+// current 表示 mc 還是 Load 之後的同一台，呼叫前必須持有 Mu
+func (m *MachineManager) current(mc *Machine) bool {
+	return m.infoMap[mc.LocationID] == mc
+}
+
+// changed 更新時間並通知訂閱者，呼叫前必須持有 Mu
+func (m *MachineManager) changed(mc *Machine) {
+	mc.UpdatedAt = m.clock.Now()
+	if m.bus != nil {
+		m.bus.Publish(EventMachineChanged, mc.clone())
+	}
+}
+
+// after 在 d 之後執行 fn，呼叫前必須持有 Mu
+func (m *MachineManager) after(d time.Duration, fn func()) {
+	t := m.clock.NewTimer(d)
+	go func() {
+		<-t.C()
+		fn()
+	}()
+}
+
+// Pick 讓手臂從 from 取最上層的貨物放到 to。from 是堆疊，to 是堆疊或疊棧機。
+// 取不到貨時回到 idle 並記錄錯誤，放不下時進入 fault，手上的貨等 Reset 放回 from
+func (m *MachineManager) Pick(ctx context.Context, armLoc, from, to string) (Machine, error) {
+	if from == to {
+		return Machine{}, ErrSameStack
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	mc, err := m.get(armLoc, MachineRoboticArm)
+	if err != nil {
+		return Machine{}, err
+	}
+	switch mc.State {
+	case MachineBusy:
+		return Machine{}, fmt.Errorf("%w: %s", ErrMachineBusy, armLoc)
+	case MachineFault:
+		return Machine{}, fmt.Errorf("%w: %s: %s", ErrMachineFault, armLoc, mc.Error)
+	}
+
+	mc.State = MachineBusy
+	mc.Error = ""
+	mc.Arm.From, mc.Arm.To = from, to
+	m.changed(mc)
+
+	// 背景的取放不跟著 request 取消，但保留操作者給 journal
+	ctx = context.WithoutCancel(ctx)
+	half := mc.Arm.CycleTime / 2
+	m.after(half, func() { m.pickStep(ctx, mc, half) })
+	return mc.clone(), nil
+}
+
+func (m *MachineManager) pickStep(ctx context.Context, mc *Machine, half time.Duration) {
+	c, err := m.take(ctx, mc.LocationID, mc.Arm.From)
+
+	m.Mu.Lock()
+	if !m.current(mc) {
+		arm := *mc.Arm
+		m.Mu.Unlock()
+		if err == nil {
+			m.putBack(ctx, mc.LocationID, arm, c)
+		}
+		return
+	}
+	defer m.Mu.Unlock()
+
+	if err != nil {
+		mc.State = MachineIdle
+		mc.Error = err.Error()
+		m.changed(mc)
+		return
+	}
+
+	mc.Arm.Holding = &c
+	m.changed(mc)
+	m.after(half, func() { m.placeStep(ctx, mc, c) })
+}
+
+func (m *MachineManager) placeStep(ctx context.Context, mc *Machine, c CargoData) {
+	err := m.put(ctx, mc.Arm.To, c)
+
+	m.Mu.Lock()
+	if !m.current(mc) {
+		arm := *mc.Arm
+		m.Mu.Unlock()
+		if err != nil {
+			m.putBack(ctx, mc.LocationID, arm, c)
+		}
+		return
+	}
+	defer m.Mu.Unlock()
+
+	if err != nil {
+		mc.State = MachineFault
+		mc.Error = err.Error()
+		m.changed(mc)
+		return
+	}
+
+	mc.Arm.Holding = nil
+	mc.Arm.Cycles++
+	mc.State = MachineIdle
+	m.changed(mc)
+}
+
+// putBack 在手臂拿著貨時機台被重新載入，沒有 Reset 可以處理，直接把貨放回 from，
+// 放不回去再放到 to，都不行只能記錄下來。呼叫時不可持有 Mu
+func (m *MachineManager) putBack(ctx context.Context, armLoc string, arm Arm, c CargoData) {
+	logger := m.logger.With(infra.FieldLocationID, armLoc)
+
+	var errs []error
+	for _, locID := range []string{arm.From, arm.To} {
+		err := m.put(ctx, locID, c)
+		if err == nil {
+			logger.Warn("machines reloaded while arm held cargo %s, put back to %s", c.ID, locID)
+			return
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", locID, err))
+	}
+	logger.Error("machines reloaded while arm held cargo %s, cannot put it back: %v", c.ID, errors.Join(errs...))
+}
+
+// take 讓 armLoc 的機械手臂從堆疊取貨，疊棧機只能放不能取
+func (m *MachineManager) take(ctx context.Context, armLoc, locID string) (CargoData, error) {
+	m.Mu.Lock()
+	mc, ok := m.infoMap[locID]
+	m.Mu.Unlock()
+	if ok {
+		return CargoData{}, fmt.Errorf("%w: cannot take from %s %s", ErrWrongMachineType, mc.Type, locID)
+	}
+	// 搬運中的貨物由機械手臂拿著
+	return m.stacks.OffloadCargo(ctx, locID, armLoc)
+}
+
+// put 把貨放到疊棧機或堆疊，放置時間重新計算
+func (m *MachineManager) put(ctx context.Context, locID string, c CargoData) error {
+	c.PlacedAt = time.Time{}
+	m.Mu.Lock()
+	_, ok := m.infoMap[locID]
+	m.Mu.Unlock()
+	if ok {
+		_, err := m.Place(ctx, locID, c)
+		return err
+	}
+	return m.stacks.PushCargo(ctx, locID, c)
+}
+
+// Place 把貨物放上疊棧機，疊滿 PatternCount 層後進入 full。
+// 狀態規則與 YFYStackManager.PushCargo 相同
+func (m *MachineManager) Place(ctx context.Context, locID string, c CargoData) (Machine, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	mc, err := m.get(locID, MachinePalletizer)
+	if err != nil {
+		return Machine{}, err
+	}
+	p := mc.Palletizer
+	if mc.State == MachineFull {
+		return Machine{}, fmt.Errorf("%w: %s has %d layers", ErrPalletFull, locID, p.Layers())
+	}
+	c, mv, err := arrive(c, cargo.Arrival(cargo.Status(c.Status)), cargo.Holder{Kind: cargo.HolderMachine, ID: locID})
+	if err != nil {
+		return Machine{}, err
+	}
+	if err := recordMoves(ctx, m.lifecycle, mv); err != nil {
+		return Machine{}, err
+	}
+	c.PlacedAt = m.clock.Now()
+
+	p.Cargo = append(p.Cargo, c)
+	if len(p.Cargo) >= p.capacity() {
+		mc.State = MachineFull
+	}
+	m.changed(mc)
+	return mc.clone(), nil
+}
+
+// Release 放行疊棧機上的整板貨物並回傳，整板貨物移出場域，疊棧機回到 idle
+func (m *MachineManager) Release(ctx context.Context, locID string) ([]CargoData, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	mc, err := m.get(locID, MachinePalletizer)
+	if err != nil {
+		return nil, err
+	}
+	p := mc.Palletizer
+	if len(p.Cargo) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrPalletEmpty, locID)
+	}
+
+	pallet, moves, err := shiftAll(p.Cargo)
+	if err != nil {
+		return nil, err
+	}
+	if err := recordMoves(ctx, m.lifecycle, moves...); err != nil {
+		return nil, err
+	}
+
+	p.Cargo = nil
+	p.Pallets++
+	mc.State = MachineIdle
+	m.changed(mc)
+	return pallet, nil
+}
+
+// Rotate 把旋轉台轉到 angle (度)，nil 表示轉回 Direction.yaw。往較近的方向轉
+func (m *MachineManager) Rotate(ctx context.Context, locID string, angle *float64) (Machine, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	mc, err := m.get(locID, MachineRotateTable)
+	if err != nil {
+		return Machine{}, err
+	}
+	if mc.State == MachineBusy {
+		return Machine{}, fmt.Errorf("%w: %s", ErrMachineBusy, locID)
+	}
+
+	t := mc.RotateTable
+	t.Target = t.Home
+	if angle != nil {
+		t.Target = normalizeAngle(*angle)
+	}
+
+	d := t.rotateDuration(t.Angle, t.Target)
+	if d <= 0 {
+		t.Angle = t.Target
+		m.changed(mc)
+		return mc.clone(), nil
+	}
+
+	mc.State = MachineBusy
+	m.changed(mc)
+	m.after(d, func() {
+		m.Mu.Lock()
+		defer m.Mu.Unlock()
+
+		if !m.current(mc) {
+			return
+		}
+		t.Angle = t.Target
+		mc.State = MachineIdle
+		m.changed(mc)
+	})
+	return mc.clone(), nil
+}
+
+// Reset 清除機台的錯誤。fault 的手臂把手上的貨放回 from，放不回去時維持 fault
+func (m *MachineManager) Reset(ctx context.Context, locID string) (Machine, error) {
+	m.Mu.Lock()
+	mc, err := m.get(locID, "")
+	if err != nil {
+		m.Mu.Unlock()
+		return Machine{}, err
+	}
+	if mc.State == MachineBusy {
+		m.Mu.Unlock()
+		return Machine{}, fmt.Errorf("%w: %s", ErrMachineBusy, locID)
+	}
+
+	var holding *CargoData
+	if mc.State == MachineFault && mc.Arm.Holding != nil {
+		holding = mc.Arm.Holding
+	}
+	from := ""
+	if mc.Arm != nil {
+		from = mc.Arm.From
+	}
+	m.Mu.Unlock()
+
+	var putErr error
+	if holding != nil {
+		putErr = m.put(ctx, from, *holding)
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if !m.current(mc) {
+		return Machine{}, fmt.Errorf("%w: %s", ErrMachineNotFound, locID)
+	}
+	if putErr != nil {
+		mc.Error = putErr.Error()
+		m.changed(mc)
+		return Machine{}, fmt.Errorf("%w: put back to %s: %w", ErrMachineFault, from, putErr)
+	}
+
+	if mc.Arm != nil {
+		mc.Arm.Holding = nil
+	}
+	if mc.State == MachineFault {
+		mc.State = MachineIdle
+	}
+	mc.Error = ""
+	m.changed(mc)
+	return mc.clone(), nil
+}
+
+// ToProto 轉成推送給上游的格式，依 locationId 排序
+func (m *MachineManager) ToProto() []*stackpb.Machine {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make([]*stackpb.Machine, 0, len(m.infoMap))
+	for locID, mc := range m.infoMap {
+		pb := &stackpb.Machine{
+			LocationId: locID,
+			Name:       mc.Name,
+			Type:       string(mc.Type),
+			State:      string(mc.State),
+			Error:      mc.Error,
+		}
+		switch {
+		case mc.Arm != nil:
+			pb.From = mc.Arm.From
+			pb.To = mc.Arm.To
+			pb.Cycles = int32(mc.Arm.Cycles)
+			if mc.Arm.Holding != nil {
+				pb.Holding = cargoToProto(*mc.Arm.Holding)
+			}
+		case mc.Palletizer != nil:
+			p := mc.Palletizer
+			pb.PatternCount = int32(p.PatternCount)
+			pb.LayerSize = int32(p.LayerSize)
+			pb.Layers = int32(p.Layers())
+			pb.Pallets = int32(p.Pallets)
+			for _, c := range p.Cargo {
+				pb.Cargo = append(pb.Cargo, cargoToProto(c))
+			}
+		case mc.RotateTable != nil:
+			pb.Angle = mc.RotateTable.Angle
+			pb.TargetAngle = mc.RotateTable.Target
+		}
+		out = append(out, pb)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationId < out[j].LocationId })
+	return out
+}
+
+func cargoToProto(c CargoData) *stackpb.Cargo {
+	return &stackpb.Cargo{
+		Id:         c.ID,
+		Metadata:   c.Metadata,
+		CustomId:   c.CustomID,
+		Status:     c.Status,
+		MetadataId: c.MetadataID,
+	}
+}
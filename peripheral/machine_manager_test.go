@@ -0,0 +1,103 @@
+package peripheral
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// testArm 建立一台從 L1 搬到 L2 的機械手臂，時間由 clock 控制
+func testArm(t *testing.T, stacks *YFYStackManager, clock *infra.ManualClock) *MachineManager {
+	t.Helper()
+	m := NewMachineManager(nil, stacks, nil, testLogger(t), MachineParams{})
+	m.SetClock(clock)
+	m.infoMap["A1"] = &Machine{LocationID: "A1", Type: MachineRoboticArm, State: MachineIdle, Arm: &Arm{CycleTime: 2 * time.Second}}
+	return m
+}
+
+// reloadArm 模擬 Load 換掉整台機台
+func reloadArm(m *MachineManager) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	m.infoMap["A1"] = &Machine{LocationID: "A1", Type: MachineRoboticArm, State: MachineIdle, Arm: &Arm{CycleTime: 2 * time.Second}}
+}
+
+// waitStack 等背景的取放把 locID 的貨物變成 ids。
+// 測試開始時貨物沒有放置時間，重新放上去的才有，用來分辨貨物是否動過
+func waitStack(t *testing.T, stacks *YFYStackManager, locID string, moved bool, ids ...string) []CargoData {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for {
+		stacks.Mu.Lock()
+		cs := append([]CargoData{}, stacks.infoMap[locID].Cargo...)
+		stacks.Mu.Unlock()
+
+		if len(cs) == len(ids) {
+			same := true
+			for i := range cs {
+				same = same && cs[i].ID == ids[i] && cs[i].PlacedAt.IsZero() != moved
+			}
+			if same {
+				return cs
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s has %v, want %v (moved %v)", locID, cs, ids, moved)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestMachinePickStepPutsBackAfterReload(t *testing.T) {
+	stacks := testStacks(t, map[string]*YFYStack{
+		"L1": stack(2, CargoData{ID: "c1", Status: string(cargo.StatusAtLocation)}),
+		"L2": stack(2),
+	})
+	clock := infra.NewManualClock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	m := testArm(t, stacks, clock)
+
+	if _, err := m.Pick(context.Background(), "A1", "L1", "L2"); err != nil {
+		t.Fatal(err)
+	}
+	// 手臂取貨前機台被重新載入，取到的貨要放回 L1
+	reloadArm(m)
+	clock.Advance(time.Second)
+
+	cs := waitStack(t, stacks, "L1", true, "c1")
+	if cs[0].Status != string(cargo.StatusAtLocation) {
+		t.Fatalf("put back cargo is %s, want AT_LOCATION", cs[0].Status)
+	}
+	waitStack(t, stacks, "L2", false)
+}
+
+func TestMachinePlaceStepPutsBackAfterReload(t *testing.T) {
+	stacks := testStacks(t, map[string]*YFYStack{
+		"L1": stack(2, CargoData{ID: "c1", Status: string(cargo.StatusAtLocation)}),
+		"L2": stack(1, CargoData{ID: "c2", Status: string(cargo.StatusAtLocation)}),
+	})
+	clock := infra.NewManualClock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	m := testArm(t, stacks, clock)
+
+	if _, err := m.Pick(context.Background(), "A1", "L1", "L2"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Second)
+	waitStack(t, stacks, "L1", false)
+
+	// 手臂拿著貨時機台被重新載入，L2 已滿放不下，貨要回到 L1 而不是不見
+	deadline := time.Now().Add(time.Second)
+	for clock.Waiters() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("place step was never scheduled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	reloadArm(m)
+	clock.Advance(time.Second)
+
+	waitStack(t, stacks, "L1", true, "c1")
+	waitStack(t, stacks, "L2", false, "c2")
+}
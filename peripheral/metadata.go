@@ -0,0 +1,357 @@
+package peripheral
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrMetadataInvalid        = errors.New("invalid cargo metadata")
+	ErrMetadataFormatNotFound = errors.New("cargo metadata format not found")
+	ErrMetadataDuplicate      = errors.New("duplicate cargo metadata unique key")
+)
+
+// 欄位型別，對應 custom_cargo_metadata.format 裡的 type
+const (
+	FieldString  = "string"
+	FieldNumber  = "number"
+	FieldInteger = "integer"
+	FieldBoolean = "boolean"
+	FieldObject  = "object"
+	FieldArray   = "array"
+	FieldDate    = "date" // RFC3339 字串
+)
+
+type MetadataField struct {
+	Key      string
+	Type     string // 空字串或不認得的型別不檢查
+	Required bool
+}
+
+// MetadataFormat 是一筆 custom_cargo_metadata
+type MetadataFormat struct {
+	ID        string
+	Name      string
+	IsDefault bool
+	Fields    []MetadataField
+	UniqueKey string // metadata 裡這個欄位的值在所有堆疊上不可重複
+}
+
+// metadataFieldDef 是 format 裡單一欄位的寫法
+type metadataFieldDef struct {
+	Key      string `json:"key"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Required *bool  `json:"required"`
+	Optional bool   `json:"optional"`
+}
+
+func (d metadataFieldDef) field(key string) MetadataField {
+	required := !d.Optional
+	if d.Required != nil {
+		required = *d.Required
+	}
+	return MetadataField{Key: key, Type: strings.ToLower(d.Type), Required: required}
+}
+
+// parseMetadataFormat 解析 format 欄位，支援以下寫法，欄位預設都是必填：
+//
+//	{"sku": "string", "weight": "number"}
+//	{"sku": {"type": "string"}, "note": {"type": "string", "required": false}}
+//	[{"key": "sku", "type": "string"}, {"name": "weight", "type": "number", "optional": true}]
+func parseMetadataFormat(raw json.RawMessage) ([]MetadataField, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var fields []MetadataField
+
+	if raw[0] == '[' {
+		var defs []metadataFieldDef
+		if err := json.Unmarshal(raw, &defs); err != nil {
+			return nil, err
+		}
+		for _, d := range defs {
+			key := d.Key
+			if key == "" {
+				key = d.Name
+			}
+			if key == "" {
+				return nil, errors.New("field without key")
+			}
+			fields = append(fields, d.field(key))
+		}
+		return fields, nil
+	}
+
+	var defs map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &defs); err != nil {
+		return nil, err
+	}
+	for key, v := range defs {
+		var typ string
+		if err := json.Unmarshal(v, &typ); err == nil {
+			fields = append(fields, MetadataField{Key: key, Type: strings.ToLower(typ), Required: true})
+			continue
+		}
+
+		var d metadataFieldDef
+		if err := json.Unmarshal(v, &d); err != nil {
+			return nil, fmt.Errorf("field %q: %w", key, err)
+		}
+		fields = append(fields, d.field(key))
+	}
+	// map 沒有順序，排序後錯誤訊息才會固定
+	sort.Slice(fields, func(i, j int) bool { return fields[i].Key < fields[j].Key })
+
+	return fields, nil
+}
+
+// loadMetadataFormats 讀出所有 custom_cargo_metadata，format 解析失敗的跳過並回傳錯誤清單
+func loadMetadataFormats(ctx context.Context, q *db.Queries) (map[string]*MetadataFormat, error) {
+	rows, err := q.ListCustomCargoMetadata(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load cargo metadata formats: %w", err)
+	}
+
+	formats := make(map[string]*MetadataFormat, len(rows))
+	var errs []error
+
+	for _, r := range rows {
+		fields, err := parseMetadataFormat(r.Format)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("cargo metadata format %s (%s): %w", r.ID, r.CustomName, err))
+			continue
+		}
+
+		formats[r.ID] = &MetadataFormat{
+			ID:        r.ID,
+			Name:      r.CustomName,
+			IsDefault: r.IsDefault,
+			Fields:    fields,
+			UniqueKey: r.UniqueKey.String,
+		}
+	}
+
+	return formats, errors.Join(errs...)
+}
+
+// Validate 檢查 metadata 是否符合格式
+func (f *MetadataFormat) Validate(metadata json.RawMessage) error {
+	values := map[string]json.RawMessage{}
+	if trimmed := bytes.TrimSpace(metadata); len(trimmed) > 0 && !bytes.Equal(trimmed, []byte("null")) {
+		if err := json.Unmarshal(trimmed, &values); err != nil {
+			return fmt.Errorf("%w: format %s expects a JSON object: %v", ErrMetadataInvalid, f.Name, err)
+		}
+	}
+
+	var problems []string
+	for _, field := range f.Fields {
+		v, ok := values[field.Key]
+		if !ok || bytes.Equal(bytes.TrimSpace(v), []byte("null")) {
+			if field.Required {
+				problems = append(problems, fmt.Sprintf("%q is required", field.Key))
+			}
+			continue
+		}
+		if err := checkFieldType(field.Type, v); err != nil {
+			problems = append(problems, fmt.Sprintf("%q %v", field.Key, err))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: format %s: %s", ErrMetadataInvalid, f.Name, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+func checkFieldType(typ string, v json.RawMessage) error {
+	var x interface{}
+	if err := json.Unmarshal(v, &x); err != nil {
+		return err
+	}
+
+	ok := true
+	switch typ {
+	case FieldString:
+		_, ok = x.(string)
+	case FieldNumber:
+		_, ok = x.(float64)
+	case FieldInteger:
+		n, isNum := x.(float64)
+		ok = isNum && n == float64(int64(n))
+	case FieldBoolean:
+		_, ok = x.(bool)
+	case FieldObject:
+		_, ok = x.(map[string]interface{})
+	case FieldArray:
+		_, ok = x.([]interface{})
+	case FieldDate:
+		s, isStr := x.(string)
+		if ok = isStr; ok {
+			if _, err := time.Parse(time.RFC3339, s); err != nil {
+				return fmt.Errorf("must be an RFC3339 date, got %q", s)
+			}
+		}
+	}
+
+	if !ok {
+		return fmt.Errorf("must be %s, got %s", typ, jsonKind(x))
+	}
+	return nil
+}
+
+func jsonKind(x interface{}) string {
+	switch x.(type) {
+	case string:
+		return FieldString
+	case float64:
+		return FieldNumber
+	case bool:
+		return FieldBoolean
+	case map[string]interface{}:
+		return FieldObject
+	case []interface{}:
+		return FieldArray
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", x)
+}
+
+// uniqueValue 取出 metadata 裡 UniqueKey 的值 (原始 JSON)，沒有設定或沒帶就回傳空字串
+func (f *MetadataFormat) uniqueValue(metadata json.RawMessage) string {
+	if f.UniqueKey == "" || len(metadata) == 0 {
+		return ""
+	}
+
+	var values map[string]json.RawMessage
+	if err := json.Unmarshal(metadata, &values); err != nil {
+		return ""
+	}
+
+	v := bytes.TrimSpace(values[f.UniqueKey])
+	if len(v) == 0 || bytes.Equal(v, []byte("null")) {
+		return ""
+	}
+	return string(v)
+}
+
+// ReloadMetadataFormats 重新讀取 custom_cargo_metadata。
+// 解析失敗的格式會被略過並記 log，其他格式照常使用。
+func (m *YFYStackManager) ReloadMetadataFormats(ctx context.Context) error {
+	formats, err := loadMetadataFormats(ctx, m.db)
+	if formats == nil {
+		return err
+	}
+	if err != nil {
+		m.logger.Warn("%v", err)
+	}
+
+	m.Mu.Lock()
+	m.formats = formats
+	m.Mu.Unlock()
+	return nil
+}
+
+// MetadataFormats 回傳目前載入的格式
+func (m *YFYStackManager) MetadataFormats() []MetadataFormat {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make([]MetadataFormat, 0, len(m.formats))
+	for _, f := range m.formats {
+		out = append(out, *f)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// formatOf 找出貨物要用的格式，沒指定時用預設格式並回填 MetadataID。
+// 還沒載入任何格式 (例如用快照啟動) 時回傳 nil 表示不檢查。呼叫前必須持有 Mu
+func (m *YFYStackManager) formatOf(c *CargoData) (*MetadataFormat, error) {
+	if m.formats == nil {
+		return nil, nil
+	}
+
+	if c.MetadataID != "" {
+		f, ok := m.formats[c.MetadataID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrMetadataFormatNotFound, c.MetadataID)
+		}
+		return f, nil
+	}
+
+	for _, f := range m.formats {
+		if f.IsDefault {
+			c.MetadataID = f.ID
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+// validateCargo 檢查新放上 (或替換) 的貨物 metadata 格式與 unique key。
+// replacing 是這次操作會移走的位置，它上面的貨物不算重複。呼叫前必須持有 Mu
+func (m *YFYStackManager) validateCargo(cargo []CargoData, replacing string) error {
+	// 同一批裡的 unique key 也不可重複
+	seen := make(map[string]string)
+
+	for i := range cargo {
+		c := &cargo[i]
+
+		f, err := m.formatOf(c)
+		if err != nil {
+			return fmt.Errorf("cargo %s: %w", c.ID, err)
+		}
+		if f == nil {
+			continue
+		}
+
+		if err := f.Validate(c.Metadata); err != nil {
+			return fmt.Errorf("cargo %s: %w", c.ID, err)
+		}
+
+		v := f.uniqueValue(c.Metadata)
+		if v == "" {
+			continue
+		}
+
+		key := f.ID + "\x00" + v
+		if other, ok := seen[key]; ok {
+			return fmt.Errorf("cargo %s: %w: %s=%s also used by %s", c.ID, ErrMetadataDuplicate, f.UniqueKey, v, other)
+		}
+		seen[key] = c.ID
+
+		if locID, other, ok := m.findUnique(f, v, c.ID, replacing); ok {
+			return fmt.Errorf("cargo %s: %w: %s=%s already used by cargo %s at %s", c.ID, ErrMetadataDuplicate, f.UniqueKey, v, other, locID)
+		}
+	}
+	return nil
+}
+
+// findUnique 在所有堆疊上找相同格式、相同 unique 值的其他貨物，呼叫前必須持有 Mu
+func (m *YFYStackManager) findUnique(f *MetadataFormat, value, cargoID, skipLoc string) (string, string, bool) {
+	for locID, s := range m.infoMap {
+		if locID == skipLoc {
+			continue
+		}
+		for _, c := range s.Cargo {
+			if c.ID == cargoID || c.MetadataID != f.ID {
+				continue
+			}
+			if f.uniqueValue(c.Metadata) == value {
+				return locID, c.ID, true
+			}
+		}
+	}
+	return "", "", false
+}
@@ -0,0 +1,152 @@
+package peripheral
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseMetadataFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    []MetadataField
+		wantErr bool
+	}{
+		{name: "空的", raw: "null"},
+		{
+			name: "型別字串",
+			raw:  `{"weight": "Number", "sku": "string"}`,
+			want: []MetadataField{{Key: "sku", Type: FieldString, Required: true}, {Key: "weight", Type: FieldNumber, Required: true}},
+		},
+		{
+			name: "物件寫法",
+			raw:  `{"sku": {"type": "string"}, "note": {"type": "string", "required": false}}`,
+			want: []MetadataField{{Key: "note", Type: FieldString}, {Key: "sku", Type: FieldString, Required: true}},
+		},
+		{
+			name: "陣列寫法",
+			raw:  `[{"key": "sku", "type": "string"}, {"name": "weight", "type": "number", "optional": true}]`,
+			want: []MetadataField{{Key: "sku", Type: FieldString, Required: true}, {Key: "weight", Type: FieldNumber}},
+		},
+		{name: "陣列欄位沒有 key", raw: `[{"type": "string"}]`, wantErr: true},
+		{name: "欄位定義不是物件", raw: `{"sku": 1}`, wantErr: true},
+		{name: "不是 JSON", raw: `{sku`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMetadataFormat(json.RawMessage(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMetadataFormat = %v, want error %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("fields = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("field %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestMetadataValidate 每一種檢查失敗都回傳 ErrMetadataInvalid
+func TestMetadataValidate(t *testing.T) {
+	f := &MetadataFormat{Name: "pallet", Fields: []MetadataField{
+		{Key: "sku", Type: FieldString, Required: true},
+		{Key: "weight", Type: FieldNumber},
+		{Key: "layers", Type: FieldInteger},
+		{Key: "fragile", Type: FieldBoolean},
+		{Key: "size", Type: FieldObject},
+		{Key: "tags", Type: FieldArray},
+		{Key: "madeAt", Type: FieldDate},
+		{Key: "note", Type: "unknown"},
+	}}
+
+	tests := []struct {
+		name     string
+		metadata string
+		wantErr  bool
+	}{
+		{name: "只有必填欄位", metadata: `{"sku": "A1"}`},
+		{name: "所有欄位", metadata: `{"sku": "A1", "weight": 1.5, "layers": 3, "fragile": true, "size": {}, "tags": [], "madeAt": "2026-10-19T08:30:00+08:00", "note": 1}`},
+		{name: "選填欄位是 null", metadata: `{"sku": "A1", "weight": null}`},
+		{name: "不是物件", metadata: `["A1"]`, wantErr: true},
+		{name: "沒有 metadata", metadata: ``, wantErr: true},
+		{name: "必填欄位缺少", metadata: `{"weight": 1}`, wantErr: true},
+		{name: "必填欄位是 null", metadata: `{"sku": null}`, wantErr: true},
+		{name: "string 型別不符", metadata: `{"sku": 1}`, wantErr: true},
+		{name: "number 型別不符", metadata: `{"sku": "A1", "weight": "1"}`, wantErr: true},
+		{name: "integer 有小數", metadata: `{"sku": "A1", "layers": 1.5}`, wantErr: true},
+		{name: "boolean 型別不符", metadata: `{"sku": "A1", "fragile": "yes"}`, wantErr: true},
+		{name: "object 型別不符", metadata: `{"sku": "A1", "size": []}`, wantErr: true},
+		{name: "array 型別不符", metadata: `{"sku": "A1", "tags": {}}`, wantErr: true},
+		{name: "date 不是字串", metadata: `{"sku": "A1", "madeAt": 1}`, wantErr: true},
+		{name: "date 不是 RFC3339", metadata: `{"sku": "A1", "madeAt": "2026/10/19"}`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := f.Validate(json.RawMessage(tt.metadata))
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("Validate = %v, want nil", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrMetadataInvalid) {
+				t.Fatalf("Validate = %v, want ErrMetadataInvalid", err)
+			}
+		})
+	}
+}
+
+// TestValidateCargo 格式不存在與 unique key 重複的檢查
+func TestValidateCargo(t *testing.T) {
+	pallet := &MetadataFormat{ID: "F1", Name: "pallet", IsDefault: true, UniqueKey: "sku",
+		Fields: []MetadataField{{Key: "sku", Type: FieldString, Required: true}}}
+
+	tests := []struct {
+		name      string
+		cargo     []CargoData
+		replacing string
+		want      error
+	}{
+		{name: "預設格式", cargo: []CargoData{{ID: "c2", Metadata: json.RawMessage(`{"sku": "B"}`)}}},
+		{name: "格式不存在", cargo: []CargoData{{ID: "c2", MetadataID: "F9"}}, want: ErrMetadataFormatNotFound},
+		{name: "格式不符", cargo: []CargoData{{ID: "c2", Metadata: json.RawMessage(`{}`)}}, want: ErrMetadataInvalid},
+		{
+			name: "同一批重複",
+			cargo: []CargoData{
+				{ID: "c2", Metadata: json.RawMessage(`{"sku": "B"}`)},
+				{ID: "c3", Metadata: json.RawMessage(`{"sku": "B"}`)},
+			},
+			want: ErrMetadataDuplicate,
+		},
+		{name: "其他堆疊上重複", cargo: []CargoData{{ID: "c2", Metadata: json.RawMessage(`{"sku": "A"}`)}}, want: ErrMetadataDuplicate},
+		{name: "被換掉的位置不算重複", cargo: []CargoData{{ID: "c2", Metadata: json.RawMessage(`{"sku": "A"}`)}}, replacing: "L1"},
+		{name: "同一個貨物不算重複", cargo: []CargoData{{ID: "c1", Metadata: json.RawMessage(`{"sku": "A"}`)}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testStacks(t, map[string]*YFYStack{
+				"L1": stack(2, CargoData{ID: "c1", MetadataID: "F1", Metadata: json.RawMessage(`{"sku": "A"}`)}),
+			})
+			m.formats = map[string]*MetadataFormat{"F1": pallet}
+
+			err := m.validateCargo(tt.cargo, tt.replacing)
+			if tt.want == nil {
+				if err != nil {
+					t.Fatalf("validateCargo = %v, want nil", err)
+				}
+				if tt.cargo[0].MetadataID != "F1" {
+					t.Errorf("MetadataID = %q, want the default format F1", tt.cargo[0].MetadataID)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("validateCargo = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
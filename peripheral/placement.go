@@ -0,0 +1,344 @@
+package peripheral
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/infra"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrNoStackAvailable = errors.New("no stack available")
+	ErrUnknownStrategy  = errors.New("unknown placement strategy")
+	ErrStackReserved    = errors.New("stack is reserved")
+)
+
+// noBooker 是沒有被預約時 Booker 的值
+const noBooker = "none"
+
+// PlacementOp 是要放貨還是取貨
+type PlacementOp string
+
+const (
+	OpLoad    PlacementOp = "load"    // 放貨，挑還有空位的堆疊
+	OpOffload PlacementOp = "offload" // 取貨，挑有貨的堆疊
+)
+
+// 內建策略的名稱
+const (
+	StrategyPriority    = "priority"
+	StrategyLeastFilled = "least-filled"
+	StrategyFIFO        = "fifo"
+	StrategyNearest     = "nearest"
+	StrategyRoundRobin  = "round-robin"
+)
+
+// PlacementRequest 是 SelectStack 的條件
+type PlacementRequest struct {
+	GroupID  string
+	Op       PlacementOp
+	Strategy string // 空字串用 StrategyPriority
+
+	// Cargo 放貨時不可已經在候選堆疊上；取貨時有 ID 就只挑這個貨物在最上層的堆疊
+	Cargo CargoData
+
+	X, Y float64 // StrategyNearest 的參考點，例如車子目前的位置
+
+	Reserve bool   // 挑到後直接預約，跟挑選在同一個鎖裡完成
+	Booker  string // 預約者，空字串用 context 裡的 actor
+}
+
+// PlacementCandidate 是符合條件的堆疊 (複本)
+type PlacementCandidate struct {
+	LocationID string
+	Stack      YFYStack
+}
+
+// PlacementStrategy 從候選堆疊中挑一個。
+// Choose 回傳 candidates 的索引，candidates 已依 LocationID 排序且至少有一個
+type PlacementStrategy interface {
+	Name() string
+	Choose(req PlacementRequest, candidates []PlacementCandidate) int
+}
+
+func (ns *YFYStack) reserved() bool {
+	return ns.Booker != "" && ns.Booker != noBooker
+}
+
+// accepts 判斷堆疊是否能做這次操作，停用或已預約的都不算
+func (ns *YFYStack) accepts(req PlacementRequest) bool {
+	if ns.Disable || ns.reserved() || ns.Placement.GroupID != req.GroupID {
+		return false
+	}
+
+	switch req.Op {
+	case OpLoad:
+		if len(ns.Cargo) >= ns.StackCount {
+			return false
+		}
+		for _, c := range ns.Cargo {
+			if req.Cargo.ID != "" && c.ID == req.Cargo.ID {
+				return false
+			}
+		}
+		return true
+	case OpOffload:
+		n := len(ns.Cargo)
+		return n > 0 && (req.Cargo.ID == "" || ns.Cargo[n-1].ID == req.Cargo.ID)
+	}
+	return false
+}
+
+// RegisterStrategy 加入 (或取代同名的) 挑選策略
+func (m *YFYStackManager) RegisterStrategy(s PlacementStrategy) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.strategy("")
+	m.strategies[s.Name()] = s
+}
+
+// Strategies 回傳可用的策略名稱
+func (m *YFYStackManager) Strategies() []string {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.strategy("")
+	names := make([]string, 0, len(m.strategies))
+	for name := range m.strategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// strategy 找出策略，第一次呼叫時放入內建策略。呼叫前必須持有 Mu
+func (m *YFYStackManager) strategy(name string) (PlacementStrategy, bool) {
+	if m.strategies == nil {
+		m.strategies = make(map[string]PlacementStrategy)
+		for _, s := range []PlacementStrategy{
+			priorityStrategy{},
+			leastFilledStrategy{},
+			fifoStrategy{},
+			nearestStrategy{},
+			&roundRobinStrategy{},
+		} {
+			m.strategies[s.Name()] = s
+		}
+	}
+
+	if name == "" {
+		name = StrategyPriority
+	}
+	s, ok := m.strategies[name]
+	return s, ok
+}
+
+// SelectStack 依策略從群組中挑出要放貨或取貨的堆疊，沒有符合的回傳 ErrNoStackAvailable。
+// req.Reserve 時挑到的堆疊會直接預約，其他人在釋放前不會再挑到它
+func (m *YFYStackManager) SelectStack(ctx context.Context, req PlacementRequest) (PlacementCandidate, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if req.Op != OpLoad && req.Op != OpOffload {
+		return PlacementCandidate{}, fmt.Errorf("unknown placement op %q", req.Op)
+	}
+	strategy, ok := m.strategy(req.Strategy)
+	if !ok {
+		return PlacementCandidate{}, fmt.Errorf("%w: %s", ErrUnknownStrategy, req.Strategy)
+	}
+
+	var candidates []PlacementCandidate
+	for locID, s := range m.infoMap {
+		if s.accepts(req) {
+			candidates = append(candidates, PlacementCandidate{LocationID: locID, Stack: s.clone()})
+		}
+	}
+	if len(candidates) == 0 {
+		return PlacementCandidate{}, fmt.Errorf("%w: %s in group %s", ErrNoStackAvailable, req.Op, req.GroupID)
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].LocationID < candidates[j].LocationID })
+
+	i := strategy.Choose(req, candidates)
+	if i < 0 || i >= len(candidates) {
+		return PlacementCandidate{}, fmt.Errorf("strategy %s chose %d of %d candidates", strategy.Name(), i, len(candidates))
+	}
+	chosen := candidates[i]
+
+	if req.Reserve {
+		booker := req.Booker
+		if booker == "" {
+			booker = infra.ActorFrom(ctx)
+		}
+		s := m.infoMap[chosen.LocationID]
+		s.Booker = booker
+		chosen.Stack = s.clone()
+
+		m.recordStack(ctx, JournalStackReserved, chosen.LocationID)
+		m.changed(chosen.LocationID)
+	}
+
+	return chosen, nil
+}
+
+// Reserve 預約堆疊，已被別人預約時回傳 ErrStackReserved，同一個預約者重複預約沒有影響
+func (m *YFYStackManager) Reserve(ctx context.Context, locID, booker string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return ErrStackNotFound
+	}
+	if booker == "" {
+		booker = infra.ActorFrom(ctx)
+	}
+	if s.Booker == booker {
+		return nil
+	}
+	if s.reserved() {
+		return fmt.Errorf("%w by %s", ErrStackReserved, s.Booker)
+	}
+
+	s.Booker = booker
+	m.recordStack(ctx, JournalStackReserved, locID)
+	m.changed(locID)
+	return nil
+}
+
+// Release 取消預約。booker 不是空字串時必須是原本的預約者，否則回傳 ErrStackReserved
+func (m *YFYStackManager) Release(ctx context.Context, locID, booker string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return ErrStackNotFound
+	}
+	if !s.reserved() {
+		return nil
+	}
+	if booker != "" && s.Booker != booker {
+		return fmt.Errorf("%w by %s", ErrStackReserved, s.Booker)
+	}
+
+	s.Booker = noBooker
+	m.recordStack(ctx, JournalStackReleased, locID)
+	m.changed(locID)
+	return nil
+}
+
+// best 回傳 less 排序下最前面的索引，相同時保留 LocationID 較小的
+func best(candidates []PlacementCandidate, less func(a, b *YFYStack) bool) int {
+	i := 0
+	for j := 1; j < len(candidates); j++ {
+		if less(&candidates[j].Stack, &candidates[i].Stack) {
+			i = j
+		}
+	}
+	return i
+}
+
+// priorityStrategy 依 load_priority 或 offload_priority，再依 Loc.placement_priority，數字越大越優先
+type priorityStrategy struct{}
+
+func (priorityStrategy) Name() string { return StrategyPriority }
+
+func (priorityStrategy) Choose(req PlacementRequest, candidates []PlacementCandidate) int {
+	opPriority := func(s *YFYStack) int {
+		if req.Op == OpOffload {
+			return s.Placement.OffloadPriority
+		}
+		return s.Placement.LoadPriority
+	}
+
+	return best(candidates, func(a, b *YFYStack) bool {
+		if pa, pb := opPriority(a), opPriority(b); pa != pb {
+			return pa > pb
+		}
+		return a.Placement.Priority > b.Placement.Priority
+	})
+}
+
+// leastFilledStrategy 挑使用率最低的堆疊。
+// 放貨時讓貨物平均分散；取貨時先清空快空的堆疊，讓整個堆疊空出來
+type leastFilledStrategy struct{}
+
+func (leastFilledStrategy) Name() string { return StrategyLeastFilled }
+
+func (leastFilledStrategy) Choose(req PlacementRequest, candidates []PlacementCandidate) int {
+	fill := func(s *YFYStack) float64 {
+		if s.StackCount <= 0 {
+			return 1
+		}
+		return float64(len(s.Cargo)) / float64(s.StackCount)
+	}
+
+	return best(candidates, func(a, b *YFYStack) bool {
+		return fill(a) < fill(b)
+	})
+}
+
+// fifoStrategy 挑最上層貨物最早放上去的堆疊。
+// 取貨時先取最舊的貨；放貨時挑最久沒放貨的堆疊，空堆疊最優先
+type fifoStrategy struct{}
+
+func (fifoStrategy) Name() string { return StrategyFIFO }
+
+func (fifoStrategy) Choose(req PlacementRequest, candidates []PlacementCandidate) int {
+	top := func(s *YFYStack) time.Time {
+		if n := len(s.Cargo); n > 0 {
+			return s.Cargo[n-1].PlacedAt
+		}
+		return time.Time{}
+	}
+
+	return best(candidates, func(a, b *YFYStack) bool {
+		return top(a).Before(top(b))
+	})
+}
+
+// nearestStrategy 挑 Loc 座標離 req.X/req.Y 最近的堆疊
+type nearestStrategy struct{}
+
+func (nearestStrategy) Name() string { return StrategyNearest }
+
+func (nearestStrategy) Choose(req PlacementRequest, candidates []PlacementCandidate) int {
+	dist := func(s *YFYStack) float64 {
+		return math.Hypot(s.Placement.X-req.X, s.Placement.Y-req.Y)
+	}
+
+	return best(candidates, func(a, b *YFYStack) bool {
+		return dist(a) < dist(b)
+	})
+}
+
+// roundRobinStrategy 在同一群組、同一種操作裡依 LocationID 輪流挑
+type roundRobinStrategy struct {
+	mu   sync.Mutex
+	last map[string]string // group + op -> 上次挑的 LocationID
+}
+
+func (*roundRobinStrategy) Name() string { return StrategyRoundRobin }
+
+func (rr *roundRobinStrategy) Choose(req PlacementRequest, candidates []PlacementCandidate) int {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.last == nil {
+		rr.last = make(map[string]string)
+	}
+	key := req.GroupID + "\x00" + string(req.Op)
+
+	// 挑上次之後的第一個，都沒有就從頭開始
+	i := sort.Search(len(candidates), func(i int) bool { return candidates[i].LocationID > rr.last[key] })
+	if i == len(candidates) {
+		i = 0
+	}
+	rr.last[key] = candidates[i].LocationID
+	return i
+}
@@ -0,0 +1,279 @@
+package peripheral
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/infra"
+)
+
+var placedAt = time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+
+// testStacks 建立只有記憶體的堆疊管理器，不連資料庫也不寫 journal
+func testStacks(t *testing.T, stacks map[string]*YFYStack) *YFYStackManager {
+	t.Helper()
+	for _, s := range stacks {
+		if s.Booker == "" {
+			s.Booker = noBooker
+		}
+		if s.Placement.GroupID == "" {
+			s.Placement.GroupID = "G1"
+		}
+	}
+	return &YFYStackManager{infoMap: stacks, logger: testLogger(t)}
+}
+
+// stack 建立有 count 層、目前放著 cargo 的堆疊
+func stack(count int, cargo ...CargoData) *YFYStack {
+	return &YFYStack{StackCount: count, Cargo: cargo}
+}
+
+func TestSelectStackStrategies(t *testing.T) {
+	tests := []struct {
+		name   string
+		stacks map[string]*YFYStack
+		req    PlacementRequest
+		want   string
+	}{
+		{
+			name: "priority uses the op priority first",
+			stacks: map[string]*YFYStack{
+				"A": {StackCount: 2, Placement: Placement{LoadPriority: 1, Priority: 9}},
+				"B": {StackCount: 2, Placement: Placement{LoadPriority: 2}},
+			},
+			req:  PlacementRequest{Op: OpLoad},
+			want: "B",
+		},
+		{
+			name: "priority falls back to the location priority",
+			stacks: map[string]*YFYStack{
+				"A": {StackCount: 2, Placement: Placement{LoadPriority: 1}},
+				"B": {StackCount: 2, Placement: Placement{LoadPriority: 1, Priority: 3}},
+			},
+			req:  PlacementRequest{Op: OpLoad},
+			want: "B",
+		},
+		{
+			name: "priority offload ignores the load priority",
+			stacks: map[string]*YFYStack{
+				"A": {StackCount: 2, Cargo: []CargoData{{ID: "c1"}}, Placement: Placement{LoadPriority: 5}},
+				"B": {StackCount: 2, Cargo: []CargoData{{ID: "c2"}}, Placement: Placement{OffloadPriority: 1}},
+			},
+			req:  PlacementRequest{Op: OpOffload},
+			want: "B",
+		},
+		{
+			name: "priority tie keeps the smallest location",
+			stacks: map[string]*YFYStack{
+				"C": stack(2),
+				"A": stack(2),
+				"B": stack(2),
+			},
+			req:  PlacementRequest{Op: OpLoad},
+			want: "A",
+		},
+		{
+			name: "least-filled compares the fill ratio",
+			stacks: map[string]*YFYStack{
+				"A": stack(2, CargoData{ID: "c1"}),
+				"B": stack(4, CargoData{ID: "c2"}),
+			},
+			req:  PlacementRequest{Op: OpLoad, Strategy: StrategyLeastFilled},
+			want: "B",
+		},
+		{
+			name: "least-filled tie keeps the smallest location",
+			stacks: map[string]*YFYStack{
+				"B": stack(2, CargoData{ID: "c1"}),
+				"A": stack(4, CargoData{ID: "c2"}, CargoData{ID: "c3"}),
+			},
+			req:  PlacementRequest{Op: OpOffload, Strategy: StrategyLeastFilled},
+			want: "A",
+		},
+		{
+			name: "fifo offloads the oldest top cargo",
+			stacks: map[string]*YFYStack{
+				"A": stack(2, CargoData{ID: "c1", PlacedAt: placedAt}, CargoData{ID: "c2", PlacedAt: placedAt.Add(time.Hour)}),
+				"B": stack(2, CargoData{ID: "c3", PlacedAt: placedAt.Add(time.Minute)}),
+			},
+			req:  PlacementRequest{Op: OpOffload, Strategy: StrategyFIFO},
+			want: "B",
+		},
+		{
+			name: "fifo loads an empty stack first",
+			stacks: map[string]*YFYStack{
+				"A": stack(2, CargoData{ID: "c1", PlacedAt: placedAt}),
+				"B": stack(2),
+			},
+			req:  PlacementRequest{Op: OpLoad, Strategy: StrategyFIFO},
+			want: "B",
+		},
+		{
+			name: "nearest measures from the request point",
+			stacks: map[string]*YFYStack{
+				"A": {StackCount: 1, Placement: Placement{X: 0, Y: 0}},
+				"B": {StackCount: 1, Placement: Placement{X: 10, Y: 10}},
+			},
+			req:  PlacementRequest{Op: OpLoad, Strategy: StrategyNearest, X: 8, Y: 9},
+			want: "B",
+		},
+		{
+			name: "nearest tie keeps the smallest location",
+			stacks: map[string]*YFYStack{
+				"B": {StackCount: 1, Placement: Placement{X: 3}},
+				"A": {StackCount: 1, Placement: Placement{X: -3}},
+			},
+			req:  PlacementRequest{Op: OpLoad, Strategy: StrategyNearest},
+			want: "A",
+		},
+		{
+			name: "full, disabled, reserved and other groups are skipped",
+			stacks: map[string]*YFYStack{
+				"A": stack(1, CargoData{ID: "c1"}),
+				"B": {StackCount: 2, Disable: true},
+				"C": {StackCount: 2, Booker: "amr-1"},
+				"D": {StackCount: 2, Placement: Placement{GroupID: "G2"}},
+				"E": stack(2),
+			},
+			req:  PlacementRequest{Op: OpLoad},
+			want: "E",
+		},
+		{
+			name: "load skips the stack that already has the cargo",
+			stacks: map[string]*YFYStack{
+				"A": stack(3, CargoData{ID: "c1"}),
+				"B": stack(3, CargoData{ID: "c2"}),
+			},
+			req:  PlacementRequest{Op: OpLoad, Cargo: CargoData{ID: "c1"}},
+			want: "B",
+		},
+		{
+			name: "offload with a cargo id only matches the top",
+			stacks: map[string]*YFYStack{
+				"A": stack(3, CargoData{ID: "c1"}, CargoData{ID: "c2"}),
+				"B": stack(3, CargoData{ID: "c0"}, CargoData{ID: "c1"}),
+			},
+			req:  PlacementRequest{Op: OpOffload, Cargo: CargoData{ID: "c1"}},
+			want: "B",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := testStacks(t, tt.stacks)
+			tt.req.GroupID = "G1"
+
+			got, err := m.SelectStack(context.Background(), tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.LocationID != tt.want {
+				t.Errorf("SelectStack = %s, want %s", got.LocationID, tt.want)
+			}
+		})
+	}
+}
+
+func TestSelectStackErrors(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{"A": stack(1, CargoData{ID: "c1"})})
+	ctx := context.Background()
+
+	if _, err := m.SelectStack(ctx, PlacementRequest{GroupID: "G1", Op: OpLoad}); !errors.Is(err, ErrNoStackAvailable) {
+		t.Errorf("load on a full stack = %v, want ErrNoStackAvailable", err)
+	}
+	if _, err := m.SelectStack(ctx, PlacementRequest{GroupID: "G1", Op: OpOffload, Strategy: "random"}); !errors.Is(err, ErrUnknownStrategy) {
+		t.Errorf("unknown strategy = %v, want ErrUnknownStrategy", err)
+	}
+	if _, err := m.SelectStack(ctx, PlacementRequest{GroupID: "G1", Op: "move"}); err == nil {
+		t.Error("unknown op succeeded")
+	}
+}
+
+func TestSelectStackRoundRobinWraps(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{
+		"C": stack(2, CargoData{ID: "c3"}),
+		"A": stack(2, CargoData{ID: "c1"}),
+		"B": stack(2, CargoData{ID: "c2"}),
+	})
+	ctx := context.Background()
+
+	pick := func(op PlacementOp) string {
+		t.Helper()
+		got, err := m.SelectStack(ctx, PlacementRequest{GroupID: "G1", Op: op, Strategy: StrategyRoundRobin})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return got.LocationID
+	}
+
+	for i, want := range []string{"A", "B", "C", "A"} {
+		if got := pick(OpLoad); got != want {
+			t.Fatalf("load %d = %s, want %s", i, got, want)
+		}
+	}
+	// 每種操作各自輪流
+	if got := pick(OpOffload); got != "A" {
+		t.Fatalf("first offload = %s, want A", got)
+	}
+
+	// 停用的堆疊不算，B 之後直接回到 A
+	m.infoMap["C"].Disable = true
+	for i, want := range []string{"B", "A"} {
+		if got := pick(OpLoad); got != want {
+			t.Fatalf("load %d after disabling C = %s, want %s", i, got, want)
+		}
+	}
+}
+
+func TestSelectStackReserve(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{
+		"A": {StackCount: 2, Placement: Placement{LoadPriority: 2}},
+		"B": {StackCount: 2, Placement: Placement{LoadPriority: 1}},
+	})
+	ctx := infra.WithActor(context.Background(), "amr-1")
+	req := PlacementRequest{GroupID: "G1", Op: OpLoad, Reserve: true}
+
+	got, err := m.SelectStack(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LocationID != "A" || got.Stack.Booker != "amr-1" {
+		t.Fatalf("SelectStack = %s booked by %q, want A booked by amr-1", got.LocationID, got.Stack.Booker)
+	}
+	if m.infoMap["A"].Booker != "amr-1" {
+		t.Fatalf("stored booker = %q, want amr-1", m.infoMap["A"].Booker)
+	}
+
+	// 已預約的不會再被挑到
+	req.Booker = "amr-2"
+	got, err = m.SelectStack(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LocationID != "B" || got.Stack.Booker != "amr-2" {
+		t.Fatalf("second SelectStack = %s booked by %q, want B booked by amr-2", got.LocationID, got.Stack.Booker)
+	}
+	if _, err := m.SelectStack(ctx, req); !errors.Is(err, ErrNoStackAvailable) {
+		t.Fatalf("third SelectStack = %v, want ErrNoStackAvailable", err)
+	}
+
+	if err := m.Reserve(ctx, "A", "amr-2"); !errors.Is(err, ErrStackReserved) {
+		t.Errorf("Reserve by another booker = %v, want ErrStackReserved", err)
+	}
+	if err := m.Release(ctx, "A", "amr-2"); !errors.Is(err, ErrStackReserved) {
+		t.Errorf("Release by another booker = %v, want ErrStackReserved", err)
+	}
+	if err := m.Release(ctx, "A", "amr-1"); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err = m.SelectStack(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LocationID != "A" {
+		t.Errorf("SelectStack after Release = %s, want A", got.LocationID)
+	}
+}
@@ -0,0 +1,130 @@
+package peripheral
+
+import (
+	"context"
+	"kenmec/peripheral/jimmy/infra"
+	"time"
+)
+
+// EventScriptChanged 在切換腳本並重新載入堆疊後發出
+const EventScriptChanged = "script.changed"
+
+// ScriptChanged 是 EventScriptChanged 的內容
+type ScriptChanged struct {
+	OldScriptID string
+	NewScriptID string
+	StackCount  int
+	Timestamp   time.Time
+}
+
+// ScriptID 回傳目前載入的腳本 ID
+func (m *YFYStackManager) ScriptID() string {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+	return m.scriptId
+}
+
+// ReloadScript 重新讀取 current-script-id，跟目前載入的不同就重新載入 AllStack，
+// 載入完成後才整個替換 infoMap，失敗時保留舊資料
+func (m *YFYStackManager) ReloadScript(ctx context.Context) (*ScriptChanged, error) {
+	scriptId, err := currentScriptID(ctx, m.rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	if scriptId == m.ScriptID() {
+		return nil, nil
+	}
+
+	newMap, err := loadStacks(ctx, m.db, scriptId)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := loadGroups(ctx, m.db, scriptId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 腳本切換時順便更新貨物格式，失敗就沿用舊的
+	if err := m.ReloadMetadataFormats(ctx); err != nil {
+		m.logger.Warn("reload cargo metadata formats failed: %v", err)
+	}
+
+	m.Mu.Lock()
+	change := &ScriptChanged{
+		OldScriptID: m.scriptId,
+		NewScriptID: scriptId,
+		StackCount:  len(newMap),
+		Timestamp:   time.Now(),
+	}
+	m.infoMap = newMap
+	m.groups = groups
+	m.scriptId = scriptId
+	m.recordState(ctx, "script "+scriptId)
+	m.reset() // 下一輪推送會送出新腳本的完整快照
+	m.Mu.Unlock()
+
+	return change, nil
+}
+
+// WatchScript 監聽 current-script-id 的 keyspace notification 以及 channel，
+// 收到訊息就呼叫 ReloadScript，並在 bus 發出 EventScriptChanged。會阻塞到 ctx 取消。
+func (m *YFYStackManager) WatchScript(ctx context.Context, bus *infra.EventBus, channel string) error {
+	channels := []string{m.rdb.KeyspaceChannel(scriptIDKey)}
+	if channel != "" {
+		channels = append(channels, channel)
+	}
+
+	backoff := infra.DefaultBackoff()
+	attempt := 0
+
+	for {
+		msgs, err := m.rdb.Subscribe(ctx, channels...)
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			delay := backoff.Duration(attempt)
+			attempt++
+			m.logger.Warn("subscribe %v failed: %v, retrying in %v", channels, err, delay)
+
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(delay):
+			}
+			continue
+		}
+		attempt = 0
+
+		// 訂閱前可能已經切換過腳本，先檢查一次
+		m.reloadAndPublish(ctx, bus)
+
+		for range msgs {
+			m.reloadAndPublish(ctx, bus)
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *YFYStackManager) reloadAndPublish(ctx context.Context, bus *infra.EventBus) {
+	change, err := m.ReloadScript(ctx)
+	if err != nil {
+		m.logger.Error("reload script failed: %v", err)
+		return
+	}
+	if change == nil {
+		return
+	}
+
+	m.logger.With(infra.FieldScriptID, change.NewScriptID).
+		Info("script changed %s -> %s, %d stacks loaded", change.OldScriptID, change.NewScriptID, change.StackCount)
+	if bus != nil {
+		bus.Publish(EventScriptChanged, *change)
+	}
+}
@@ -0,0 +1,192 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/initial"
+)
+
+// emptyDB 是每個查詢都沒有資料的 database/sql driver，設定 err 後所有查詢都失敗
+type emptyDB struct {
+	mu  sync.Mutex
+	err error
+}
+
+func (d *emptyDB) setErr(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.err = err
+}
+
+func (d *emptyDB) fail() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *emptyDB) Connect(context.Context) (driver.Conn, error) { return emptyConn{d}, nil }
+func (d *emptyDB) Driver() driver.Driver                        { return d }
+func (d *emptyDB) Open(string) (driver.Conn, error)             { return emptyConn{d}, nil }
+
+type emptyConn struct{ d *emptyDB }
+
+func (c emptyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c emptyConn) Close() error              { return nil }
+func (c emptyConn) Begin() (driver.Tx, error) { return emptyTx{}, nil }
+
+func (c emptyConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.fail(); err != nil {
+		return nil, err
+	}
+	return emptyRows{}, nil
+}
+
+func (c emptyConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.fail(); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(0), nil
+}
+
+type emptyTx struct{}
+
+func (emptyTx) Commit() error   { return nil }
+func (emptyTx) Rollback() error { return nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+// testScriptStacks 建立目前載入 script 的堆疊管理器，Redis 用 FakeRedis，資料庫用 emptyDB
+func testScriptStacks(t *testing.T, script string) (*YFYStackManager, *initial.FakeRedis, *emptyDB) {
+	t.Helper()
+	rdb := initial.NewFakeRedis()
+	rdb.Set(scriptIDKey, `"`+script+`"`)
+
+	fake := &emptyDB{}
+	conn := sql.OpenDB(fake)
+	t.Cleanup(func() { conn.Close() })
+
+	m := testStacks(t, map[string]*YFYStack{"L1": stack(2)})
+	m.rdb = rdb
+	m.conn = conn
+	m.db = db.New(conn)
+	m.scriptId = script
+	return m, rdb, fake
+}
+
+func TestReloadScript(t *testing.T) {
+	m, rdb, fake := testScriptStacks(t, "s1")
+	ctx := context.Background()
+
+	// 腳本沒變，不重新載入
+	change, err := m.ReloadScript(ctx)
+	if err != nil || change != nil {
+		t.Fatalf("ReloadScript on the same script = %+v, %v; want nil, nil", change, err)
+	}
+	if _, ok := m.infoMap["L1"]; !ok {
+		t.Fatal("stacks were replaced although the script did not change")
+	}
+
+	// 沒有引號的值也接受
+	rdb.Set(scriptIDKey, "s2")
+	change, err = m.ReloadScript(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if change == nil || change.OldScriptID != "s1" || change.NewScriptID != "s2" || change.StackCount != 0 {
+		t.Fatalf("ReloadScript = %+v, want s1 -> s2 with 0 stacks", change)
+	}
+	if m.ScriptID() != "s2" || len(m.infoMap) != 0 {
+		t.Fatalf("after reload script = %s with %d stacks, want s2 with 0", m.ScriptID(), len(m.infoMap))
+	}
+
+	// 載入失敗時保留原本的腳本
+	fake.setErr(errors.New("connection refused"))
+	rdb.Set(scriptIDKey, `"s3"`)
+	if _, err := m.ReloadScript(ctx); err == nil {
+		t.Fatal("ReloadScript with a failing database succeeded")
+	}
+	if m.ScriptID() != "s2" {
+		t.Fatalf("script after a failed reload = %s, want s2", m.ScriptID())
+	}
+
+	rdb.Set(scriptIDKey, `""`)
+	if _, err := m.ReloadScript(ctx); err == nil {
+		t.Fatal("ReloadScript with an empty script id succeeded")
+	}
+}
+
+func TestReloadScriptMissingKey(t *testing.T) {
+	m := testStacks(t, nil)
+	m.rdb = initial.NewFakeRedis()
+
+	if _, err := m.ReloadScript(context.Background()); !errors.Is(err, initial.ErrNotFound) {
+		t.Fatalf("ReloadScript without %s = %v, want ErrNotFound", scriptIDKey, err)
+	}
+}
+
+func TestWatchScript(t *testing.T) {
+	m, rdb, _ := testScriptStacks(t, "s1")
+	bus := infra.New()
+
+	changes := make(chan ScriptChanged, 8)
+	bus.Subscribe(EventScriptChanged, func(data interface{}) { changes <- data.(ScriptChanged) })
+
+	expect := func(from, to string) {
+		t.Helper()
+		select {
+		case c := <-changes:
+			if c.OldScriptID != from || c.NewScriptID != to {
+				t.Fatalf("script changed %s -> %s, want %s -> %s", c.OldScriptID, c.NewScriptID, from, to)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("no change %s -> %s", from, to)
+		}
+	}
+
+	// 訂閱前就切換的腳本，訂閱後會先檢查一次
+	rdb.Set(scriptIDKey, `"s2"`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.WatchScript(ctx, bus, "script-reload") }()
+	expect("s1", "s2")
+
+	// keyspace 通知
+	rdb.Set(scriptIDKey, `"s3"`)
+	expect("s2", "s3")
+
+	// 額外的頻道只觸發重新檢查，腳本沒變就不發事件
+	rdb.Publish("script-reload", "")
+	rdb.Set(scriptIDKey, `"s4"`)
+	expect("s3", "s4")
+
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("WatchScript = %v, want context.Canceled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WatchScript did not return after cancel")
+	}
+	select {
+	case c := <-changes:
+		t.Fatalf("unexpected change %s -> %s", c.OldScriptID, c.NewScriptID)
+	default:
+	}
+}
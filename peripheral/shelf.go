@@ -0,0 +1,115 @@
+package peripheral
+
+import "errors"
+
+var (
+	ErrShelfNotFound      = errors.New("shelf not found")
+	ErrShelfLevelNotFound = errors.New("shelf level not found")
+	ErrShelfLevelDisabled = errors.New("shelf level is disabled")
+	ErrShelfLevelFull     = errors.New("shelf level reached its cargo limit")
+	ErrShelfLevelEmpty    = errors.New("shelf level is empty")
+	ErrShelfReserved      = errors.New("shelf level is reserved")
+)
+
+// EventShelfChanged 在貨架變動後發出 (Publish)，內容是 Shelf 的複本
+const EventShelfChanged = "shelf.changed"
+
+// Shelf 是放在一個 Loc 上的多層貨架，Levels 依 level 由低到高
+type Shelf struct {
+	ShelfID    string        `json:"shelfId"` // Shelf.id
+	LocationID string        `json:"locationId"`
+	Category   string        `json:"category,omitempty"` // ShelfCategory.name
+	Style      string        `json:"style,omitempty"`    // ShelfCategory.shelf_style
+	Levels     []*ShelfLevel `json:"levels"`
+}
+
+// ShelfLevel 是貨架的一層，對應一筆 ShelfConfig
+type ShelfLevel struct {
+	ConfigID     string `json:"configId"`     // ShelfConfig.id，cargo_info.shelfConfigId 指向它
+	PeripheralID string `json:"peripheralId"` // peripheral_name.id
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+
+	Level  int `json:"level"`
+	Height int `json:"height"` // ShelfHeight.height
+
+	Disable bool   `json:"disable"`
+	Booker  string `json:"booker"`
+
+	// CargoLimit 是這層最多放幾個貨物，資料庫的 0 (預設值) 視為 1
+	CargoLimit int         `json:"cargoLimit"`
+	Cargo      []CargoData `json:"cargo"`
+}
+
+// !! ------  呼叫下面的方法記得用上層的mutex --- !!
+
+func (s *Shelf) clone() Shelf {
+	c := *s
+	c.Levels = make([]*ShelfLevel, 0, len(s.Levels))
+	for _, l := range s.Levels {
+		lc := *l
+		lc.Cargo = append([]CargoData(nil), l.Cargo...)
+		c.Levels = append(c.Levels, &lc)
+	}
+	return c
+}
+
+// level 找出第 n 層
+func (s *Shelf) level(n int) (*ShelfLevel, error) {
+	for _, l := range s.Levels {
+		if l.Level == n {
+			return l, nil
+		}
+	}
+	return nil, ErrShelfLevelNotFound
+}
+
+func (l *ShelfLevel) reserved() bool {
+	return l.Booker != "" && l.Booker != noBooker
+}
+
+// Put 把貨物放到這層，超過 CargoLimit 時回傳 ErrShelfLevelFull
+func (l *ShelfLevel) Put(c CargoData) error {
+	if l.Disable {
+		return ErrShelfLevelDisabled
+	}
+	if len(l.Cargo) >= l.CargoLimit {
+		return ErrShelfLevelFull
+	}
+	for _, existing := range l.Cargo {
+		if existing.ID == c.ID {
+			return ErrCargoExists
+		}
+	}
+
+	l.Cargo = append(l.Cargo, c)
+	return nil
+}
+
+// Take 取走這層的 cargoID，空字串表示最後放上的貨物
+func (l *ShelfLevel) Take(cargoID string) (CargoData, error) {
+	if l.Disable {
+		return CargoData{}, ErrShelfLevelDisabled
+	}
+	if len(l.Cargo) == 0 {
+		return CargoData{}, ErrShelfLevelEmpty
+	}
+
+	i := len(l.Cargo) - 1
+	if cargoID != "" {
+		i = -1
+		for j, c := range l.Cargo {
+			if c.ID == cargoID {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return CargoData{}, ErrCargoNotFound
+		}
+	}
+
+	c := l.Cargo[i]
+	l.Cargo = append(l.Cargo[:i], l.Cargo[i+1:]...)
+	return c, nil
+}
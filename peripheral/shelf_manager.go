@@ -0,0 +1,343 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	stackpb "kenmec/peripheral/jimmy/protoGen"
+	"slices"
+	"sync"
+)
+
+// ShelfManager 管理目前腳本的多層貨架，只存在記憶體
+type ShelfManager struct {
+	infoMap map[string]*Shelf // locationId -> 貨架
+	db      *db.Queries
+	bus     *infra.EventBus
+	logger  infra.Logger
+	clock   infra.Clock
+	// lifecycle 寫入貨物的狀態轉換，nil 表示只檢查不寫入
+	lifecycle *cargo.Recorder
+
+	Mu sync.Mutex
+}
+
+func NewShelfManager(conn *sql.DB, bus *infra.EventBus, logger infra.Logger) *ShelfManager {
+	m := &ShelfManager{
+		infoMap: make(map[string]*Shelf),
+		bus:     bus,
+		logger:  logger.With(infra.FieldComponent, "shelf-manager"),
+		clock:   infra.NewRealClock(),
+	}
+	if conn != nil {
+		m.db = db.New(conn)
+	}
+	return m
+}
+
+// SetClock 替換放置時間的時間來源，nil 表示使用真實時間
+func (m *ShelfManager) SetClock(c infra.Clock) {
+	if c == nil {
+		c = infra.NewRealClock()
+	}
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.clock = c
+}
+
+// SetShelves 讓 ToProto 一併輸出貨架
+func (m *YFYStackManager) SetShelves(sm *ShelfManager) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.shelves = sm
+}
+
+// Load 讀出腳本的所有貨架與貨物並整個替換，失敗時保留舊資料
+func (m *ShelfManager) Load(ctx context.Context, scriptId string) error {
+	if m.db == nil {
+		return nil
+	}
+
+	rows, err := m.db.ListShelfLevels(ctx, sql.NullString{String: scriptId, Valid: true})
+	if err != nil {
+		return fmt.Errorf("load shelves of script %s: %w", scriptId, err)
+	}
+
+	configIds := make([]sql.NullString, 0, len(rows))
+	for _, r := range rows {
+		configIds = append(configIds, sql.NullString{String: r.ShelfConfigID, Valid: true})
+	}
+	rawCargos, err := m.db.ListCargosByShelfConfigIds(ctx, configIds)
+	if err != nil {
+		return fmt.Errorf("load shelf cargo of script %s: %w", scriptId, err)
+	}
+
+	cargoGroups := make(map[string][]CargoData)
+	for _, c := range rawCargos {
+		cargoGroups[c.Shelfconfigid.String] = append(cargoGroups[c.Shelfconfigid.String], CargoData{
+			ID:         c.CargoID,
+			Metadata:   c.CargoMetadata,
+			MetadataID: c.CustomCargoMetadataID.String,
+			CustomID:   c.CargoCustomID.String,
+			Status:     string(c.CargoStatus),
+			PlacedAt:   c.CargoUpdatedAt,
+		})
+	}
+
+	// rows 已依 locationId、level 排序
+	infoMap := make(map[string]*Shelf)
+	for _, r := range rows {
+		s, ok := infoMap[r.Locationid]
+		if !ok {
+			s = &Shelf{
+				ShelfID:    r.ShelfID,
+				LocationID: r.Locationid,
+				Category:   r.CategoryName.String,
+				Style:      r.ShelfStyle.String,
+			}
+			infoMap[r.Locationid] = s
+		}
+
+		l := &ShelfLevel{
+			ConfigID:     r.ShelfConfigID,
+			PeripheralID: r.PeripheralID,
+			Name:         r.PeripheralName.String,
+			Description:  r.PeripheralDesc,
+			Level:        int(r.Level),
+			Height:       int(r.Height),
+			Disable:      r.ShelfDisable,
+			Booker:       noBooker,
+			CargoLimit:   max(int(r.CargoLimit), 1),
+			Cargo:        cargoGroups[r.ShelfConfigID],
+		}
+		if r.HasCargo && len(l.Cargo) == 0 {
+			// hasCargo 沒有對應的 cargo_info，不知道是哪一個
+			l.Cargo = []CargoData{{Status: "AT_LOCATION"}}
+		}
+		if len(l.Cargo) > l.CargoLimit {
+			m.logger.With(infra.FieldLocationID, r.Locationid).Warn("shelf level %d has %d cargos over its limit %d", l.Level, len(l.Cargo), l.CargoLimit)
+		}
+		s.Levels = append(s.Levels, l)
+	}
+
+	m.Mu.Lock()
+	m.infoMap = infoMap
+	m.Mu.Unlock()
+	return nil
+}
+
+// Snapshot 回傳目前所有貨架的複本
+func (m *ShelfManager) Snapshot() map[string]Shelf {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make(map[string]Shelf, len(m.infoMap))
+	for locID, s := range m.infoMap {
+		out[locID] = s.clone()
+	}
+	return out
+}
+
+// Shelf 回傳單一貨架的複本
+func (m *ShelfManager) Shelf(locID string) (Shelf, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return Shelf{}, fmt.Errorf("%w: %s", ErrShelfNotFound, locID)
+	}
+	return s.clone(), nil
+}
+
+// holds 回傳放著 cargoID 的貨架，沒有時回傳空字串
+func (m *ShelfManager) holds(cargoID string) string {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	for locID, s := range m.infoMap {
+		for _, l := range s.Levels {
+			for _, c := range l.Cargo {
+				if c.ID == cargoID {
+					return locID
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// level 找出 locID 貨架的第 n 層，呼叫前必須持有 Mu
+func (m *ShelfManager) level(locID string, n int) (*Shelf, *ShelfLevel, error) {
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrShelfNotFound, locID)
+	}
+	l, err := s.level(n)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s level %d", err, locID, n)
+	}
+	return s, l, nil
+}
+
+// changed 通知訂閱者，呼叫前必須持有 Mu
+func (m *ShelfManager) changed(s *Shelf) {
+	if m.bus != nil {
+		m.bus.Publish(EventShelfChanged, s.clone())
+	}
+}
+
+// booked 確認 context 的操作者可以使用被預約的一層，預約者以外回傳 ErrShelfReserved
+func booked(ctx context.Context, l *ShelfLevel) error {
+	if l.reserved() && l.Booker != infra.ActorFrom(ctx) {
+		return fmt.Errorf("%w by %s", ErrShelfReserved, l.Booker)
+	}
+	return nil
+}
+
+// PutCargo 把貨物放到貨架的第 level 層，狀態規則與 YFYStackManager.PushCargo 相同。
+// 被預約的一層只有預約者 (context 的操作者) 可以放
+func (m *ShelfManager) PutCargo(ctx context.Context, locID string, level int, c CargoData) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, l, err := m.level(locID, level)
+	if err != nil {
+		return err
+	}
+	if err := booked(ctx, l); err != nil {
+		return fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	c, mv, err := arrive(c, cargo.Arrival(cargo.Status(c.Status)), cargo.Holder{Kind: cargo.HolderShelf, ID: locID})
+	if err != nil {
+		return fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	mv.ShelfConfigID = l.ConfigID
+	if c.PlacedAt.IsZero() {
+		c.PlacedAt = m.clock.Now()
+	}
+	before := slices.Clone(l.Cargo)
+	if err := l.Put(c); err != nil {
+		return fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	if err := recordLevel(ctx, m.lifecycle, l, mv); err != nil {
+		l.Cargo = before
+		return fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	m.changed(s)
+	return nil
+}
+
+// TakeCargo 讓 AMR (context 的操作者) 取走第 level 層的 cargoID，空字串表示最後放上的貨物。
+// 被預約的一層只有預約者可以取
+func (m *ShelfManager) TakeCargo(ctx context.Context, locID string, level int, cargoID string) (CargoData, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, l, err := m.level(locID, level)
+	if err != nil {
+		return CargoData{}, err
+	}
+	if err := booked(ctx, l); err != nil {
+		return CargoData{}, fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	before := slices.Clone(l.Cargo)
+	taken, err := l.Take(cargoID)
+	if err != nil {
+		return CargoData{}, fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	c, mv, err := leave(taken, cargo.EventOffload, robotOf(ctx))
+	if err == nil {
+		err = recordLevel(ctx, m.lifecycle, l, mv)
+	}
+	if err != nil {
+		l.Cargo = before
+		return CargoData{}, fmt.Errorf("shelf %s level %d: %w", locID, level, err)
+	}
+	m.changed(s)
+	return c, nil
+}
+
+// Reserve 預約貨架的一層，booker 為空字串時使用 context 的操作者。
+// 已經被別人預約時回傳 ErrShelfReserved，重複預約不算錯誤
+func (m *ShelfManager) Reserve(ctx context.Context, locID string, level int, booker string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, l, err := m.level(locID, level)
+	if err != nil {
+		return err
+	}
+	if booker == "" {
+		booker = infra.ActorFrom(ctx)
+	}
+	if l.Booker == booker {
+		return nil
+	}
+	if l.reserved() {
+		return fmt.Errorf("%w by %s", ErrShelfReserved, l.Booker)
+	}
+
+	l.Booker = booker
+	m.changed(s)
+	return nil
+}
+
+// Release 取消預約。booker 不是空字串時必須是原本的預約者，否則回傳 ErrShelfReserved
+func (m *ShelfManager) Release(ctx context.Context, locID string, level int, booker string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, l, err := m.level(locID, level)
+	if err != nil {
+		return err
+	}
+	if !l.reserved() {
+		return nil
+	}
+	if booker != "" && l.Booker != booker {
+		return fmt.Errorf("%w by %s", ErrShelfReserved, l.Booker)
+	}
+
+	l.Booker = noBooker
+	m.changed(s)
+	return nil
+}
+
+// ToProto 轉成推送給上游的格式，key 是 locationId
+func (m *ShelfManager) ToProto() map[string]*stackpb.Shelf {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make(map[string]*stackpb.Shelf, len(m.infoMap))
+	for locID, s := range m.infoMap {
+		pb := &stackpb.Shelf{
+			ShelfId:    s.ShelfID,
+			Category:   s.Category,
+			ShelfStyle: s.Style,
+		}
+		for _, l := range s.Levels {
+			pl := &stackpb.ShelfLevel{
+				Level:       int32(l.Level),
+				Height:      int32(l.Height),
+				Name:        l.Name,
+				Description: l.Description,
+				Disable:     l.Disable,
+				Booker:      l.Booker,
+				CargoLimit:  int32(l.CargoLimit),
+			}
+			for _, c := range l.Cargo {
+				pl.Cargo = append(pl.Cargo, cargoToProto(c))
+			}
+			pb.Levels = append(pb.Levels, pl)
+		}
+		out[locID] = pb
+	}
+	return out
+}
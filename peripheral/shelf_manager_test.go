@@ -0,0 +1,134 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// testShelf 建立 S1 貨架，第 1 層 (SC1) 最多放 limit 個貨物
+func testShelf(t *testing.T, limit int) *ShelfManager {
+	t.Helper()
+	m := NewShelfManager(nil, nil, testLogger(t))
+	m.infoMap["S1"] = &Shelf{
+		ShelfID:    "shelf-1",
+		LocationID: "S1",
+		Levels:     []*ShelfLevel{{ConfigID: "SC1", Level: 1, Booker: noBooker, CargoLimit: limit}},
+	}
+	return m
+}
+
+func TestShelfCargoLimit(t *testing.T) {
+	m := testShelf(t, 2)
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	for _, id := range []string{"c1", "c2"} {
+		if err := m.PutCargo(ctx, "S1", 1, CargoData{ID: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.PutCargo(ctx, "S1", 1, CargoData{ID: "c3"}); !errors.Is(err, ErrShelfLevelFull) {
+		t.Fatalf("PutCargo over the limit = %v, want ErrShelfLevelFull", err)
+	}
+
+	// 取走一個就能再放
+	if _, err := m.TakeCargo(ctx, "S1", 1, "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.PutCargo(ctx, "S1", 1, CargoData{ID: "c3"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestShelfNotFound(t *testing.T) {
+	m := testShelf(t, 1)
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	tests := []struct {
+		locID string
+		level int
+		want  error
+	}{
+		{"S9", 1, ErrShelfNotFound},
+		{"S1", 2, ErrShelfLevelNotFound},
+	}
+	for _, tt := range tests {
+		if err := m.PutCargo(ctx, tt.locID, tt.level, CargoData{ID: "c1"}); !errors.Is(err, tt.want) {
+			t.Errorf("PutCargo on %s level %d = %v, want %v", tt.locID, tt.level, err, tt.want)
+		}
+		if _, err := m.TakeCargo(ctx, tt.locID, tt.level, ""); !errors.Is(err, tt.want) {
+			t.Errorf("TakeCargo on %s level %d = %v, want %v", tt.locID, tt.level, err, tt.want)
+		}
+		if err := m.Reserve(ctx, tt.locID, tt.level, ""); !errors.Is(err, tt.want) {
+			t.Errorf("Reserve on %s level %d = %v, want %v", tt.locID, tt.level, err, tt.want)
+		}
+	}
+}
+
+func TestShelfReservation(t *testing.T) {
+	m := testShelf(t, 2)
+	amr1 := infra.WithActor(context.Background(), "amr-1")
+	amr2 := infra.WithActor(context.Background(), "amr-2")
+
+	if err := m.Reserve(amr1, "S1", 1, ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Reserve(amr2, "S1", 1, ""); !errors.Is(err, ErrShelfReserved) {
+		t.Fatalf("Reserve by amr-2 = %v, want ErrShelfReserved", err)
+	}
+
+	// 預約者以外不能放也不能取
+	if err := m.PutCargo(amr2, "S1", 1, CargoData{ID: "c1"}); !errors.Is(err, ErrShelfReserved) {
+		t.Fatalf("PutCargo by amr-2 = %v, want ErrShelfReserved", err)
+	}
+	if err := m.PutCargo(amr1, "S1", 1, CargoData{ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.TakeCargo(amr2, "S1", 1, "c1"); !errors.Is(err, ErrShelfReserved) {
+		t.Fatalf("TakeCargo by amr-2 = %v, want ErrShelfReserved", err)
+	}
+
+	if err := m.Release(amr2, "S1", 1, "amr-2"); !errors.Is(err, ErrShelfReserved) {
+		t.Fatalf("Release by amr-2 = %v, want ErrShelfReserved", err)
+	}
+	if err := m.Release(amr1, "S1", 1, "amr-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.TakeCargo(amr2, "S1", 1, "c1"); err != nil {
+		t.Fatalf("TakeCargo after release = %v", err)
+	}
+}
+
+func TestShelfPersistsLevel(t *testing.T) {
+	m := testShelf(t, 1)
+	r, fake := testRecorder(t)
+	m.SetLifecycle(r)
+	ctx := infra.WithActor(context.Background(), "amr-1")
+
+	if err := m.PutCargo(ctx, "S1", 1, CargoData{ID: "c1", Status: string(cargo.StatusOnAMR)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.TakeCargo(ctx, "S1", 1, "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 參數依序是 status, owner, stack_config_id, shelfConfigId, id, from_status
+	updates := fake.args("UpdateCargoLifecycle")
+	if len(updates) != 2 || updates[0][3] != "SC1" || updates[1][3] != nil {
+		t.Fatalf("UpdateCargoLifecycle = %v, want shelfConfigId SC1 then cleared", updates)
+	}
+	levels := fake.args("UpdateShelfHasCargo")
+	want := [][]driver.Value{{true, "SC1"}, {false, "SC1"}}
+	if len(levels) != len(want) {
+		t.Fatalf("UpdateShelfHasCargo = %v, want %v", levels, want)
+	}
+	for i := range want {
+		if levels[i][0] != want[i][0] || levels[i][1] != want[i][1] {
+			t.Fatalf("UpdateShelfHasCargo = %v, want %v", levels, want)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/initial"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotVersion 是快照檔格式的版本，格式不相容時要加一。
+// 2 加入輸送帶與群組，版本 1 的檔案讀進來沒有這兩個欄位
+const SnapshotVersion = 2
+
+// LatestSnapshot 是定期自動寫入的快照名稱，資料庫連不上時啟動會讀它
+const LatestSnapshot = "latest"
+
+const snapshotExt = ".json"
+
+var (
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	ErrSnapshotVersion  = errors.New("unsupported snapshot version")
+	ErrSnapshotName     = errors.New("invalid snapshot name")
+	ErrSnapshotScript   = errors.New("snapshot belongs to another script")
+)
+
+var snapshotName = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// StateSnapshot 是寫到磁碟的週邊狀態：堆疊、輸送帶與群組。
+// 貨架、一般位置與機台不在快照裡，啟動與還原時照常從資料庫載入：
+// 位置的佔用會過期、機台的取放是進行中的計時，還原舊的狀態沒有意義。
+// Conveyors 與 Groups 是 nil 表示快照沒有記錄 (版本 1)，還原時保留目前的輸送帶並從堆疊整理群組
+type StateSnapshot struct {
+	Version   int                        `json:"version"`
+	ScriptID  string                     `json:"scriptId"`
+	CreatedAt time.Time                  `json:"createdAt"`
+	Stacks    map[string]YFYStack        `json:"stacks"`
+	Conveyors map[string]Conveyor        `json:"conveyors"`
+	Groups    map[string]PeripheralGroup `json:"groups"`
+}
+
+// SnapshotInfo 是 List 回傳的摘要
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TakeSnapshot 取得目前狀態的快照
+func (m *YFYStackManager) TakeSnapshot() *StateSnapshot {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	stacks := make(map[string]YFYStack, len(m.infoMap))
+	for locID, s := range m.infoMap {
+		stacks[locID] = s.clone()
+	}
+	conveyors := map[string]Conveyor{}
+	if m.conveyors != nil {
+		conveyors = m.conveyors.Snapshot()
+	}
+
+	return &StateSnapshot{
+		Version:   SnapshotVersion,
+		ScriptID:  m.scriptId,
+		CreatedAt: time.Now(),
+		Stacks:    stacks,
+		Conveyors: conveyors,
+		Groups:    m.groupMap(),
+	}
+}
+
+// Restore 以快照整個取代目前的狀態，有記錄輸送帶時一併取代。
+// 快照必須是目前腳本的，其他腳本的快照回傳 ErrSnapshotScript：
+// 切換腳本要經過 current-script-id，其他週邊才會跟著重新載入
+func (m *YFYStackManager) Restore(ctx context.Context, snap *StateSnapshot) error {
+	infoMap := snap.stackMap()
+
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if m.scriptId != "" && snap.ScriptID != m.scriptId {
+		return fmt.Errorf("%w: snapshot is %s, current is %s", ErrSnapshotScript, snap.ScriptID, m.scriptId)
+	}
+	m.infoMap = infoMap
+	m.groups = snap.groupMap()
+	m.scriptId = snap.ScriptID
+	if m.conveyors != nil && snap.Conveyors != nil {
+		m.conveyors.Restore(snap)
+	}
+	m.recordState(ctx, "restore")
+	m.reset()
+	return nil
+}
+
+func (snap *StateSnapshot) stackMap() map[string]*YFYStack {
+	infoMap := make(map[string]*YFYStack, len(snap.Stacks))
+	for locID, s := range snap.Stacks {
+		c := s.clone()
+		infoMap[locID] = &c
+	}
+	return infoMap
+}
+
+// groupMap 回傳快照的群組，版本 1 的快照回傳 nil，由 YFYStackManager.groupMap 從堆疊整理
+func (snap *StateSnapshot) groupMap() map[string]PeripheralGroup {
+	if snap.Groups == nil {
+		return nil
+	}
+	return maps.Clone(snap.Groups)
+}
+
+// NewStackManagerFromSnapshot 用快照建立 manager，給資料庫連不上時啟動使用。
+// 之後的 AddStack 與腳本切換仍會使用資料庫。
+func NewStackManagerFromSnapshot(snap *StateSnapshot, conn *sql.DB, rdb initial.Redis, bus *infra.EventBus, logger infra.Logger) *YFYStackManager {
+	m := &YFYStackManager{
+		infoMap:  snap.stackMap(),
+		groups:   snap.groupMap(),
+		conn:     conn,
+		rdb:      rdb,
+		logger:   logger.With(infra.FieldComponent, "stack-manager"),
+		bus:      bus,
+		scriptId: snap.ScriptID,
+		IsDirty:  true,
+	}
+	if conn != nil {
+		m.db = db.New(conn)
+	}
+	return m
+}
+
+// SnapshotStore 把快照以 <name>.json 存在同一個目錄
+type SnapshotStore struct {
+	dir string
+}
+
+func NewSnapshotStore(dir string) *SnapshotStore {
+	return &SnapshotStore{dir: dir}
+}
+
+func (s *SnapshotStore) path(name string) (string, error) {
+	if !snapshotName.MatchString(name) || strings.Trim(name, ".") == "" {
+		return "", fmt.Errorf("%w: %q", ErrSnapshotName, name)
+	}
+	return filepath.Join(s.dir, name+snapshotExt), nil
+}
+
+// Save 寫入快照。先寫暫存檔再 rename，寫到一半當機也不會留下壞掉的檔案
+func (s *SnapshotStore) Save(name string, snap *StateSnapshot) error {
+	path, err := s.path(name)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	if err := os.MkdirAll(s.dir, 0o755); err != nil {
+		return fmt.Errorf("create snapshot dir: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, name+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create snapshot file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("close snapshot: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("rename snapshot: %w", err)
+	}
+	return nil
+}
+
+// Load 讀取快照並檢查版本
+func (s *SnapshotStore) Load(name string) (*StateSnapshot, error) {
+	path, err := s.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrSnapshotNotFound, name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read snapshot: %w", err)
+	}
+
+	var snap StateSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("decode snapshot %s: %w", name, err)
+	}
+	if snap.Version < 1 || snap.Version > SnapshotVersion {
+		return nil, fmt.Errorf("%w: %s has version %d, want <= %d", ErrSnapshotVersion, name, snap.Version, SnapshotVersion)
+	}
+	if snap.Stacks == nil {
+		snap.Stacks = map[string]YFYStack{}
+	}
+
+	return &snap, nil
+}
+
+// List 依名稱排序列出所有快照，目錄不存在時回傳空的
+func (s *SnapshotStore) List() ([]SnapshotInfo, error) {
+	entries, err := os.ReadDir(s.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []SnapshotInfo{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list snapshots: %w", err)
+	}
+
+	out := make([]SnapshotInfo, 0, len(entries))
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), snapshotExt)
+		if e.IsDir() || !ok {
+			continue
+		}
+
+		fi, err := e.Info()
+		if err != nil {
+			continue
+		}
+		out = append(out, SnapshotInfo{Name: name, Size: fi.Size(), UpdatedAt: fi.ModTime()})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+
+	return out, nil
+}
+
+// RunSnapshots 每隔 interval 把目前狀態寫到 latest，只在堆疊、輸送帶、機台或貨架有變動時寫，
+// ctx 取消時再寫最後一次。會阻塞到 ctx 取消。
+func (m *YFYStackManager) RunSnapshots(ctx context.Context, store *SnapshotStore, interval time.Duration) error {
+	changed := make(chan struct{}, 1)
+	if m.bus != nil {
+		for _, event := range []string{EventStackChanged, EventConveyorChanged, EventMachineChanged, EventShelfChanged} {
+			subID := m.bus.Subscribe(event, func(interface{}) {
+				select {
+				case changed <- struct{}{}:
+				default:
+				}
+			})
+			defer m.bus.Unsubscribe(event, subID)
+		}
+	}
+
+	save := func() {
+		if err := store.Save(LatestSnapshot, m.TakeSnapshot()); err != nil {
+			m.logger.Warn("save snapshot failed: %v", err)
+		}
+	}
+
+	// 啟動時先寫一次，確保 latest 跟得上目前的腳本
+	save()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			save()
+			return ctx.Err()
+		case <-ticker.C:
+			select {
+			case <-changed:
+				save()
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package peripheral
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/infra"
+)
+
+func testConveyors(t *testing.T, conveyors map[string]*Conveyor) *ConveyorManager {
+	t.Helper()
+	m := NewConveyorManager(nil, nil, testLogger(t))
+	m.infoMap = conveyors
+	return m
+}
+
+func TestSnapshotConveyorsAndGroups(t *testing.T) {
+	src := testStacks(t, map[string]*YFYStack{"L1": stack(2, CargoData{ID: "c1"})})
+	src.scriptId = "s1"
+	src.groups = map[string]PeripheralGroup{"G1": {ID: "G1", Name: "inbound"}}
+	src.SetConveyors(testConveyors(t, map[string]*Conveyor{
+		"C1": {ConveyorID: "conv-1", Booker: noBooker, Cargo: &CargoData{ID: "c2"}},
+	}))
+
+	store := NewSnapshotStore(t.TempDir())
+	if err := store.Save("test", src.TakeSnapshot()); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := store.Load("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Version != SnapshotVersion || len(snap.Conveyors) != 1 || snap.Groups["G1"].Name != "inbound" {
+		t.Fatalf("loaded snapshot = version %d, %d conveyors, groups %v", snap.Version, len(snap.Conveyors), snap.Groups)
+	}
+
+	// 還原到同一個腳本的 manager，堆疊、輸送帶與群組整個換掉
+	dst := testStacks(t, map[string]*YFYStack{"L9": stack(1)})
+	dst.scriptId = "s1"
+	dst.groups = map[string]PeripheralGroup{"G9": {ID: "G9"}}
+	conveyors := testConveyors(t, map[string]*Conveyor{"C9": {Booker: noBooker}})
+	dst.SetConveyors(conveyors)
+
+	if err := dst.Restore(context.Background(), snap); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := dst.Stack("L9"); ok {
+		t.Error("restore kept L9, want the stacks of the snapshot")
+	}
+	if groups := dst.Groups(); len(groups) != 1 || groups[0].Name != "inbound" {
+		t.Errorf("groups after restore = %v, want G1 inbound", groups)
+	}
+	got := conveyors.Snapshot()
+	if _, ok := got["C9"]; ok || got["C1"].Cargo == nil || got["C1"].Cargo.ID != "c2" {
+		t.Errorf("conveyors after restore = %v, want C1 with c2", got)
+	}
+
+	// 用快照啟動時也保留群組名稱
+	m := NewStackManagerFromSnapshot(snap, nil, nil, nil, testLogger(t))
+	if groups := m.Groups(); len(groups) != 1 || groups[0].Name != "inbound" {
+		t.Errorf("groups from snapshot = %v, want G1 inbound", groups)
+	}
+}
+
+func TestSnapshotVersion1(t *testing.T) {
+	dir := t.TempDir()
+	v1 := `{"version":1,"scriptId":"s1","stacks":{"L1":{"stackCount":2,"booker":"none","placement":{"groupId":"G1"}}}}`
+	if err := os.WriteFile(filepath.Join(dir, "old.json"), []byte(v1), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	snap, err := NewSnapshotStore(dir).Load("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snap.Conveyors != nil || snap.Groups != nil {
+		t.Fatalf("version 1 snapshot has conveyors %v and groups %v, want none", snap.Conveyors, snap.Groups)
+	}
+
+	m := testStacks(t, nil)
+	m.groups = map[string]PeripheralGroup{"G9": {ID: "G9"}}
+	conveyors := testConveyors(t, map[string]*Conveyor{"C9": {Booker: noBooker}})
+	m.SetConveyors(conveyors)
+
+	if err := m.Restore(infra.WithActor(context.Background(), "test"), snap); err != nil {
+		t.Fatal(err)
+	}
+
+	// 沒有記錄輸送帶就保留目前的，群組從堆疊整理出來
+	if _, ok := conveyors.Snapshot()["C9"]; !ok {
+		t.Error("version 1 restore dropped the current conveyors")
+	}
+	if groups := m.Groups(); len(groups) != 1 || groups[0].ID != "G1" {
+		t.Errorf("groups after version 1 restore = %v, want G1 from the stacks", groups)
+	}
+}
+
+// TestRestoreOtherScript 其他腳本的快照不能還原，目前的狀態保持不變
+func TestRestoreOtherScript(t *testing.T) {
+	m := testStacks(t, map[string]*YFYStack{"L1": stack(1)})
+	m.scriptId = "s2"
+
+	snap := &StateSnapshot{Version: SnapshotVersion, ScriptID: "s1", Stacks: map[string]YFYStack{"L9": *stack(1)}}
+	if err := m.Restore(context.Background(), snap); !errors.Is(err, ErrSnapshotScript) {
+		t.Fatalf("Restore = %v, want ErrSnapshotScript", err)
+	}
+	if _, ok := m.Stack("L1"); !ok || m.ScriptID() != "s2" {
+		t.Errorf("state after rejected restore = script %s, L1 %v, want s2 with L1", m.ScriptID(), ok)
+	}
+}
+
+// TestRunSnapshotsConveyorChange 只有輸送帶變動時也要寫入 latest
+func TestRunSnapshotsConveyorChange(t *testing.T) {
+	bus := infra.New()
+	m := testStacks(t, nil)
+	m.bus = bus
+	conveyors := NewConveyorManager(nil, bus, testLogger(t))
+	conveyors.infoMap = map[string]*Conveyor{"C1": {PeripheralID: "P1", Booker: noBooker}}
+	m.SetConveyors(conveyors)
+
+	dir := t.TempDir()
+	store := NewSnapshotStore(dir)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.RunSnapshots(ctx, store, 10*time.Millisecond) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	latest := filepath.Join(dir, LatestSnapshot+snapshotExt)
+	waitFile := func(want bool) {
+		t.Helper()
+		for range 200 {
+			if _, err := os.Stat(latest); (err == nil) == want {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		t.Fatalf("latest exists = %v, want %v", !want, want)
+	}
+
+	// 啟動時寫的第一次
+	waitFile(true)
+	if err := os.Remove(latest); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := conveyors.SpawnCargo(context.Background(), "P1", CargoData{ID: "c1"}); err != nil {
+		t.Fatal(err)
+	}
+	waitFile(true)
+
+	snap, err := store.Load(LatestSnapshot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := snap.Conveyors["C1"].Cargo; c == nil || c.ID != "c1" {
+		t.Errorf("conveyor C1 in latest = %v, want c1", c)
+	}
+}
@@ -2,46 +2,113 @@ package peripheral
 
 import (
 	"encoding/json"
+	"kenmec/peripheral/jimmy/cargo"
+	"time"
 )
 
+// json tag 決定快照檔 (snapshot.go) 的格式，修改時要調整 SnapshotVersion
 type YFYStack struct {
-	Name        string
-	Description string
+	StackID      string `json:"stackId,omitempty"`      // stack_config.id，寫回資料庫時使用
+	PeripheralID string `json:"peripheralId,omitempty"` // peripheral_name.id，時間軸用它指定週邊
+	Name         string `json:"name"`
+	Description  string `json:"description"`
 
-	Disable bool
-	Booker  string
+	Disable bool   `json:"disable"`
+	Booker  string `json:"booker"`
 
-	StackCount int //堆堆疊數量
-	Heights    []int
-	Cargo      []CargoData
+	StackCount int         `json:"stackCount"` //堆堆疊數量
+	Heights    []int       `json:"heights"`
+	Cargo      []CargoData `json:"cargo"`
+
+	Placement Placement `json:"placement"`
+}
+
+// Placement 是挑選堆疊 (placement.go) 用的設定，來自 peripheral_name 與 Loc
+type Placement struct {
+	GroupID         string  `json:"groupId,omitempty"` // peripheral_name.group_id
+	LoadPriority    int     `json:"loadPriority"`
+	OffloadPriority int     `json:"offloadPriority"`
+	Priority        int     `json:"priority"` // Loc.placement_priority
+	X               float64 `json:"x"`
+	Y               float64 `json:"y"`
 }
 
 type CargoData struct {
-	ID       string
+	ID       string          `json:"id"`
 	Metadata json.RawMessage `json:"metadata"`
+	// MetadataID 是 custom_cargo_metadata.id，Metadata 依它的 format 檢查
+	MetadataID string `json:"metadataId,omitempty"`
+	CustomID   string `json:"customId,omitempty"`
+	Status     string `json:"status,omitempty"` // cargo_info.status，例如 AT_LOCATION
+	// PlacedAt 是放到目前堆疊的時間，StrategyFIFO 依它排序
+	PlacedAt time.Time `json:"placedAt,omitzero"`
+	// Holder 是最後一次移動後拿著貨物的位置或 AMR，從資料庫載入的貨物沒有
+	Holder cargo.Holder `json:"holder,omitzero"`
 }
 
 func NewStack(data YFYStack) *YFYStack {
 
 	return &YFYStack{
-		Name:        data.Name,
-		Description: data.Description,
-		Disable:     data.Disable,
-		Booker:      "none",
+		StackID:      data.StackID,
+		PeripheralID: data.PeripheralID,
+		Name:         data.Name,
+		Description:  data.Description,
+		Disable:      data.Disable,
+		Booker:       "none",
 
 		Heights:    data.Heights,
 		StackCount: data.StackCount,
 		Cargo:      data.Cargo,
+		Placement:  data.Placement,
 	}
 
 }
 
 // !! ------  呼叫下面的方法記得用上層的mutex --- !!
 
+func (ns *YFYStack) clone() YFYStack {
+	c := *ns
+	c.Heights = append([]int(nil), ns.Heights...)
+	c.Cargo = append([]CargoData(nil), ns.Cargo...)
+	return c
+}
+
 func (ns *YFYStack) UpdateAllCargo(c []CargoData) {
 	ns.Cargo = c
 }
 
+// Push 把貨物放到最上層 (Cargo 的最後一個)
+func (ns *YFYStack) Push(c CargoData) error {
+	if ns.Disable {
+		return ErrStackDisabled
+	}
+	if len(ns.Cargo) >= ns.StackCount {
+		return ErrStackFull
+	}
+	for _, existing := range ns.Cargo {
+		if existing.ID == c.ID {
+			return ErrCargoExists
+		}
+	}
+
+	ns.Cargo = append(ns.Cargo, c)
+	return nil
+}
+
+// Pop 取出最上層的貨物
+func (ns *YFYStack) Pop() (CargoData, error) {
+	if ns.Disable {
+		return CargoData{}, ErrStackDisabled
+	}
+	if len(ns.Cargo) == 0 {
+		return CargoData{}, ErrStackEmpty
+	}
+
+	top := ns.Cargo[len(ns.Cargo)-1]
+	ns.Cargo = ns.Cargo[:len(ns.Cargo)-1]
+	return top, nil
+}
+
 func (ns *YFYStack) UpdateConfig(name string, desc string, disable bool) {
 	ns.Name = name
 	ns.Description = desc
@@ -4,36 +4,138 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"kenmec/peripheral/jimmy/cargo"
 	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
 	"kenmec/peripheral/jimmy/initial"
 	stackpb "kenmec/peripheral/jimmy/protoGen"
+	"slices"
 	"strconv"
 	"sync"
+	"time"
 )
 
+const scriptIDKey = "current-script-id"
+
 type YFYStackManager struct {
-	infoMap map[string]*YFYStack
-	db      *db.Queries
-	IsDirty bool //如果有變動 變true時在傳出去
+	infoMap  map[string]*YFYStack
+	conn     *sql.DB // Transfer 需要交易
+	db       *db.Queries
+	rdb      initial.Redis
+	logger   infra.Logger
+	bus      *infra.EventBus
+	journal  *Journal
+	groups   map[string]PeripheralGroup
+	formats  map[string]*MetadataFormat // nil 表示還沒載入，不檢查 metadata
+	index    *cargoIndex                // nil 表示還沒建立，第一次 FindCargo 時建立
+	scriptId string
+	IsDirty  bool //如果有變動 變true時在傳出去
+
+	// strategies 是 SelectStack 可用的策略，nil 表示還沒註冊，第一次使用時放入內建策略
+	strategies map[string]PlacementStrategy
+
+	// clock 提供放置時間與事件時間，模擬加速時換成 scaled clock
+	clock infra.Clock
+
+	// conveyors 跟著堆疊一起寫入快照與還原，nil 表示沒有載入輸送帶
+	conveyors *ConveyorManager
+	// machines 的狀態跟著堆疊一起推送，nil 表示沒有模擬機台
+	machines *MachineManager
+	// shelves 的貨架跟著堆疊一起推送，nil 表示沒有載入貨架
+	shelves *ShelfManager
+	// areas 是一般位置的佔用，跟著堆疊一起推送，nil 表示沒有載入
+	areas *AreaManager
+	// lifecycle 寫入貨物的狀態轉換，nil 表示只檢查不寫入
+	lifecycle *cargo.Recorder
 
 	Mu sync.Mutex
 }
 
-func NewStackManager(q *db.Queries) *YFYStackManager {
+// currentScriptID 讀取目前使用中的腳本 ID，Redis 存的是 JSON 字串所以要 Unquote
+func currentScriptID(ctx context.Context, rdb initial.Redis) (string, error) {
+	txt, err := rdb.Get(ctx, scriptIDKey)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", scriptIDKey, err)
+	}
+
+	scriptId, err := strconv.Unquote(txt)
+	if err != nil {
+		// 不是被引號包住的字串就直接使用
+		scriptId = txt
+	}
+	if scriptId == "" {
+		return "", fmt.Errorf("%s is empty", scriptIDKey)
+	}
+
+	return scriptId, nil
+}
+
+func NewStackManager(conn *sql.DB, rdb initial.Redis, bus *infra.EventBus, logger infra.Logger) (*YFYStackManager, error) {
 
 	ctx := context.Background()
-	txt := initial.Rdb.Get(ctx, "current-script-id").Val()
+	q := db.New(conn)
+	scriptId, err := currentScriptID(ctx, rdb)
+	if err != nil {
+		return nil, err
+	}
+
+	defaultMap, err := loadStacks(ctx, q, scriptId)
+	if err != nil {
+		return nil, err
+	}
 
-	scriptId, _ := strconv.Unquote(txt)
+	groups, err := loadGroups(ctx, q, scriptId)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &YFYStackManager{
+		infoMap:  defaultMap,
+		groups:   groups,
+		conn:     conn,
+		db:       q,
+		rdb:      rdb,
+		logger:   logger.With(infra.FieldComponent, "stack-manager"),
+		bus:      bus,
+		scriptId: scriptId,
+		IsDirty:  true,
+		clock:    infra.NewRealClock(),
+	}
+
+	if err := m.ReloadMetadataFormats(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// SetClock 替換時間來源，nil 表示使用真實時間
+func (m *YFYStackManager) SetClock(c infra.Clock) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	m.clock = c
+}
+
+// now 回傳 clock 的目前時間，呼叫前必須持有 Mu
+func (m *YFYStackManager) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock.Now()
+}
+
+// loadStacks 從資料庫讀出腳本的所有堆疊與貨物
+func loadStacks(ctx context.Context, q *db.Queries, scriptId string) (map[string]*YFYStack, error) {
 
 	defaultMap := make(map[string]*YFYStack)
 
 	dbData, qErr := q.AllStack(ctx, scriptId)
 
 	if qErr != nil {
-
-		panic(qErr)
+		return nil, fmt.Errorf("load stacks of script %s: %w", scriptId, qErr)
 	}
 
 	// 2. 收集所有 Stack ID
@@ -56,8 +158,12 @@ func NewStackManager(q *db.Queries) *YFYStackManager {
 	cargoGroups := make(map[string][]CargoData)
 	for _, c := range rawCargos {
 		cargoGroups[c.StackConfigID.String] = append(cargoGroups[c.StackConfigID.String], CargoData{
-			ID:       c.CargoID,
-			Metadata: c.CargoMetadata,
+			ID:         c.CargoID,
+			Metadata:   c.CargoMetadata,
+			MetadataID: c.CustomCargoMetadataID.String,
+			CustomID:   c.CargoCustomID.String,
+			Status:     string(c.CargoStatus),
+			PlacedAt:   c.CargoUpdatedAt,
 		})
 	}
 
@@ -66,103 +172,362 @@ func NewStackManager(q *db.Queries) *YFYStackManager {
 		_ = json.Unmarshal(v.StackHeights, &heights)
 
 		s := NewStack(YFYStack{
-			Name:        v.PeripheralName.String,
-			Description: v.PeripheralDesc,
-			Disable:     v.StackDisable,
-			Booker:      "none",
-			Heights:     heights,
-			StackCount:  int(v.StackCount),
+			StackID:      v.Stackid,
+			PeripheralID: v.PeripheralID,
+			Name:         v.PeripheralName.String,
+			Description:  v.PeripheralDesc,
+			Disable:      v.StackDisable,
+			Booker:       "none",
+			Heights:      heights,
+			StackCount:   int(v.StackCount),
 			// 直接從 Map 拿該 Stack 的貨物列表，沒貨物就是 nil/空 slice
 			Cargo: cargoGroups[v.Stackid],
+			Placement: Placement{
+				GroupID:         v.GroupID.String,
+				LoadPriority:    int(v.LoadPriority),
+				OffloadPriority: int(v.OffloadPriority),
+				Priority:        int(v.PlacementPriority),
+				X:               v.X,
+				Y:               v.Y,
+			},
 		})
 
 		defaultMap[v.Locationid] = s
 	}
 
-	return &YFYStackManager{
-		infoMap: defaultMap,
-		db:      q,
-		IsDirty: true,
-	}
+	return defaultMap, nil
 }
 
-func (m *YFYStackManager) AddStack(locationId string) {
+// AddStack 從資料庫讀取目前腳本在 locationId 的堆疊設定並加入管理
+func (m *YFYStackManager) AddStack(ctx context.Context, locationId string) error {
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
 
-	ctx := context.Background()
-	txt := initial.Rdb.Get(ctx, "current-script-id").Val()
-
-	scriptId, _ := strconv.Unquote(txt)
+	if _, ok := m.infoMap[locationId]; ok {
+		return ErrStackExists
+	}
 
 	dbData, err := m.db.OneStack(ctx, db.OneStackParams{
-		ID:         scriptId,
+		ID:         m.scriptId,
 		Locationid: locationId,
 	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrStackNotFound
+	}
 	if err != nil {
-
-		return
+		m.logger.With(infra.FieldLocationID, locationId, infra.FieldScriptID, m.scriptId).
+			Error("add stack failed: %v", err)
+		return fmt.Errorf("load stack %s: %w", locationId, err)
 	}
 
 	var heights []int
 	_ = json.Unmarshal(dbData.StackHeights, &heights)
 
 	s := NewStack(YFYStack{
-		Name:        dbData.PeripheralName.String,
-		Description: dbData.PeripheralDesc,
-		Disable:     dbData.StackDisable,
-		Booker:      "none",
-		Heights:     heights,
-		StackCount:  int(dbData.StackCount),
-		Cargo:       []CargoData{},
+		StackID:      dbData.Stackid,
+		PeripheralID: dbData.PeripheralID,
+		Name:         dbData.PeripheralName.String,
+		Description:  dbData.PeripheralDesc,
+		Disable:      dbData.StackDisable,
+		Booker:       "none",
+		Heights:      heights,
+		StackCount:   int(dbData.StackCount),
+		Cargo:        []CargoData{},
+		Placement: Placement{
+			GroupID:         dbData.GroupID.String,
+			LoadPriority:    int(dbData.LoadPriority),
+			OffloadPriority: int(dbData.OffloadPriority),
+			Priority:        int(dbData.PlacementPriority),
+			X:               dbData.X,
+			Y:               dbData.Y,
+		},
 	})
 
 	m.infoMap[locationId] = s
-	m.IsDirty = true
+	m.recordStack(ctx, JournalStackAdded, locationId)
+	m.changed(locationId)
+	return nil
 }
 
-func (m *YFYStackManager) DeleteStack(locationId string) {
+func (m *YFYStackManager) DeleteStack(ctx context.Context, locationId string) error {
 	m.Mu.Lock()
 	defer m.Mu.Unlock()
 
+	s, ok := m.infoMap[locationId]
+	if !ok {
+		return ErrStackNotFound
+	}
+	// 堆疊上的貨物跟著移出場域
+	_, moves, err := shiftAll(s.Cargo)
+	if err != nil {
+		return err
+	}
+
+	if err := recordMoves(ctx, m.lifecycle, moves...); err != nil {
+		return err
+	}
+
 	delete(m.infoMap, locationId)
-	m.IsDirty = true
+	m.recordStack(ctx, JournalStackDeleted, locationId)
+	m.changed(locationId)
+	return nil
+}
+
+func (m *YFYStackManager) UpdatestackConfig(ctx context.Context, locID string, name string, desc string, disable bool) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return ErrStackNotFound
+	}
+
+	typ := JournalStackUpdated
+	if disable != s.Disable {
+		typ = JournalStackEnable
+		if disable {
+			typ = JournalStackDisable
+		}
+	}
+
+	s.UpdateConfig(name, desc, disable)
+	m.recordStack(ctx, typ, locID)
+	m.changed(locID)
+	return nil
+}
+
+// UpdateCargo 整份替換堆疊的貨物。原本就在堆疊上的貨物不算移動，
+// 新的貨物跟 PushCargo 一樣依狀態算 spawn 或 load，被拿掉的貨物移出場域
+func (m *YFYStackManager) UpdateCargo(ctx context.Context, locID string, cs []CargoData) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return ErrStackNotFound
+	}
+
+	// 檢查時會回填預設的 MetadataID，複製一份避免改到呼叫端的 slice
+	cs = append([]CargoData(nil), cs...)
+	if err := m.validateCargo(cs, locID); err != nil {
+		return err
+	}
+
+	here := cargo.Holder{Kind: cargo.HolderStack, ID: locID}
+	kept := make(map[string]bool, len(cs))
+	var moves []cargo.Move
+	for i, c := range cs {
+		if slices.ContainsFunc(s.Cargo, func(old CargoData) bool { return old.ID == c.ID }) {
+			kept[c.ID] = true
+			continue
+		}
+		if where := m.placed(c.ID); where != "" {
+			return fmt.Errorf("%w: %s is on %s", ErrCargoExists, c.ID, where)
+		}
+		c, mv, err := arrive(c, cargo.Arrival(cargo.Status(c.Status)), here)
+		if err != nil {
+			return err
+		}
+		mv.StackConfigID = s.StackID
+		cs[i] = c
+		moves = append(moves, mv)
+	}
+
+	var removed []CargoData
+	for _, old := range s.Cargo {
+		if !kept[old.ID] {
+			removed = append(removed, old)
+		}
+	}
+	_, shifted, err := shiftAll(removed)
+	if err != nil {
+		return err
+	}
+
+	if err := recordMoves(ctx, m.lifecycle, append(moves, shifted...)...); err != nil {
+		return err
+	}
+
+	s.UpdateAllCargo(cs)
+	m.record(ctx, JournalEntry{Type: JournalCargoReplaced, LocationID: locID, Cargo: cs})
+	m.changed(locID)
+	return nil
 }
 
-func (m *YFYStackManager) UpdatestackConfig(locID string, name string, desc string, disable bool) {
-	m.Mu.Unlock()
+// Stack 回傳單一堆疊的複本
+func (m *YFYStackManager) Stack(locID string) (YFYStack, bool) {
+	m.Mu.Lock()
 	defer m.Mu.Unlock()
 
 	s, ok := m.infoMap[locID]
+	if !ok {
+		return YFYStack{}, false
+	}
+	return s.clone(), true
+}
 
-	if ok {
-		s.UpdateConfig(name, desc, disable)
-		m.IsDirty = true
+// PushCargo 把貨物放到堆疊最上層。沒有狀態或 PRE_SPAWN 的貨物算生成，
+// ON_AMR 的貨物算 AMR 放貨，其餘狀態回傳 cargo.ErrIllegalTransition。
+// 貨物已經放在任何位置 (包括別的堆疊) 時回傳 ErrCargoExists
+func (m *YFYStackManager) PushCargo(ctx context.Context, locID string, c CargoData) error {
+	return m.push(ctx, locID, c, cargo.Arrival(cargo.Status(c.Status)))
+}
+
+// PopCargo 讓 AMR (context 的操作者) 取走堆疊最上層的貨物，回傳的貨物狀態是 ON_AMR
+func (m *YFYStackManager) PopCargo(ctx context.Context, locID string) (CargoData, error) {
+	return m.pop(ctx, locID, cargo.EventOffload, robotOf(ctx))
+}
+
+// OffloadCargo 同 PopCargo，但由 robotID (例如機械手臂的位置) 拿著貨物
+func (m *YFYStackManager) OffloadCargo(ctx context.Context, locID, robotID string) (CargoData, error) {
+	return m.pop(ctx, locID, cargo.EventOffload, cargo.Holder{Kind: cargo.HolderRobot, ID: robotID})
+}
+
+func (m *YFYStackManager) push(ctx context.Context, locID string, c CargoData, ev cargo.Event) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	s, ok := m.infoMap[locID]
+	if !ok {
+		return ErrStackNotFound
 	}
+
+	batch := []CargoData{c}
+	if err := m.validateCargo(batch, ""); err != nil {
+		return err
+	}
+	// 同一個貨物不能同時放在兩個位置
+	if where := m.placed(c.ID); where != "" {
+		return fmt.Errorf("%w: %s is on %s", ErrCargoExists, c.ID, where)
+	}
+	c, mv, err := arrive(batch[0], ev, cargo.Holder{Kind: cargo.HolderStack, ID: locID})
+	if err != nil {
+		return err
+	}
+	mv.StackConfigID = s.StackID
+	if c.PlacedAt.IsZero() {
+		c.PlacedAt = m.now()
+	}
+
+	// 先在複本上放，資料庫寫入成功才換回去
+	next := s.clone()
+	if err := next.Push(c); err != nil {
+		return err
+	}
+	if err := recordMoves(ctx, m.lifecycle, mv); err != nil {
+		return err
+	}
+	*s = next
+	m.record(ctx, JournalEntry{Type: JournalCargoPushed, LocationID: locID, Cargo: []CargoData{c}})
+	m.changed(locID)
+	return nil
 }
 
-func (m *YFYStackManager) UpdateCargo(locID string, cargo []CargoData) {
-	m.Mu.Unlock()
+func (m *YFYStackManager) pop(ctx context.Context, locID string, ev cargo.Event, to cargo.Holder) (CargoData, error) {
+	m.Mu.Lock()
 	defer m.Mu.Unlock()
 
 	s, ok := m.infoMap[locID]
+	if !ok {
+		return CargoData{}, ErrStackNotFound
+	}
 
-	if ok {
-		s.UpdateAllCargo(cargo)
-		m.IsDirty = true
+	// 先在複本上取，檢查與資料庫寫入都成功才換回去
+	next := s.clone()
+	top, err := next.Pop()
+	if err != nil {
+		return CargoData{}, err
+	}
+	c, mv, err := leave(top, ev, to)
+	if err != nil {
+		return CargoData{}, err
 	}
+	if err := recordMoves(ctx, m.lifecycle, mv); err != nil {
+		return CargoData{}, err
+	}
+	*s = next
+	m.record(ctx, JournalEntry{Type: JournalCargoPopped, LocationID: locID, Cargo: []CargoData{c}})
+	m.changed(locID)
+	return c, nil
 }
 
-func (m *YFYStackManager) PrintDebug() {
+// locationOf 找出 peripheral_name.id 對應的堆疊位置，呼叫前必須持有 Mu
+func (m *YFYStackManager) locationOf(peripheralID string) (string, error) {
+	for locID, s := range m.infoMap {
+		if s.PeripheralID == peripheralID {
+			return locID, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrPeripheralNotFound, peripheralID)
+}
+
+// LocationOf 回傳 peripheralID 堆疊的 locationId，不是堆疊回傳 ErrPeripheralNotFound
+func (m *YFYStackManager) LocationOf(peripheralID string) (string, error) {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	return m.locationOf(peripheralID)
+}
+
+// SpawnCargo 把新貨物放到 peripheralID 的堆疊最上層，不是堆疊回傳 ErrPeripheralNotFound
+func (m *YFYStackManager) SpawnCargo(ctx context.Context, peripheralID string, c CargoData) error {
+	locID, err := m.LocationOf(peripheralID)
+	if err != nil {
+		return err
+	}
+	return m.push(ctx, locID, c, cargo.EventSpawn)
+}
 
-	output, err := json.MarshalIndent(m.infoMap, "", "    ")
+// ShiftCargo 把 peripheralID 堆疊最上層的貨物移出場域，不是堆疊回傳 ErrPeripheralNotFound
+func (m *YFYStackManager) ShiftCargo(ctx context.Context, peripheralID string) (CargoData, error) {
+	locID, err := m.LocationOf(peripheralID)
 	if err != nil {
-		fmt.Printf("Error marshaling debug info: %v\n", err)
-		return
+		return CargoData{}, err
+	}
+	return m.ShiftCargoAt(ctx, locID)
+}
+
+// ShiftCargoAt 把 locID 堆疊最上層的貨物移出場域，回傳的貨物狀態是 SHIFT
+func (m *YFYStackManager) ShiftCargoAt(ctx context.Context, locID string) (CargoData, error) {
+	return m.pop(ctx, locID, cargo.EventShift, cargo.Holder{})
+}
+
+// StackStat 是單一堆疊的統計資料，給 metrics 使用
+type StackStat struct {
+	LocationID string
+	Name       string
+	CargoCount int
+	Capacity   int
+	Reserved   bool
+}
+
+func (m *YFYStackManager) StackStats() []StackStat {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	stats := make([]StackStat, 0, len(m.infoMap))
+	for locID, s := range m.infoMap {
+		stats = append(stats, StackStat{
+			LocationID: locID,
+			Name:       s.Name,
+			CargoCount: len(s.Cargo),
+			Capacity:   s.StackCount,
+			Reserved:   s.reserved(),
+		})
+	}
+	return stats
+}
+
+// Snapshot 回傳目前所有堆疊的複本，呼叫端不需要持有鎖
+func (m *YFYStackManager) Snapshot() map[string]YFYStack {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	out := make(map[string]YFYStack, len(m.infoMap))
+	for locID, s := range m.infoMap {
+		out[locID] = s.clone()
 	}
-	fmt.Println("--- Current Stack Manager Data ---")
-	fmt.Println(string(output))
-	fmt.Println("----------------------------------")
+	return out
 }
 
 // ToProto 將 Manager 內部的 map 轉換為 gRPC 專用的傳輸格式
@@ -175,8 +540,11 @@ func (m *YFYStackManager) ToProto() *stackpb.StackMapResponse {
 		var pbCargos []*stackpb.Cargo
 		for _, c := range s.Cargo {
 			pbCargos = append(pbCargos, &stackpb.Cargo{
-				Id:       c.ID,
-				Metadata: c.Metadata, // []byte 直接對應 bytes
+				Id:         c.ID,
+				Metadata:   c.Metadata, // []byte 直接對應 bytes
+				CustomId:   c.CustomID,
+				Status:     c.Status,
+				MetadataId: c.MetadataID,
 			})
 		}
 
@@ -197,7 +565,40 @@ func (m *YFYStackManager) ToProto() *stackpb.StackMapResponse {
 		}
 	}
 
+	var groups []*stackpb.GroupSummary
+	for _, g := range m.groupSummaries() {
+		groups = append(groups, &stackpb.GroupSummary{
+			GroupId:    g.GroupID,
+			Name:       g.Name,
+			Members:    int32(g.Members),
+			Disabled:   int32(g.Disabled),
+			Reserved:   int32(g.Reserved),
+			Capacity:   int32(g.Capacity),
+			CargoCount: int32(g.CargoCount),
+			FreeSlots:  int32(g.FreeSlots),
+			Available:  g.Available(),
+		})
+	}
+
+	// 鎖的順序是 m.Mu -> machines.Mu、shelves.Mu、areas.Mu，它們都不會持有自己的鎖呼叫堆疊
+	var machines []*stackpb.Machine
+	if m.machines != nil {
+		machines = m.machines.ToProto()
+	}
+	var shelves map[string]*stackpb.Shelf
+	if m.shelves != nil {
+		shelves = m.shelves.ToProto()
+	}
+	var areas map[string]*stackpb.Area
+	if m.areas != nil {
+		areas = m.areas.ToProto()
+	}
+
 	return &stackpb.StackMapResponse{
-		InfoMap: protoMap,
+		InfoMap:  protoMap,
+		Groups:   groups,
+		Machines: machines,
+		Shelves:  shelves,
+		Areas:    areas,
 	}
 }
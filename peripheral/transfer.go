@@ -0,0 +1,139 @@
+package peripheral
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+)
+
+// Transfer 把 fromLoc 最上層的貨物 cargoID 移到 toLoc 的最上層。
+// 兩個堆疊都檢查完、資料庫交易也成功後才修改記憶體，中途失敗不會留下一半的結果。
+func (m *YFYStackManager) Transfer(ctx context.Context, fromLoc, toLoc, cargoID string) error {
+	m.Mu.Lock()
+	defer m.Mu.Unlock()
+
+	if fromLoc == toLoc {
+		return ErrSameStack
+	}
+
+	from, ok := m.infoMap[fromLoc]
+	if !ok {
+		return fmt.Errorf("from %s: %w", fromLoc, ErrStackNotFound)
+	}
+	to, ok := m.infoMap[toLoc]
+	if !ok {
+		return fmt.Errorf("to %s: %w", toLoc, ErrStackNotFound)
+	}
+
+	// 先在複本上操作，兩邊都成功才換回去
+	src, dst := from.clone(), to.clone()
+
+	if err := checkTop(&src, cargoID); err != nil {
+		return fmt.Errorf("from %s: %w", fromLoc, err)
+	}
+	c, err := src.Pop()
+	if err != nil {
+		return fmt.Errorf("from %s: %w", fromLoc, err)
+	}
+	// TRANSFER 紀錄由 persistTransfer 寫入
+	c, _, err = leave(c, cargo.EventTransfer, cargo.Holder{Kind: cargo.HolderStack, ID: toLoc})
+	if err != nil {
+		return err
+	}
+	c.PlacedAt = m.now()
+	if err := dst.Push(c); err != nil {
+		return fmt.Errorf("to %s: %w", toLoc, err)
+	}
+
+	if err := m.persistTransfer(ctx, from.StackID, to.StackID, cargoID, fromLoc+" -> "+toLoc); err != nil {
+		m.logger.With(infra.FieldLocationID, fromLoc).
+			Error("transfer %s to %s failed: %v", cargoID, toLoc, err)
+		return err
+	}
+
+	*from, *to = src, dst
+
+	m.record(ctx, JournalEntry{Type: JournalCargoTransferred, LocationID: fromLoc, ToLocationID: toLoc, Cargo: []CargoData{c}})
+	m.transferred(fromLoc, toLoc)
+	return nil
+}
+
+// checkTop 確認 cargoID 在最上層，堆疊只能從最上層取貨
+func checkTop(s *YFYStack, cargoID string) error {
+	if n := len(s.Cargo); n > 0 && s.Cargo[n-1].ID == cargoID {
+		return nil
+	}
+	for _, c := range s.Cargo {
+		if c.ID == cargoID {
+			return ErrCargoNotOnTop
+		}
+	}
+	return ErrCargoNotFound
+}
+
+// persistTransfer 在同一個交易裡更新 cargo_info.stack_config_id 並寫入 TRANSFER 紀錄。
+// 只存在記憶體的貨物 (例如從 API 放上去的) 在資料庫沒有資料，不寫紀錄；
+// 資料庫記錄在別的堆疊則回傳 ErrCargoMoved，記憶體維持原狀
+func (m *YFYStackManager) persistTransfer(ctx context.Context, fromStackID, toStackID, cargoID, desc string) error {
+	if m.conn == nil {
+		return nil
+	}
+
+	tx, err := m.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transfer: %w", err)
+	}
+	defer tx.Rollback()
+
+	q := m.db.WithTx(tx)
+
+	res, err := q.MoveCargo(ctx, db.MoveCargoParams{
+		ToStackID:   nullString(toStackID),
+		CargoID:     cargoID,
+		FromStackID: nullString(fromStackID),
+	})
+	if err != nil {
+		return fmt.Errorf("move cargo %s: %w", cargoID, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("move cargo %s: %w", cargoID, err)
+	}
+	if n == 0 {
+		stackID, err := q.GetCargoStack(ctx, cargoID)
+		if errors.Is(err, sql.ErrNoRows) {
+			m.logger.Debug("cargo %s is not in cargo_info, transfer kept in memory only", cargoID)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("get cargo %s: %w", cargoID, err)
+		}
+		return fmt.Errorf("cargo %s is on stack %q, not %q: %w", cargoID, stackID.String, fromStackID, ErrCargoMoved)
+	}
+
+	err = q.InsertCargoHistory(ctx, db.InsertCargoHistoryParams{
+		ID:          rand.Text(),
+		CargoID:     cargoID,
+		Action:      db.CargoHistoryActionTRANSFER,
+		Description: nullString(desc),
+		Actor:       nullString(infra.ActorFrom(ctx)),
+	})
+	if err != nil {
+		return fmt.Errorf("insert cargo history: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit transfer: %w", err)
+	}
+	return nil
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,91 @@
+package peripheral
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"testing"
+
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/db"
+)
+
+// cargoStackDB 的 MoveCargo 一律沒有更新到資料，GetCargoStack 回傳 stackID
+type cargoStackDB struct{ stackID string }
+
+func (d cargoStackDB) Connect(context.Context) (driver.Conn, error) { return d, nil }
+func (d cargoStackDB) Driver() driver.Driver                        { return d }
+func (d cargoStackDB) Open(string) (driver.Conn, error)             { return d, nil }
+func (d cargoStackDB) Close() error                                 { return nil }
+func (d cargoStackDB) Begin() (driver.Tx, error)                    { return emptyTx{}, nil }
+
+func (d cargoStackDB) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+
+func (d cargoStackDB) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(0), nil
+}
+
+func (d cargoStackDB) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &cargoStackRows{stackID: d.stackID}, nil
+}
+
+type cargoStackRows struct {
+	stackID string
+	done    bool
+}
+
+func (r *cargoStackRows) Columns() []string { return []string{"stack_config_id"} }
+func (r *cargoStackRows) Close() error      { return nil }
+
+func (r *cargoStackRows) Next(dest []driver.Value) error {
+	if r.done {
+		return io.EOF
+	}
+	dest[0], r.done = r.stackID, true
+	return nil
+}
+
+// transferStacks 建立 L1 (S1，上面有 c1) 和 L2 (S2，空的) 兩個堆疊，資料庫用 connector
+func transferStacks(t *testing.T, connector driver.Connector) *YFYStackManager {
+	t.Helper()
+	m := testStacks(t, map[string]*YFYStack{
+		"L1": {StackID: "S1", StackCount: 2, Cargo: []CargoData{{ID: "c1", Status: string(cargo.StatusAtLocation)}}},
+		"L2": {StackID: "S2", StackCount: 2},
+	})
+	conn := sql.OpenDB(connector)
+	t.Cleanup(func() { conn.Close() })
+	m.conn = conn
+	m.db = db.New(conn)
+	return m
+}
+
+func TestTransferCargoOnlyInMemory(t *testing.T) {
+	m := transferStacks(t, &emptyDB{})
+
+	if err := m.Transfer(context.Background(), "L1", "L2", "c1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.infoMap["L1"].Cargo) != 0 || len(m.infoMap["L2"].Cargo) != 1 {
+		t.Fatalf("after transfer L1 has %v and L2 has %v, want c1 on L2", m.infoMap["L1"].Cargo, m.infoMap["L2"].Cargo)
+	}
+}
+
+func TestTransferCargoOnAnotherStack(t *testing.T) {
+	// 資料庫記錄 c1 在 S9，記憶體和資料庫不一致，不能當成只在記憶體的貨物
+	m := transferStacks(t, cargoStackDB{stackID: "S9"})
+
+	err := m.Transfer(context.Background(), "L1", "L2", "c1")
+	if !errors.Is(err, ErrCargoMoved) {
+		t.Fatalf("Transfer = %v, want ErrCargoMoved", err)
+	}
+	if cs := m.infoMap["L1"].Cargo; len(cs) != 1 || cs[0].ID != "c1" || cs[0].Status != string(cargo.StatusAtLocation) {
+		t.Fatalf("L1 after a failed transfer = %v, want c1 unchanged", cs)
+	}
+	if cs := m.infoMap["L2"].Cargo; len(cs) != 0 {
+		t.Fatalf("L2 after a failed transfer = %v, want empty", cs)
+	}
+}
@@ -26,6 +26,9 @@ type Cargo struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
 	Metadata      []byte                 `protobuf:"bytes,2,opt,name=metadata,proto3" json:"metadata,omitempty"` // 對應你的 json.RawMessage
+	CustomId      string                 `protobuf:"bytes,3,opt,name=custom_id,json=customId,proto3" json:"custom_id,omitempty"`
+	Status        string                 `protobuf:"bytes,4,opt,name=status,proto3" json:"status,omitempty"`                           // cargo_info.status
+	MetadataId    string                 `protobuf:"bytes,5,opt,name=metadata_id,json=metadataId,proto3" json:"metadata_id,omitempty"` // custom_cargo_metadata.id
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -74,34 +77,585 @@ func (x *Cargo) GetMetadata() []byte {
 	return nil
 }
 
+func (x *Cargo) GetCustomId() string {
+	if x != nil {
+		return x.CustomId
+	}
+	return ""
+}
+
+func (x *Cargo) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *Cargo) GetMetadataId() string {
+	if x != nil {
+		return x.MetadataId
+	}
+	return ""
+}
+
 // 堆棧資訊
 type Stack struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
-	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
-	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
-	Disable       bool                   `protobuf:"varint,3,opt,name=disable,proto3" json:"disable,omitempty"`
-	StackCount    int32                  `protobuf:"varint,4,opt,name=stack_count,json=stackCount,proto3" json:"stack_count,omitempty"`
-	Heights       []int32                `protobuf:"varint,5,rep,packed,name=heights,proto3" json:"heights,omitempty"` // repeated 代表 slice
-	Cargo         []*Cargo               `protobuf:"bytes,6,rep,name=cargo,proto3" json:"cargo,omitempty"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,2,opt,name=description,proto3" json:"description,omitempty"`
+	Disable       bool                   `protobuf:"varint,3,opt,name=disable,proto3" json:"disable,omitempty"`
+	StackCount    int32                  `protobuf:"varint,4,opt,name=stack_count,json=stackCount,proto3" json:"stack_count,omitempty"`
+	Heights       []int32                `protobuf:"varint,5,rep,packed,name=heights,proto3" json:"heights,omitempty"` // repeated 代表 slice
+	Cargo         []*Cargo               `protobuf:"bytes,6,rep,name=cargo,proto3" json:"cargo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Stack) Reset() {
+	*x = Stack{}
+	mi := &file_stack_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Stack) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Stack) ProtoMessage() {}
+
+func (x *Stack) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Stack.ProtoReflect.Descriptor instead.
+func (*Stack) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Stack) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Stack) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *Stack) GetDisable() bool {
+	if x != nil {
+		return x.Disable
+	}
+	return false
+}
+
+func (x *Stack) GetStackCount() int32 {
+	if x != nil {
+		return x.StackCount
+	}
+	return 0
+}
+
+func (x *Stack) GetHeights() []int32 {
+	if x != nil {
+		return x.Heights
+	}
+	return nil
+}
+
+func (x *Stack) GetCargo() []*Cargo {
+	if x != nil {
+		return x.Cargo
+	}
+	return nil
+}
+
+// peripheral_group 的彙總狀態，讓派車端以群組為單位決定
+type GroupSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GroupId       string                 `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Members       int32                  `protobuf:"varint,3,opt,name=members,proto3" json:"members,omitempty"`
+	Disabled      int32                  `protobuf:"varint,4,opt,name=disabled,proto3" json:"disabled,omitempty"`
+	Reserved      int32                  `protobuf:"varint,5,opt,name=reserved,proto3" json:"reserved,omitempty"`
+	Capacity      int32                  `protobuf:"varint,6,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	CargoCount    int32                  `protobuf:"varint,7,opt,name=cargo_count,json=cargoCount,proto3" json:"cargo_count,omitempty"`
+	FreeSlots     int32                  `protobuf:"varint,8,opt,name=free_slots,json=freeSlots,proto3" json:"free_slots,omitempty"` // 只算啟用且沒被預約的堆疊
+	Available     bool                   `protobuf:"varint,9,opt,name=available,proto3" json:"available,omitempty"`                  // free_slots > 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupSummary) Reset() {
+	*x = GroupSummary{}
+	mi := &file_stack_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupSummary) ProtoMessage() {}
+
+func (x *GroupSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupSummary.ProtoReflect.Descriptor instead.
+func (*GroupSummary) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *GroupSummary) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *GroupSummary) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *GroupSummary) GetMembers() int32 {
+	if x != nil {
+		return x.Members
+	}
+	return 0
+}
+
+func (x *GroupSummary) GetDisabled() int32 {
+	if x != nil {
+		return x.Disabled
+	}
+	return 0
+}
+
+func (x *GroupSummary) GetReserved() int32 {
+	if x != nil {
+		return x.Reserved
+	}
+	return 0
+}
+
+func (x *GroupSummary) GetCapacity() int32 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *GroupSummary) GetCargoCount() int32 {
+	if x != nil {
+		return x.CargoCount
+	}
+	return 0
+}
+
+func (x *GroupSummary) GetFreeSlots() int32 {
+	if x != nil {
+		return x.FreeSlots
+	}
+	return 0
+}
+
+func (x *GroupSummary) GetAvailable() bool {
+	if x != nil {
+		return x.Available
+	}
+	return false
+}
+
+// 機械手臂、疊棧機與旋轉台的狀態，只有對應種類的欄位有值
+type Machine struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	LocationId string                 `protobuf:"bytes,1,opt,name=location_id,json=locationId,proto3" json:"location_id,omitempty"`
+	Name       string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Type       string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`   // ROBOTIC_ARM、PALLETIZER、ROTATE_TABLE
+	State      string                 `protobuf:"bytes,4,opt,name=state,proto3" json:"state,omitempty"` // idle、busy、full、fault
+	Error      string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	// ROBOTIC_ARM
+	From    string `protobuf:"bytes,6,opt,name=from,proto3" json:"from,omitempty"`
+	To      string `protobuf:"bytes,7,opt,name=to,proto3" json:"to,omitempty"`
+	Holding *Cargo `protobuf:"bytes,8,opt,name=holding,proto3" json:"holding,omitempty"`
+	Cycles  int32  `protobuf:"varint,9,opt,name=cycles,proto3" json:"cycles,omitempty"`
+	// PALLETIZER
+	PatternCount int32    `protobuf:"varint,10,opt,name=pattern_count,json=patternCount,proto3" json:"pattern_count,omitempty"` // 疊滿一板的層數
+	LayerSize    int32    `protobuf:"varint,11,opt,name=layer_size,json=layerSize,proto3" json:"layer_size,omitempty"`          // 每層的貨物數
+	Layers       int32    `protobuf:"varint,12,opt,name=layers,proto3" json:"layers,omitempty"`                                 // 已完成的層數
+	Cargo        []*Cargo `protobuf:"bytes,13,rep,name=cargo,proto3" json:"cargo,omitempty"`
+	Pallets      int32    `protobuf:"varint,14,opt,name=pallets,proto3" json:"pallets,omitempty"` // 已放行的板數
+	// ROTATE_TABLE，角度單位是度
+	Angle         float64 `protobuf:"fixed64,15,opt,name=angle,proto3" json:"angle,omitempty"`
+	TargetAngle   float64 `protobuf:"fixed64,16,opt,name=target_angle,json=targetAngle,proto3" json:"target_angle,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Machine) Reset() {
+	*x = Machine{}
+	mi := &file_stack_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Machine) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Machine) ProtoMessage() {}
+
+func (x *Machine) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Machine.ProtoReflect.Descriptor instead.
+func (*Machine) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Machine) GetLocationId() string {
+	if x != nil {
+		return x.LocationId
+	}
+	return ""
+}
+
+func (x *Machine) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Machine) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Machine) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Machine) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *Machine) GetFrom() string {
+	if x != nil {
+		return x.From
+	}
+	return ""
+}
+
+func (x *Machine) GetTo() string {
+	if x != nil {
+		return x.To
+	}
+	return ""
+}
+
+func (x *Machine) GetHolding() *Cargo {
+	if x != nil {
+		return x.Holding
+	}
+	return nil
+}
+
+func (x *Machine) GetCycles() int32 {
+	if x != nil {
+		return x.Cycles
+	}
+	return 0
+}
+
+func (x *Machine) GetPatternCount() int32 {
+	if x != nil {
+		return x.PatternCount
+	}
+	return 0
+}
+
+func (x *Machine) GetLayerSize() int32 {
+	if x != nil {
+		return x.LayerSize
+	}
+	return 0
+}
+
+func (x *Machine) GetLayers() int32 {
+	if x != nil {
+		return x.Layers
+	}
+	return 0
+}
+
+func (x *Machine) GetCargo() []*Cargo {
+	if x != nil {
+		return x.Cargo
+	}
+	return nil
+}
+
+func (x *Machine) GetPallets() int32 {
+	if x != nil {
+		return x.Pallets
+	}
+	return 0
+}
+
+func (x *Machine) GetAngle() float64 {
+	if x != nil {
+		return x.Angle
+	}
+	return 0
+}
+
+func (x *Machine) GetTargetAngle() float64 {
+	if x != nil {
+		return x.TargetAngle
+	}
+	return 0
+}
+
+// 貨架的一層，對應一筆 ShelfConfig
+type ShelfLevel struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Level         int32                  `protobuf:"varint,1,opt,name=level,proto3" json:"level,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"` // ShelfHeight.height
+	Name          string                 `protobuf:"bytes,3,opt,name=name,proto3" json:"name,omitempty"`
+	Description   string                 `protobuf:"bytes,4,opt,name=description,proto3" json:"description,omitempty"`
+	Disable       bool                   `protobuf:"varint,5,opt,name=disable,proto3" json:"disable,omitempty"`
+	Booker        string                 `protobuf:"bytes,6,opt,name=booker,proto3" json:"booker,omitempty"` // "none" 表示沒有預約
+	CargoLimit    int32                  `protobuf:"varint,7,opt,name=cargo_limit,json=cargoLimit,proto3" json:"cargo_limit,omitempty"`
+	Cargo         []*Cargo               `protobuf:"bytes,8,rep,name=cargo,proto3" json:"cargo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ShelfLevel) Reset() {
+	*x = ShelfLevel{}
+	mi := &file_stack_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ShelfLevel) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ShelfLevel) ProtoMessage() {}
+
+func (x *ShelfLevel) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ShelfLevel.ProtoReflect.Descriptor instead.
+func (*ShelfLevel) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *ShelfLevel) GetLevel() int32 {
+	if x != nil {
+		return x.Level
+	}
+	return 0
+}
+
+func (x *ShelfLevel) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *ShelfLevel) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ShelfLevel) GetDescription() string {
+	if x != nil {
+		return x.Description
+	}
+	return ""
+}
+
+func (x *ShelfLevel) GetDisable() bool {
+	if x != nil {
+		return x.Disable
+	}
+	return false
+}
+
+func (x *ShelfLevel) GetBooker() string {
+	if x != nil {
+		return x.Booker
+	}
+	return ""
+}
+
+func (x *ShelfLevel) GetCargoLimit() int32 {
+	if x != nil {
+		return x.CargoLimit
+	}
+	return 0
+}
+
+func (x *ShelfLevel) GetCargo() []*Cargo {
+	if x != nil {
+		return x.Cargo
+	}
+	return nil
+}
+
+// 多層貨架 (Shelf)，levels 由低到高
+type Shelf struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ShelfId       string                 `protobuf:"bytes,1,opt,name=shelf_id,json=shelfId,proto3" json:"shelf_id,omitempty"`
+	Category      string                 `protobuf:"bytes,2,opt,name=category,proto3" json:"category,omitempty"` // ShelfCategory.name
+	ShelfStyle    string                 `protobuf:"bytes,3,opt,name=shelf_style,json=shelfStyle,proto3" json:"shelf_style,omitempty"`
+	Levels        []*ShelfLevel          `protobuf:"bytes,4,rep,name=levels,proto3" json:"levels,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Shelf) Reset() {
+	*x = Shelf{}
+	mi := &file_stack_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Shelf) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Shelf) ProtoMessage() {}
+
+func (x *Shelf) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Shelf.ProtoReflect.Descriptor instead.
+func (*Shelf) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *Shelf) GetShelfId() string {
+	if x != nil {
+		return x.ShelfId
+	}
+	return ""
+}
+
+func (x *Shelf) GetCategory() string {
+	if x != nil {
+		return x.Category
+	}
+	return ""
+}
+
+func (x *Shelf) GetShelfStyle() string {
+	if x != nil {
+		return x.ShelfStyle
+	}
+	return ""
+}
+
+func (x *Shelf) GetLevels() []*ShelfLevel {
+	if x != nil {
+		return x.Levels
+	}
+	return nil
+}
+
+// 佔用一般位置的 AMR 或貨物
+type AreaClaim struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Holder        string                 `protobuf:"bytes,1,opt,name=holder,proto3" json:"holder,omitempty"`
+	Kind          string                 `protobuf:"bytes,2,opt,name=kind,proto3" json:"kind,omitempty"`                                     // robot、cargo
+	ClaimedAtMs   int64                  `protobuf:"varint,3,opt,name=claimed_at_ms,json=claimedAtMs,proto3" json:"claimed_at_ms,omitempty"` // unix 毫秒
+	ExpiresAtMs   int64                  `protobuf:"varint,4,opt,name=expires_at_ms,json=expiresAtMs,proto3" json:"expires_at_ms,omitempty"` // unix 毫秒，0 表示不會過期
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
-func (x *Stack) Reset() {
-	*x = Stack{}
-	mi := &file_stack_proto_msgTypes[1]
+func (x *AreaClaim) Reset() {
+	*x = AreaClaim{}
+	mi := &file_stack_proto_msgTypes[6]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
 
-func (x *Stack) String() string {
+func (x *AreaClaim) String() string {
 	return protoimpl.X.MessageStringOf(x)
 }
 
-func (*Stack) ProtoMessage() {}
+func (*AreaClaim) ProtoMessage() {}
 
-func (x *Stack) ProtoReflect() protoreflect.Message {
-	mi := &file_stack_proto_msgTypes[1]
+func (x *AreaClaim) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[6]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -112,49 +666,104 @@ func (x *Stack) ProtoReflect() protoreflect.Message {
 	return mi.MessageOf(x)
 }
 
-// Deprecated: Use Stack.ProtoReflect.Descriptor instead.
-func (*Stack) Descriptor() ([]byte, []int) {
-	return file_stack_proto_rawDescGZIP(), []int{1}
+// Deprecated: Use AreaClaim.ProtoReflect.Descriptor instead.
+func (*AreaClaim) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{6}
 }
 
-func (x *Stack) GetName() string {
+func (x *AreaClaim) GetHolder() string {
 	if x != nil {
-		return x.Name
+		return x.Holder
 	}
 	return ""
 }
 
-func (x *Stack) GetDescription() string {
+func (x *AreaClaim) GetKind() string {
 	if x != nil {
-		return x.Description
+		return x.Kind
 	}
 	return ""
 }
 
-func (x *Stack) GetDisable() bool {
+func (x *AreaClaim) GetClaimedAtMs() int64 {
 	if x != nil {
-		return x.Disable
+		return x.ClaimedAtMs
 	}
-	return false
+	return 0
 }
 
-func (x *Stack) GetStackCount() int32 {
+func (x *AreaClaim) GetExpiresAtMs() int64 {
 	if x != nil {
-		return x.StackCount
+		return x.ExpiresAtMs
 	}
 	return 0
 }
 
-func (x *Stack) GetHeights() []int32 {
+// STORAGE、DISPATCH、STANDBY、EXTRA 位置的佔用狀態
+type Area struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	AreaType      string                 `protobuf:"bytes,2,opt,name=area_type,json=areaType,proto3" json:"area_type,omitempty"`
+	Capacity      int32                  `protobuf:"varint,3,opt,name=capacity,proto3" json:"capacity,omitempty"` // peripheral_name.quantity，0 視為 1
+	Claims        []*AreaClaim           `protobuf:"bytes,4,rep,name=claims,proto3" json:"claims,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Area) Reset() {
+	*x = Area{}
+	mi := &file_stack_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Area) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Area) ProtoMessage() {}
+
+func (x *Area) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[7]
 	if x != nil {
-		return x.Heights
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
 	}
-	return nil
+	return mi.MessageOf(x)
 }
 
-func (x *Stack) GetCargo() []*Cargo {
+// Deprecated: Use Area.ProtoReflect.Descriptor instead.
+func (*Area) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Area) GetName() string {
 	if x != nil {
-		return x.Cargo
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Area) GetAreaType() string {
+	if x != nil {
+		return x.AreaType
+	}
+	return ""
+}
+
+func (x *Area) GetCapacity() int32 {
+	if x != nil {
+		return x.Capacity
+	}
+	return 0
+}
+
+func (x *Area) GetClaims() []*AreaClaim {
+	if x != nil {
+		return x.Claims
 	}
 	return nil
 }
@@ -163,13 +772,17 @@ func (x *Stack) GetCargo() []*Cargo {
 type StackMapResponse struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	InfoMap       map[string]*Stack      `protobuf:"bytes,1,rep,name=info_map,json=infoMap,proto3" json:"info_map,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	Groups        []*GroupSummary        `protobuf:"bytes,2,rep,name=groups,proto3" json:"groups,omitempty"`                                                                             // 依 group_id 排序
+	Machines      []*Machine             `protobuf:"bytes,3,rep,name=machines,proto3" json:"machines,omitempty"`                                                                         // 依 location_id 排序
+	Shelves       map[string]*Shelf      `protobuf:"bytes,4,rep,name=shelves,proto3" json:"shelves,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"` // key 是 locationId
+	Areas         map[string]*Area       `protobuf:"bytes,5,rep,name=areas,proto3" json:"areas,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`     // key 是 locationId
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *StackMapResponse) Reset() {
 	*x = StackMapResponse{}
-	mi := &file_stack_proto_msgTypes[2]
+	mi := &file_stack_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -181,7 +794,7 @@ func (x *StackMapResponse) String() string {
 func (*StackMapResponse) ProtoMessage() {}
 
 func (x *StackMapResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_stack_proto_msgTypes[2]
+	mi := &file_stack_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -194,7 +807,7 @@ func (x *StackMapResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StackMapResponse.ProtoReflect.Descriptor instead.
 func (*StackMapResponse) Descriptor() ([]byte, []int) {
-	return file_stack_proto_rawDescGZIP(), []int{2}
+	return file_stack_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *StackMapResponse) GetInfoMap() map[string]*Stack {
@@ -204,6 +817,34 @@ func (x *StackMapResponse) GetInfoMap() map[string]*Stack {
 	return nil
 }
 
+func (x *StackMapResponse) GetGroups() []*GroupSummary {
+	if x != nil {
+		return x.Groups
+	}
+	return nil
+}
+
+func (x *StackMapResponse) GetMachines() []*Machine {
+	if x != nil {
+		return x.Machines
+	}
+	return nil
+}
+
+func (x *StackMapResponse) GetShelves() map[string]*Shelf {
+	if x != nil {
+		return x.Shelves
+	}
+	return nil
+}
+
+func (x *StackMapResponse) GetAreas() map[string]*Area {
+	if x != nil {
+		return x.Areas
+	}
+	return nil
+}
+
 // 空訊息，用於 WatchStacks 請求
 type Empty struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -213,7 +854,7 @@ type Empty struct {
 
 func (x *Empty) Reset() {
 	*x = Empty{}
-	mi := &file_stack_proto_msgTypes[3]
+	mi := &file_stack_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -225,7 +866,7 @@ func (x *Empty) String() string {
 func (*Empty) ProtoMessage() {}
 
 func (x *Empty) ProtoReflect() protoreflect.Message {
-	mi := &file_stack_proto_msgTypes[3]
+	mi := &file_stack_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -238,7 +879,7 @@ func (x *Empty) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Empty.ProtoReflect.Descriptor instead.
 func (*Empty) Descriptor() ([]byte, []int) {
-	return file_stack_proto_rawDescGZIP(), []int{3}
+	return file_stack_proto_rawDescGZIP(), []int{9}
 }
 
 type Location struct {
@@ -250,7 +891,7 @@ type Location struct {
 
 func (x *Location) Reset() {
 	*x = Location{}
-	mi := &file_stack_proto_msgTypes[4]
+	mi := &file_stack_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -262,7 +903,7 @@ func (x *Location) String() string {
 func (*Location) ProtoMessage() {}
 
 func (x *Location) ProtoReflect() protoreflect.Message {
-	mi := &file_stack_proto_msgTypes[4]
+	mi := &file_stack_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -275,7 +916,7 @@ func (x *Location) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use Location.ProtoReflect.Descriptor instead.
 func (*Location) Descriptor() ([]byte, []int) {
-	return file_stack_proto_rawDescGZIP(), []int{4}
+	return file_stack_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *Location) GetLocationid() string {
@@ -285,14 +926,221 @@ func (x *Location) GetLocationid() string {
 	return ""
 }
 
+// FindCargo 的查詢條件，三選一
+type FindCargoRequest struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Key:
+	//
+	//	*FindCargoRequest_Id
+	//	*FindCargoRequest_CustomId
+	//	*FindCargoRequest_MetadataValue
+	Key           isFindCargoRequest_Key `protobuf_oneof:"key"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FindCargoRequest) Reset() {
+	*x = FindCargoRequest{}
+	mi := &file_stack_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FindCargoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindCargoRequest) ProtoMessage() {}
+
+func (x *FindCargoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindCargoRequest.ProtoReflect.Descriptor instead.
+func (*FindCargoRequest) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *FindCargoRequest) GetKey() isFindCargoRequest_Key {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *FindCargoRequest) GetId() string {
+	if x != nil {
+		if x, ok := x.Key.(*FindCargoRequest_Id); ok {
+			return x.Id
+		}
+	}
+	return ""
+}
+
+func (x *FindCargoRequest) GetCustomId() string {
+	if x != nil {
+		if x, ok := x.Key.(*FindCargoRequest_CustomId); ok {
+			return x.CustomId
+		}
+	}
+	return ""
+}
+
+func (x *FindCargoRequest) GetMetadataValue() string {
+	if x != nil {
+		if x, ok := x.Key.(*FindCargoRequest_MetadataValue); ok {
+			return x.MetadataValue
+		}
+	}
+	return ""
+}
+
+type isFindCargoRequest_Key interface {
+	isFindCargoRequest_Key()
+}
+
+type FindCargoRequest_Id struct {
+	Id string `protobuf:"bytes,1,opt,name=id,proto3,oneof"`
+}
+
+type FindCargoRequest_CustomId struct {
+	CustomId string `protobuf:"bytes,2,opt,name=custom_id,json=customId,proto3,oneof"`
+}
+
+type FindCargoRequest_MetadataValue struct {
+	MetadataValue string `protobuf:"bytes,3,opt,name=metadata_value,json=metadataValue,proto3,oneof"` // 設定檔 cargo.index_metadata_key 指定的 metadata 欄位
+}
+
+func (*FindCargoRequest_Id) isFindCargoRequest_Key() {}
+
+func (*FindCargoRequest_CustomId) isFindCargoRequest_Key() {}
+
+func (*FindCargoRequest_MetadataValue) isFindCargoRequest_Key() {}
+
+type CargoLocation struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	LocationId    string                 `protobuf:"bytes,1,opt,name=location_id,json=locationId,proto3" json:"location_id,omitempty"`
+	Position      int32                  `protobuf:"varint,2,opt,name=position,proto3" json:"position,omitempty"` // 0 是最底層
+	Cargo         *Cargo                 `protobuf:"bytes,3,opt,name=cargo,proto3" json:"cargo,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CargoLocation) Reset() {
+	*x = CargoLocation{}
+	mi := &file_stack_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CargoLocation) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CargoLocation) ProtoMessage() {}
+
+func (x *CargoLocation) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CargoLocation.ProtoReflect.Descriptor instead.
+func (*CargoLocation) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *CargoLocation) GetLocationId() string {
+	if x != nil {
+		return x.LocationId
+	}
+	return ""
+}
+
+func (x *CargoLocation) GetPosition() int32 {
+	if x != nil {
+		return x.Position
+	}
+	return 0
+}
+
+func (x *CargoLocation) GetCargo() *Cargo {
+	if x != nil {
+		return x.Cargo
+	}
+	return nil
+}
+
+type FindCargoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Locations     []*CargoLocation       `protobuf:"bytes,1,rep,name=locations,proto3" json:"locations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *FindCargoResponse) Reset() {
+	*x = FindCargoResponse{}
+	mi := &file_stack_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *FindCargoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*FindCargoResponse) ProtoMessage() {}
+
+func (x *FindCargoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_stack_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use FindCargoResponse.ProtoReflect.Descriptor instead.
+func (*FindCargoResponse) Descriptor() ([]byte, []int) {
+	return file_stack_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *FindCargoResponse) GetLocations() []*CargoLocation {
+	if x != nil {
+		return x.Locations
+	}
+	return nil
+}
+
 var File_stack_proto protoreflect.FileDescriptor
 
 const file_stack_proto_rawDesc = "" +
 	"\n" +
-	"\vstack.proto\x12\rperipheral_pb\"3\n" +
+	"\vstack.proto\x12\rperipheral_pb\"\x89\x01\n" +
 	"\x05Cargo\x12\x0e\n" +
 	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1a\n" +
-	"\bmetadata\x18\x02 \x01(\fR\bmetadata\"\xbe\x01\n" +
+	"\bmetadata\x18\x02 \x01(\fR\bmetadata\x12\x1b\n" +
+	"\tcustom_id\x18\x03 \x01(\tR\bcustomId\x12\x16\n" +
+	"\x06status\x18\x04 \x01(\tR\x06status\x12\x1f\n" +
+	"\vmetadata_id\x18\x05 \x01(\tR\n" +
+	"metadataId\"\xbe\x01\n" +
 	"\x05Stack\x12\x12\n" +
 	"\x04name\x18\x01 \x01(\tR\x04name\x12 \n" +
 	"\vdescription\x18\x02 \x01(\tR\vdescription\x12\x18\n" +
@@ -300,22 +1148,106 @@ const file_stack_proto_rawDesc = "" +
 	"\vstack_count\x18\x04 \x01(\x05R\n" +
 	"stackCount\x12\x18\n" +
 	"\aheights\x18\x05 \x03(\x05R\aheights\x12*\n" +
-	"\x05cargo\x18\x06 \x03(\v2\x14.peripheral_pb.CargoR\x05cargo\"\xad\x01\n" +
+	"\x05cargo\x18\x06 \x03(\v2\x14.peripheral_pb.CargoR\x05cargo\"\x89\x02\n" +
+	"\fGroupSummary\x12\x19\n" +
+	"\bgroup_id\x18\x01 \x01(\tR\agroupId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x18\n" +
+	"\amembers\x18\x03 \x01(\x05R\amembers\x12\x1a\n" +
+	"\bdisabled\x18\x04 \x01(\x05R\bdisabled\x12\x1a\n" +
+	"\breserved\x18\x05 \x01(\x05R\breserved\x12\x1a\n" +
+	"\bcapacity\x18\x06 \x01(\x05R\bcapacity\x12\x1f\n" +
+	"\vcargo_count\x18\a \x01(\x05R\n" +
+	"cargoCount\x12\x1d\n" +
+	"\n" +
+	"free_slots\x18\b \x01(\x05R\tfreeSlots\x12\x1c\n" +
+	"\tavailable\x18\t \x01(\bR\tavailable\"\xc5\x03\n" +
+	"\aMachine\x12\x1f\n" +
+	"\vlocation_id\x18\x01 \x01(\tR\n" +
+	"locationId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x14\n" +
+	"\x05state\x18\x04 \x01(\tR\x05state\x12\x14\n" +
+	"\x05error\x18\x05 \x01(\tR\x05error\x12\x12\n" +
+	"\x04from\x18\x06 \x01(\tR\x04from\x12\x0e\n" +
+	"\x02to\x18\a \x01(\tR\x02to\x12.\n" +
+	"\aholding\x18\b \x01(\v2\x14.peripheral_pb.CargoR\aholding\x12\x16\n" +
+	"\x06cycles\x18\t \x01(\x05R\x06cycles\x12#\n" +
+	"\rpattern_count\x18\n" +
+	" \x01(\x05R\fpatternCount\x12\x1d\n" +
+	"\n" +
+	"layer_size\x18\v \x01(\x05R\tlayerSize\x12\x16\n" +
+	"\x06layers\x18\f \x01(\x05R\x06layers\x12*\n" +
+	"\x05cargo\x18\r \x03(\v2\x14.peripheral_pb.CargoR\x05cargo\x12\x18\n" +
+	"\apallets\x18\x0e \x01(\x05R\apallets\x12\x14\n" +
+	"\x05angle\x18\x0f \x01(\x01R\x05angle\x12!\n" +
+	"\ftarget_angle\x18\x10 \x01(\x01R\vtargetAngle\"\xef\x01\n" +
+	"\n" +
+	"ShelfLevel\x12\x14\n" +
+	"\x05level\x18\x01 \x01(\x05R\x05level\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x12\n" +
+	"\x04name\x18\x03 \x01(\tR\x04name\x12 \n" +
+	"\vdescription\x18\x04 \x01(\tR\vdescription\x12\x18\n" +
+	"\adisable\x18\x05 \x01(\bR\adisable\x12\x16\n" +
+	"\x06booker\x18\x06 \x01(\tR\x06booker\x12\x1f\n" +
+	"\vcargo_limit\x18\a \x01(\x05R\n" +
+	"cargoLimit\x12*\n" +
+	"\x05cargo\x18\b \x03(\v2\x14.peripheral_pb.CargoR\x05cargo\"\x92\x01\n" +
+	"\x05Shelf\x12\x19\n" +
+	"\bshelf_id\x18\x01 \x01(\tR\ashelfId\x12\x1a\n" +
+	"\bcategory\x18\x02 \x01(\tR\bcategory\x12\x1f\n" +
+	"\vshelf_style\x18\x03 \x01(\tR\n" +
+	"shelfStyle\x121\n" +
+	"\x06levels\x18\x04 \x03(\v2\x19.peripheral_pb.ShelfLevelR\x06levels\"\x7f\n" +
+	"\tAreaClaim\x12\x16\n" +
+	"\x06holder\x18\x01 \x01(\tR\x06holder\x12\x12\n" +
+	"\x04kind\x18\x02 \x01(\tR\x04kind\x12\"\n" +
+	"\rclaimed_at_ms\x18\x03 \x01(\x03R\vclaimedAtMs\x12\"\n" +
+	"\rexpires_at_ms\x18\x04 \x01(\x03R\vexpiresAtMs\"\x85\x01\n" +
+	"\x04Area\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x1b\n" +
+	"\tarea_type\x18\x02 \x01(\tR\bareaType\x12\x1a\n" +
+	"\bcapacity\x18\x03 \x01(\x05R\bcapacity\x120\n" +
+	"\x06claims\x18\x04 \x03(\v2\x18.peripheral_pb.AreaClaimR\x06claims\"\xc1\x04\n" +
 	"\x10StackMapResponse\x12G\n" +
-	"\binfo_map\x18\x01 \x03(\v2,.peripheral_pb.StackMapResponse.InfoMapEntryR\ainfoMap\x1aP\n" +
+	"\binfo_map\x18\x01 \x03(\v2,.peripheral_pb.StackMapResponse.InfoMapEntryR\ainfoMap\x123\n" +
+	"\x06groups\x18\x02 \x03(\v2\x1b.peripheral_pb.GroupSummaryR\x06groups\x122\n" +
+	"\bmachines\x18\x03 \x03(\v2\x16.peripheral_pb.MachineR\bmachines\x12F\n" +
+	"\ashelves\x18\x04 \x03(\v2,.peripheral_pb.StackMapResponse.ShelvesEntryR\ashelves\x12@\n" +
+	"\x05areas\x18\x05 \x03(\v2*.peripheral_pb.StackMapResponse.AreasEntryR\x05areas\x1aP\n" +
 	"\fInfoMapEntry\x12\x10\n" +
 	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
-	"\x05value\x18\x02 \x01(\v2\x14.peripheral_pb.StackR\x05value:\x028\x01\"\a\n" +
+	"\x05value\x18\x02 \x01(\v2\x14.peripheral_pb.StackR\x05value:\x028\x01\x1aP\n" +
+	"\fShelvesEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12*\n" +
+	"\x05value\x18\x02 \x01(\v2\x14.peripheral_pb.ShelfR\x05value:\x028\x01\x1aM\n" +
+	"\n" +
+	"AreasEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12)\n" +
+	"\x05value\x18\x02 \x01(\v2\x13.peripheral_pb.AreaR\x05value:\x028\x01\"\a\n" +
 	"\x05Empty\"*\n" +
 	"\bLocation\x12\x1e\n" +
 	"\n" +
 	"locationid\x18\x01 \x01(\tR\n" +
-	"locationid2\xce\x01\n" +
+	"locationid\"s\n" +
+	"\x10FindCargoRequest\x12\x10\n" +
+	"\x02id\x18\x01 \x01(\tH\x00R\x02id\x12\x1d\n" +
+	"\tcustom_id\x18\x02 \x01(\tH\x00R\bcustomId\x12'\n" +
+	"\x0emetadata_value\x18\x03 \x01(\tH\x00R\rmetadataValueB\x05\n" +
+	"\x03key\"x\n" +
+	"\rCargoLocation\x12\x1f\n" +
+	"\vlocation_id\x18\x01 \x01(\tR\n" +
+	"locationId\x12\x1a\n" +
+	"\bposition\x18\x02 \x01(\x05R\bposition\x12*\n" +
+	"\x05cargo\x18\x03 \x01(\v2\x14.peripheral_pb.CargoR\x05cargo\"O\n" +
+	"\x11FindCargoResponse\x12:\n" +
+	"\tlocations\x18\x01 \x03(\v2\x1c.peripheral_pb.CargoLocationR\tlocations2\xce\x01\n" +
 	"\fStackService\x129\n" +
 	"\bAddStack\x12\x17.peripheral_pb.Location\x1a\x14.peripheral_pb.Empty\x12<\n" +
 	"\vDeleteStack\x12\x17.peripheral_pb.Location\x1a\x14.peripheral_pb.Empty\x12E\n" +
 	"\n" +
-	"PushStacks\x12\x1f.peripheral_pb.StackMapResponse\x1a\x14.peripheral_pb.Empty(\x01B Z\x1ekenmec/peripheral/protoGen;genb\x06proto3"
+	"PushStacks\x12\x1f.peripheral_pb.StackMapResponse\x1a\x14.peripheral_pb.Empty(\x012^\n" +
+	"\fCargoService\x12N\n" +
+	"\tFindCargo\x12\x1f.peripheral_pb.FindCargoRequest\x1a .peripheral_pb.FindCargoResponseB Z\x1ekenmec/peripheral/protoGen;genb\x06proto3"
 
 var (
 	file_stack_proto_rawDescOnce sync.Once
@@ -329,30 +1261,56 @@ func file_stack_proto_rawDescGZIP() []byte {
 	return file_stack_proto_rawDescData
 }
 
-var file_stack_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
+var file_stack_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
 var file_stack_proto_goTypes = []any{
-	(*Cargo)(nil),            // 0: peripheral_pb.Cargo
-	(*Stack)(nil),            // 1: peripheral_pb.Stack
-	(*StackMapResponse)(nil), // 2: peripheral_pb.StackMapResponse
-	(*Empty)(nil),            // 3: peripheral_pb.Empty
-	(*Location)(nil),         // 4: peripheral_pb.Location
-	nil,                      // 5: peripheral_pb.StackMapResponse.InfoMapEntry
+	(*Cargo)(nil),             // 0: peripheral_pb.Cargo
+	(*Stack)(nil),             // 1: peripheral_pb.Stack
+	(*GroupSummary)(nil),      // 2: peripheral_pb.GroupSummary
+	(*Machine)(nil),           // 3: peripheral_pb.Machine
+	(*ShelfLevel)(nil),        // 4: peripheral_pb.ShelfLevel
+	(*Shelf)(nil),             // 5: peripheral_pb.Shelf
+	(*AreaClaim)(nil),         // 6: peripheral_pb.AreaClaim
+	(*Area)(nil),              // 7: peripheral_pb.Area
+	(*StackMapResponse)(nil),  // 8: peripheral_pb.StackMapResponse
+	(*Empty)(nil),             // 9: peripheral_pb.Empty
+	(*Location)(nil),          // 10: peripheral_pb.Location
+	(*FindCargoRequest)(nil),  // 11: peripheral_pb.FindCargoRequest
+	(*CargoLocation)(nil),     // 12: peripheral_pb.CargoLocation
+	(*FindCargoResponse)(nil), // 13: peripheral_pb.FindCargoResponse
+	nil,                       // 14: peripheral_pb.StackMapResponse.InfoMapEntry
+	nil,                       // 15: peripheral_pb.StackMapResponse.ShelvesEntry
+	nil,                       // 16: peripheral_pb.StackMapResponse.AreasEntry
 }
 var file_stack_proto_depIdxs = []int32{
-	0, // 0: peripheral_pb.Stack.cargo:type_name -> peripheral_pb.Cargo
-	5, // 1: peripheral_pb.StackMapResponse.info_map:type_name -> peripheral_pb.StackMapResponse.InfoMapEntry
-	1, // 2: peripheral_pb.StackMapResponse.InfoMapEntry.value:type_name -> peripheral_pb.Stack
-	4, // 3: peripheral_pb.StackService.AddStack:input_type -> peripheral_pb.Location
-	4, // 4: peripheral_pb.StackService.DeleteStack:input_type -> peripheral_pb.Location
-	2, // 5: peripheral_pb.StackService.PushStacks:input_type -> peripheral_pb.StackMapResponse
-	3, // 6: peripheral_pb.StackService.AddStack:output_type -> peripheral_pb.Empty
-	3, // 7: peripheral_pb.StackService.DeleteStack:output_type -> peripheral_pb.Empty
-	3, // 8: peripheral_pb.StackService.PushStacks:output_type -> peripheral_pb.Empty
-	6, // [6:9] is the sub-list for method output_type
-	3, // [3:6] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	0,  // 0: peripheral_pb.Stack.cargo:type_name -> peripheral_pb.Cargo
+	0,  // 1: peripheral_pb.Machine.holding:type_name -> peripheral_pb.Cargo
+	0,  // 2: peripheral_pb.Machine.cargo:type_name -> peripheral_pb.Cargo
+	0,  // 3: peripheral_pb.ShelfLevel.cargo:type_name -> peripheral_pb.Cargo
+	4,  // 4: peripheral_pb.Shelf.levels:type_name -> peripheral_pb.ShelfLevel
+	6,  // 5: peripheral_pb.Area.claims:type_name -> peripheral_pb.AreaClaim
+	14, // 6: peripheral_pb.StackMapResponse.info_map:type_name -> peripheral_pb.StackMapResponse.InfoMapEntry
+	2,  // 7: peripheral_pb.StackMapResponse.groups:type_name -> peripheral_pb.GroupSummary
+	3,  // 8: peripheral_pb.StackMapResponse.machines:type_name -> peripheral_pb.Machine
+	15, // 9: peripheral_pb.StackMapResponse.shelves:type_name -> peripheral_pb.StackMapResponse.ShelvesEntry
+	16, // 10: peripheral_pb.StackMapResponse.areas:type_name -> peripheral_pb.StackMapResponse.AreasEntry
+	0,  // 11: peripheral_pb.CargoLocation.cargo:type_name -> peripheral_pb.Cargo
+	12, // 12: peripheral_pb.FindCargoResponse.locations:type_name -> peripheral_pb.CargoLocation
+	1,  // 13: peripheral_pb.StackMapResponse.InfoMapEntry.value:type_name -> peripheral_pb.Stack
+	5,  // 14: peripheral_pb.StackMapResponse.ShelvesEntry.value:type_name -> peripheral_pb.Shelf
+	7,  // 15: peripheral_pb.StackMapResponse.AreasEntry.value:type_name -> peripheral_pb.Area
+	10, // 16: peripheral_pb.StackService.AddStack:input_type -> peripheral_pb.Location
+	10, // 17: peripheral_pb.StackService.DeleteStack:input_type -> peripheral_pb.Location
+	8,  // 18: peripheral_pb.StackService.PushStacks:input_type -> peripheral_pb.StackMapResponse
+	11, // 19: peripheral_pb.CargoService.FindCargo:input_type -> peripheral_pb.FindCargoRequest
+	9,  // 20: peripheral_pb.StackService.AddStack:output_type -> peripheral_pb.Empty
+	9,  // 21: peripheral_pb.StackService.DeleteStack:output_type -> peripheral_pb.Empty
+	9,  // 22: peripheral_pb.StackService.PushStacks:output_type -> peripheral_pb.Empty
+	13, // 23: peripheral_pb.CargoService.FindCargo:output_type -> peripheral_pb.FindCargoResponse
+	20, // [20:24] is the sub-list for method output_type
+	16, // [16:20] is the sub-list for method input_type
+	16, // [16:16] is the sub-list for extension type_name
+	16, // [16:16] is the sub-list for extension extendee
+	0,  // [0:16] is the sub-list for field type_name
 }
 
 func init() { file_stack_proto_init() }
@@ -360,15 +1318,20 @@ func file_stack_proto_init() {
 	if File_stack_proto != nil {
 		return
 	}
+	file_stack_proto_msgTypes[11].OneofWrappers = []any{
+		(*FindCargoRequest_Id)(nil),
+		(*FindCargoRequest_CustomId)(nil),
+		(*FindCargoRequest_MetadataValue)(nil),
+	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
 		File: protoimpl.DescBuilder{
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_stack_proto_rawDesc), len(file_stack_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   6,
+			NumMessages:   17,
 			NumExtensions: 0,
-			NumServices:   1,
+			NumServices:   2,
 		},
 		GoTypes:           file_stack_proto_goTypes,
 		DependencyIndexes: file_stack_proto_depIdxs,
@@ -199,3 +199,109 @@ var StackService_ServiceDesc = grpc.ServiceDesc{
 	},
 	Metadata: "stack.proto",
 }
+
+const (
+	CargoService_FindCargo_FullMethodName = "/peripheral_pb.CargoService/FindCargo"
+)
+
+// CargoServiceClient is the client API for CargoService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// 本服務提供的查詢 (gRPC server)
+type CargoServiceClient interface {
+	FindCargo(ctx context.Context, in *FindCargoRequest, opts ...grpc.CallOption) (*FindCargoResponse, error)
+}
+
+type cargoServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewCargoServiceClient(cc grpc.ClientConnInterface) CargoServiceClient {
+	return &cargoServiceClient{cc}
+}
+
+func (c *cargoServiceClient) FindCargo(ctx context.Context, in *FindCargoRequest, opts ...grpc.CallOption) (*FindCargoResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(FindCargoResponse)
+	err := c.cc.Invoke(ctx, CargoService_FindCargo_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// CargoServiceServer is the server API for CargoService service.
+// All implementations must embed UnimplementedCargoServiceServer
+// for forward compatibility.
+//
+// 本服務提供的查詢 (gRPC server)
+type CargoServiceServer interface {
+	FindCargo(context.Context, *FindCargoRequest) (*FindCargoResponse, error)
+	mustEmbedUnimplementedCargoServiceServer()
+}
+
+// UnimplementedCargoServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedCargoServiceServer struct{}
+
+func (UnimplementedCargoServiceServer) FindCargo(context.Context, *FindCargoRequest) (*FindCargoResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method FindCargo not implemented")
+}
+func (UnimplementedCargoServiceServer) mustEmbedUnimplementedCargoServiceServer() {}
+func (UnimplementedCargoServiceServer) testEmbeddedByValue()                      {}
+
+// UnsafeCargoServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to CargoServiceServer will
+// result in compilation errors.
+type UnsafeCargoServiceServer interface {
+	mustEmbedUnimplementedCargoServiceServer()
+}
+
+func RegisterCargoServiceServer(s grpc.ServiceRegistrar, srv CargoServiceServer) {
+	// If the following call panics, it indicates UnimplementedCargoServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&CargoService_ServiceDesc, srv)
+}
+
+func _CargoService_FindCargo_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindCargoRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CargoServiceServer).FindCargo(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: CargoService_FindCargo_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CargoServiceServer).FindCargo(ctx, req.(*FindCargoRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// CargoService_ServiceDesc is the grpc.ServiceDesc for CargoService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var CargoService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "peripheral_pb.CargoService",
+	HandlerType: (*CargoServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "FindCargo",
+			Handler:    _CargoService_FindCargo_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "stack.proto",
+}
@@ -26,8 +26,15 @@ SELECT
     -- cargo_info.metadata as cargo_metadata,
     -- cargo_info.custom_id as cargo_custom_id,
     -- cargo_info.custom_cargo_metadata_id as custom_cargo_metadata_id,
+    peripheral_name.id as peripheral_id,
     peripheral_name.name as peripheral_name,
-    peripheral_name.description as peripheral_desc
+    peripheral_name.description as peripheral_desc,
+    peripheral_name.group_id as group_id,
+    peripheral_name.load_priority,
+    peripheral_name.offload_priority,
+    loc.x,
+    loc.y,
+    loc.placement_priority
 FROM mission_script ms
  JOIN Loc loc ON ms.id = loc.mission_script_id
  JOIN mock_wcs_station mws ON loc.id = mws.sourceId
@@ -42,10 +49,31 @@ SELECT
     id as cargo_id,
     status as cargo_status,
     metadata as cargo_metadata,
-    custom_id as cargo_custom_id
+    custom_id as cargo_custom_id,
+    custom_cargo_metadata_id,
+    updatedAt as cargo_updated_at
 FROM cargo_info 
 -- sqlc 支援傳入 slice: WHERE stack_config_id IN (?)
-WHERE stack_config_id IN (sqlc.slice('stackIds'));
+-- 已經被取走或移出場域的貨物不算在堆疊上
+WHERE stack_config_id IN (sqlc.slice('stackIds')) AND status = 'AT_LOCATION';
+
+-- name: MoveCargo :execresult
+UPDATE cargo_info
+SET stack_config_id = sqlc.arg(to_stack_id), updatedAt = CURRENT_TIMESTAMP(3)
+WHERE id = sqlc.arg(cargo_id) AND stack_config_id = sqlc.arg(from_stack_id);
+
+-- name: InsertCargoHistory :exec
+INSERT INTO cargo_history (id, cargo_id, action, description, actor)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: ListCustomCargoMetadata :many
+SELECT id, is_default, custom_name, format, unique_key
+FROM custom_cargo_metadata;
+
+-- name: ListPeripheralGroups :many
+SELECT id, name, description, mission_script_id
+FROM peripheral_group
+WHERE mission_script_id = ?;
 
 
 -- name: OneStack :one
@@ -61,12 +89,167 @@ SELECT
     -- cargo_info.metadata as cargo_metadata,
     -- cargo_info.custom_id as cargo_custom_id,
     -- cargo_info.custom_cargo_metadata_id as custom_cargo_metadata_id,
+    peripheral_name.id as peripheral_id,
     peripheral_name.name as peripheral_name,
-    peripheral_name.description as peripheral_desc
+    peripheral_name.description as peripheral_desc,
+    peripheral_name.group_id as group_id,
+    peripheral_name.load_priority,
+    peripheral_name.offload_priority,
+    loc.x,
+    loc.y,
+    loc.placement_priority
 FROM mission_script ms
  JOIN Loc loc ON ms.id = loc.mission_script_id
  JOIN mock_wcs_station mws ON loc.id = mws.sourceId
  JOIN stack_config stack ON mws.stack_id = stack.id
 --  JOIN cargo_info ON stack.id = cargo_info.stack_config_id
  JOIN peripheral_name ON stack.name = peripheral_name.id
- WHERE ms.id = ? AND loc.locationId = ?;
\ No newline at end of file
+ WHERE ms.id = ? AND loc.locationId = ?;
+-- name: AllConveyor :many
+SELECT
+    loc.locationId AS locationId,
+    cc.id AS conveyor_id,
+    cc.disable AS conveyor_disable,
+    cc.hasCargo AS has_cargo,
+    cc.is_spawn_cargo,
+    cc.active_shift,
+    peripheral_name.id as peripheral_id,
+    peripheral_name.name as peripheral_name,
+    peripheral_name.description as peripheral_desc
+FROM Loc loc
+ JOIN mock_wcs_station mws ON loc.id = mws.sourceId
+ JOIN conveyor_config cc ON mws.conveyor_id = cc.id
+ JOIN peripheral_name ON cc.name = peripheral_name.id
+ WHERE loc.mission_script_id = ?;
+
+-- name: GetCargoInfo :one
+SELECT id, status, metadata, custom_id, custom_cargo_metadata_id
+FROM cargo_info
+WHERE id = ?;
+
+-- name: GetCargoStack :one
+SELECT stack_config_id
+FROM cargo_info
+WHERE id = ?;
+
+-- name: ListTimelines :many
+SELECT
+    t.id,
+    t.timestamp,
+    t.event_type,
+    t.type,
+    t.shift_peripheral_id,
+    t.simulation_result_id,
+    tsc.peripheral_name_id AS spawn_peripheral_id,
+    tsc.spawn_cargo_info_id,
+    spg.peripheral_group_id AS spawn_group_id,
+    spg.range AS spawn_range,
+    spg.active_interval AS spawn_interval,
+    spg.is_spawn_all,
+    spg.spawn_number,
+    spg.spawn_cargo_info_id AS group_spawn_cargo_info_id,
+    shg.peripheral_group_id AS shift_group_id,
+    shg.range AS shift_range,
+    shg.active_interval AS shift_interval,
+    shg.is_shift_all,
+    shg.shift_number
+FROM timeline t
+ LEFT JOIN timeline_spawn_cargo tsc ON tsc.timeline_id = t.id
+ LEFT JOIN spawn_cargo_peripheral_group_bridge spg ON spg.timeline_id = t.id
+ LEFT JOIN shift_cargo_peripheral_group_bridge shg ON shg.timeline_id = t.id
+ WHERE t.script_id = ? AND t.is_enable = 1
+ ORDER BY t.style_row, t.id;
+
+-- name: UpsertSimulationResult :exec
+INSERT INTO simulation_result (
+    id, amr_stat, startTime, endTime, total_cargos_carried,
+    mission_success_rate, total_mission_count, completed_missions
+) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+ON DUPLICATE KEY UPDATE
+    startTime = VALUES(startTime),
+    endTime = VALUES(endTime),
+    total_cargos_carried = VALUES(total_cargos_carried),
+    total_mission_count = VALUES(total_mission_count),
+    completed_missions = VALUES(completed_missions);
+
+-- name: ListMockWcsStations :many
+SELECT
+    mws.id,
+    mws.machine_type,
+    mws.delay_ms,
+    mws.is_enabled,
+    loc.locationId AS locationId
+FROM mock_wcs_station mws
+ JOIN Loc loc ON loc.id = mws.sourceId
+ WHERE loc.mission_script_id = ?;
+
+-- name: ListMachines :many
+SELECT
+    loc.locationId AS locationId,
+    loc.name,
+    loc.areaType,
+    dir.yaw
+FROM Loc loc
+ LEFT JOIN Direction dir ON loc.dirId = dir.id
+ WHERE loc.mission_script_id = ?
+   AND loc.areaType IN ('ROBOTIC_ARM', 'PALLETIZER', 'ROTATE_TABLE');
+
+-- name: ListShelfLevels :many
+SELECT
+    loc.locationId AS locationId,
+    shelf.id AS shelf_id,
+    cat.name AS category_name,
+    cat.shelf_style,
+    sc.id AS shelf_config_id,
+    sc.level,
+    sc.hasCargo AS has_cargo,
+    sc.disable AS shelf_disable,
+    sc.cargo_limit,
+    sh.height,
+    peripheral_name.id as peripheral_id,
+    peripheral_name.name as peripheral_name,
+    peripheral_name.description as peripheral_desc
+FROM Loc loc
+ JOIN Shelf shelf ON loc.shelfId = shelf.id
+ LEFT JOIN ShelfCategory cat ON shelf.shelfCategoryId = cat.id
+ JOIN ShelfConfig sc ON sc.shelfId = shelf.id
+ JOIN ShelfHeight sh ON sc.shelfHeightId = sh.id
+ JOIN peripheral_name ON sc.name = peripheral_name.id
+ WHERE loc.mission_script_id = ?
+ ORDER BY loc.locationId, sc.level;
+
+-- name: ListCargosByShelfConfigIds :many
+SELECT
+    shelfConfigId,
+    id as cargo_id,
+    status as cargo_status,
+    metadata as cargo_metadata,
+    custom_id as cargo_custom_id,
+    custom_cargo_metadata_id,
+    updatedAt as cargo_updated_at
+FROM cargo_info
+WHERE shelfConfigId IN (sqlc.slice('shelfConfigIds')) AND status = 'AT_LOCATION';
+
+-- name: ListAreas :many
+-- 一般位置沒有 config 表，peripheral_name 以同名同類型對應
+SELECT
+    loc.locationId AS locationId,
+    loc.name,
+    loc.areaType,
+    pn.quantity
+FROM Loc loc
+ LEFT JOIN peripheral_name pn ON pn.name = loc.name AND pn.type = loc.areaType
+ WHERE loc.mission_script_id = ?
+   AND loc.areaType IN ('STORAGE', 'DISPATCH', 'STANDBY', 'EXTRA');
+
+-- name: UpdateCargoLifecycle :execresult
+-- 只有狀態還是 from 時才更新，避免蓋掉資料庫裡不同的狀態
+UPDATE cargo_info
+SET status = sqlc.arg(status), owner = sqlc.arg(owner), stack_config_id = sqlc.arg(stack_config_id),
+    shelfConfigId = sqlc.arg(shelf_config_id), updatedAt = CURRENT_TIMESTAMP(3)
+WHERE id = sqlc.arg(id) AND status = sqlc.arg(from_status);
+
+-- name: UpdateShelfHasCargo :exec
+UPDATE ShelfConfig
+SET hasCargo = sqlc.arg(has_cargo)
+WHERE id = sqlc.arg(id);
@@ -0,0 +1,623 @@
+package timeline
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/cargo"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"kenmec/peripheral/jimmy/wcs"
+	"sync"
+	"time"
+)
+
+// EventFired 在每次觸發 timeline 後同步發出，內容是 Fired，handler 不可阻塞
+const EventFired = "timeline.fired"
+
+// EventState 在時間軸開始、暫停、繼續、停止時同步發出，內容是 StateChanged。
+// handler 在持有 Engine 的鎖時被呼叫，不可再呼叫 Engine 的方法，也不可阻塞
+const EventState = "timeline.state"
+
+var (
+	ErrAlreadyRunning = errors.New("timeline already running")
+	ErrNotRunning     = errors.New("timeline is not running")
+	ErrNotPaused      = errors.New("timeline is not paused")
+)
+
+// State 是時間軸的狀態
+type State string
+
+const (
+	StateIdle    State = "idle"
+	StateRunning State = "running"
+	StatePaused  State = "paused"
+	StateStopped State = "stopped"
+)
+
+// Target 是時間軸能生成與移走貨物的週邊，不認得 peripheralID 時回傳 peripheral.ErrPeripheralNotFound
+type Target interface {
+	LocationOf(peripheralID string) (string, error)
+	SpawnCargo(ctx context.Context, peripheralID string, c peripheral.CargoData) error
+	ShiftCargo(ctx context.Context, peripheralID string) (peripheral.CargoData, error)
+}
+
+// Fired 是 EventFired 的內容
+type Fired struct {
+	TimelineID         string
+	Type               db.TimelineType
+	SimulationResultID string
+	PeripheralID       string        // 指定週邊的 timeline 才有
+	GroupID            string        // *_GROUP 才有
+	Elapsed            time.Duration // 觸發時時間軸的進度
+	Cargo              []string      // 這次生成或移走的貨物 ID
+	Err                string
+	Timestamp          time.Time
+}
+
+// StateChanged 是 EventState 的內容
+type StateChanged struct {
+	State    State
+	ScriptID string
+	// SimulationResultID 是這次執行所有 timeline 共用的 simulation_result_id，不一致或沒有設定時為空
+	SimulationResultID string
+	StartedAt          time.Time
+	Elapsed            time.Duration
+	Timestamp          time.Time
+}
+
+// Status 是時間軸目前的狀態
+type Status struct {
+	State     State
+	ScriptID  string
+	StartedAt time.Time
+	Elapsed   time.Duration
+	Entries   int
+	Pending   int // 還會再觸發的 timeline 數
+	Fired     int // 已觸發的次數
+}
+
+// schedule 是一筆 timeline 的執行進度
+type schedule struct {
+	Entry
+	next  time.Duration
+	fired int
+	done  bool
+}
+
+// Engine 依 timeline.timestamp 觸發生成、移走貨物與任務事件。
+// 觸發都在 Run 的 goroutine 裡執行，Start/Pause/Resume/Stop 只改狀態並叫醒它
+type Engine struct {
+	db      *db.Queries
+	stacks  *peripheral.YFYStackManager
+	targets []Target
+	// stations 讓生成與移走經過 mock WCS 站點，nil 表示直接執行
+	stations *wcs.Mock
+	// requests 有站點的 handler 時指令經過 request bus 送到站點，nil 表示直接呼叫 stations
+	requests *infra.RequestResponseBus
+	bus      *infra.EventBus
+	logger   infra.Logger
+	clock    infra.Clock
+
+	mu        sync.Mutex
+	state     State
+	scriptID  string
+	resultID  string
+	schedules []*schedule
+	fired     int
+	startedAt time.Time
+	pausedAt  time.Time
+	paused    time.Duration // 暫停的總時間，不算進進度
+
+	wake chan struct{}
+}
+
+// New 建立時間軸，group 類型的 timeline 用 stacks 挑選堆疊，
+// 指定週邊的 timeline 依序交給 stacks 與 conveyors
+func New(conn *sql.DB, stacks *peripheral.YFYStackManager, conveyors *peripheral.ConveyorManager, bus *infra.EventBus, logger infra.Logger) *Engine {
+	e := &Engine{
+		stacks:  stacks,
+		targets: []Target{stacks},
+		bus:     bus,
+		logger:  logger.With(infra.FieldComponent, "timeline"),
+		clock:   infra.NewRealClock(),
+		state:   StateIdle,
+		wake:    make(chan struct{}, 1),
+	}
+	if conveyors != nil {
+		e.targets = append(e.targets, conveyors)
+	}
+	if conn != nil {
+		e.db = db.New(conn)
+	}
+	return e
+}
+
+// SetStations 讓生成與移走經過 mock WCS，套用站點的延遲、停用與注入的故障。
+// 站點的延遲在 Run 裡等待，會延後之後觸發的 timeline
+func (e *Engine) SetStations(m *wcs.Mock) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.stations = m
+}
+
+// SetRequests 讓站點指令經過 rb (wcs.TopicCommand)，卡住的站點在 rb 的逾時後失敗，不會讓 Run 一直等下去
+func (e *Engine) SetRequests(rb *infra.RequestResponseBus) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.requests = rb
+}
+
+// command 以 locID 的站點執行 fn，沒有設定 mock WCS 時直接執行
+func (e *Engine) command(ctx context.Context, locID string, fn func(ctx context.Context) error) error {
+	e.mu.Lock()
+	stations, requests := e.stations, e.requests
+	e.mu.Unlock()
+
+	if requests != nil && requests.HasHandler(wcs.TopicCommand) {
+		// 逾時後取消，還在等待的站點就不會在回報失敗之後才執行 fn
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		res, err := requests.RequestWithContext(ctx, wcs.TopicCommand, wcs.Command{LocationID: locID, Run: fn})
+		if err != nil {
+			return err
+		}
+		return res.Error
+	}
+	if stations == nil {
+		return fn(ctx)
+	}
+	return stations.Do(ctx, locID, fn)
+}
+
+// SetClock 替換時間來源，用 scaled clock 加速模擬或用 manual clock 逐步執行。
+// 只能在沒有執行時替換，否則進度會錯亂
+func (e *Engine) SetClock(c infra.Clock) error {
+	if c == nil {
+		c = infra.NewRealClock()
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state == StateRunning || e.state == StatePaused {
+		return ErrAlreadyRunning
+	}
+	e.clock = c
+	return nil
+}
+
+func (e *Engine) notify() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// elapsed 是扣掉暫停時間的進度，呼叫前必須持有 mu
+func (e *Engine) elapsed(now time.Time) time.Duration {
+	switch e.state {
+	case StateRunning:
+		return now.Sub(e.startedAt) - e.paused
+	case StatePaused, StateStopped:
+		return e.pausedAt.Sub(e.startedAt) - e.paused
+	}
+	return 0
+}
+
+// Start 讀取目前腳本啟用的 timeline 並從 0 開始執行。
+// 時間格式錯誤的 timeline 會被略過並記 log
+func (e *Engine) Start(ctx context.Context) error {
+	if e.db == nil {
+		return errors.New("timeline needs a database connection")
+	}
+
+	scriptID := e.stacks.ScriptID()
+	entries, err := loadEntries(ctx, e.db, scriptID)
+	if entries == nil && err != nil {
+		return err
+	}
+	if err != nil {
+		e.logger.Warn("%v", err)
+	}
+	return e.start(scriptID, entries)
+}
+
+// start 從 0 開始執行 entries
+func (e *Engine) start(scriptID string, entries []Entry) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state == StateRunning || e.state == StatePaused {
+		return ErrAlreadyRunning
+	}
+
+	e.schedules = make([]*schedule, 0, len(entries))
+	for _, en := range entries {
+		e.schedules = append(e.schedules, &schedule{Entry: en, next: en.At})
+	}
+	e.scriptID = scriptID
+	e.resultID = commonResultID(entries)
+	e.fired = 0
+	e.paused = 0
+	e.startedAt = e.clock.Now()
+	e.state = StateRunning
+
+	e.logger.With(infra.FieldScriptID, scriptID).Info("timeline started with %d entries", len(entries))
+	e.publishState()
+	e.notify()
+	return nil
+}
+
+// commonResultID 回傳所有 timeline 共用的 simulation_result_id，不一致時回傳空字串
+func commonResultID(entries []Entry) string {
+	id := ""
+	for _, en := range entries {
+		switch {
+		case en.SimulationResultID == "":
+		case id == "":
+			id = en.SimulationResultID
+		case id != en.SimulationResultID:
+			return ""
+		}
+	}
+	return id
+}
+
+// publishState 發出 EventState，呼叫前必須持有 mu
+func (e *Engine) publishState() {
+	if e.bus == nil {
+		return
+	}
+
+	now := e.clock.Now()
+	e.bus.PublishSync(EventState, StateChanged{
+		State:              e.state,
+		ScriptID:           e.scriptID,
+		SimulationResultID: e.resultID,
+		StartedAt:          e.startedAt,
+		Elapsed:            e.elapsed(now),
+		Timestamp:          now,
+	})
+}
+
+// Pause 暫停時間軸，暫停期間不計入進度
+func (e *Engine) Pause() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state != StateRunning {
+		return ErrNotRunning
+	}
+	e.pausedAt = e.clock.Now()
+	e.state = StatePaused
+	e.publishState()
+	e.notify()
+	return nil
+}
+
+// Resume 從暫停的進度繼續
+func (e *Engine) Resume() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state != StatePaused {
+		return ErrNotPaused
+	}
+	e.paused += e.clock.Since(e.pausedAt)
+	e.state = StateRunning
+	e.publishState()
+	e.notify()
+	return nil
+}
+
+// Stop 停止時間軸，之後要重新 Start 才會從頭開始
+func (e *Engine) Stop() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch e.state {
+	case StateRunning:
+		e.pausedAt = e.clock.Now()
+	case StatePaused:
+	default:
+		return ErrNotRunning
+	}
+	e.state = StateStopped
+	e.publishState()
+	e.notify()
+	return nil
+}
+
+func (e *Engine) Status() Status {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	st := Status{
+		State:     e.state,
+		ScriptID:  e.scriptID,
+		StartedAt: e.startedAt,
+		Elapsed:   e.elapsed(e.clock.Now()),
+		Entries:   len(e.schedules),
+		Fired:     e.fired,
+	}
+	for _, s := range e.schedules {
+		if !s.done {
+			st.Pending++
+		}
+	}
+	return st
+}
+
+// nextTimer 設好到下一次觸發的計時器，沒有在執行或沒有要觸發的回傳 nil
+func (e *Engine) nextTimer() infra.Timer {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state != StateRunning {
+		return nil
+	}
+
+	var (
+		next  time.Duration
+		found bool
+	)
+	for _, s := range e.schedules {
+		if !s.done && (!found || s.next < next) {
+			next, found = s.next, true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return e.clock.NewTimer(max(next-e.elapsed(e.clock.Now()), 0))
+}
+
+// due 取出已經到時間的 timeline 並排好下一次觸發，呼叫端在鎖外執行
+func (e *Engine) due() ([]Entry, time.Duration) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.state != StateRunning {
+		return nil, 0
+	}
+
+	now := e.elapsed(e.clock.Now())
+	var out []Entry
+
+	for _, s := range e.schedules {
+		if s.done || s.next > now {
+			continue
+		}
+
+		out = append(out, s.Entry)
+		s.fired++
+		e.fired++
+
+		if s.Interval <= 0 {
+			s.done = true
+			continue
+		}
+		// 落後好幾個間隔時只補觸發一次
+		for s.next <= now {
+			s.next += s.Interval
+		}
+		if s.Until > 0 && s.next > s.Until {
+			s.done = true
+		}
+	}
+	return out, now
+}
+
+// Run 執行到 ctx 取消為止
+func (e *Engine) Run(ctx context.Context) error {
+	ctx = infra.WithActor(ctx, "timeline")
+
+	for {
+		var fire <-chan time.Time
+		timer := e.nextTimer()
+		if timer != nil {
+			fire = timer.C()
+		}
+
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		case <-e.wake:
+		case <-fire:
+			entries, elapsed := e.due()
+			for _, en := range entries {
+				e.fire(ctx, en, elapsed)
+			}
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+func (e *Engine) fire(ctx context.Context, en Entry, elapsed time.Duration) {
+	var (
+		cargo []string
+		err   error
+	)
+
+	switch en.Type {
+	case db.TimelineTypeSPAWNCARGO:
+		c := newCargo(en.Template)
+		if err = e.spawn(ctx, en.PeripheralID, c); err == nil {
+			cargo = []string{c.ID}
+		}
+	case db.TimelineTypeSHIFTCARGO:
+		var c peripheral.CargoData
+		if c, err = e.shift(ctx, en.PeripheralID); err == nil {
+			cargo = []string{c.ID}
+		}
+	case db.TimelineTypeSPAWNCARGOGROUP:
+		cargo, err = e.spawnGroup(ctx, en)
+	case db.TimelineTypeSHIFTCARGOGROUP:
+		cargo, err = e.shiftGroup(ctx, en)
+	case db.TimelineTypeMISSION:
+		// 任務由派車端執行，這裡只發出事件
+	default:
+		err = fmt.Errorf("unknown timeline type %q", en.Type)
+	}
+
+	log := e.logger.With(infra.FieldTimelineID, en.ID)
+	ev := Fired{
+		TimelineID:         en.ID,
+		Type:               en.Type,
+		SimulationResultID: en.SimulationResultID,
+		PeripheralID:       en.PeripheralID,
+		GroupID:            en.GroupID,
+		Elapsed:            elapsed,
+		Cargo:              cargo,
+		Timestamp:          e.now(),
+	}
+	if err != nil {
+		ev.Err = err.Error()
+		log.Warn("%s at %s failed: %v", en.Type, elapsed, err)
+	} else {
+		log.Debug("%s at %s: %d cargo", en.Type, elapsed, len(cargo))
+	}
+
+	if e.bus != nil {
+		e.bus.PublishSync(EventFired, ev)
+	}
+}
+
+func (e *Engine) now() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.clock.Now()
+}
+
+// newCargo 依範本產生新貨物，ID 每次都不同，custom ID 不沿用以免重複
+func newCargo(template *peripheral.CargoData) peripheral.CargoData {
+	c := peripheral.CargoData{
+		ID:     "SPAWN-" + rand.Text(),
+		Status: string(cargo.StatusPreSpawn),
+	}
+	if template != nil {
+		c.Metadata = template.Metadata
+		c.MetadataID = template.MetadataID
+	}
+	return c
+}
+
+// target 找出 peripheralID 所在的週邊與位置
+func (e *Engine) target(peripheralID string) (Target, string, error) {
+	for _, t := range e.targets {
+		locID, err := t.LocationOf(peripheralID)
+		if errors.Is(err, peripheral.ErrPeripheralNotFound) {
+			continue
+		}
+		return t, locID, err
+	}
+	return nil, "", fmt.Errorf("%w: %s", peripheral.ErrPeripheralNotFound, peripheralID)
+}
+
+func (e *Engine) spawn(ctx context.Context, peripheralID string, c peripheral.CargoData) error {
+	t, locID, err := e.target(peripheralID)
+	if err != nil {
+		return err
+	}
+	return e.command(ctx, locID, func(ctx context.Context) error {
+		return t.SpawnCargo(ctx, peripheralID, c)
+	})
+}
+
+func (e *Engine) shift(ctx context.Context, peripheralID string) (peripheral.CargoData, error) {
+	t, locID, err := e.target(peripheralID)
+	if err != nil {
+		return peripheral.CargoData{}, err
+	}
+
+	var c peripheral.CargoData
+	err = e.command(ctx, locID, func(ctx context.Context) (err error) {
+		c, err = t.ShiftCargo(ctx, peripheralID)
+		return err
+	})
+	return c, err
+}
+
+// spawnGroup 依放貨策略把貨物放進群組，All 時放到沒有空位為止
+func (e *Engine) spawnGroup(ctx context.Context, en Entry) ([]string, error) {
+	n := en.Count
+	if en.All {
+		sum, err := e.stacks.GroupSummary(en.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		n = sum.FreeSlots
+	}
+
+	var spawned []string
+	for range n {
+		c := newCargo(en.Template)
+		chosen, err := e.stacks.SelectStack(ctx, peripheral.PlacementRequest{
+			GroupID: en.GroupID,
+			Op:      peripheral.OpLoad,
+			Cargo:   c,
+		})
+		if err != nil {
+			return spawned, err
+		}
+		err = e.command(ctx, chosen.LocationID, func(ctx context.Context) error {
+			return e.stacks.PushCargo(ctx, chosen.LocationID, c)
+		})
+		if err != nil {
+			return spawned, err
+		}
+		spawned = append(spawned, c.ID)
+	}
+	return spawned, nil
+}
+
+// shiftGroup 從群組移走最早放上去的貨物，All 時清空整個群組
+func (e *Engine) shiftGroup(ctx context.Context, en Entry) ([]string, error) {
+	n := en.Count
+	if en.All {
+		sum, err := e.stacks.GroupSummary(en.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		n = sum.CargoCount
+	}
+
+	var shifted []string
+	for range n {
+		chosen, err := e.stacks.SelectStack(ctx, peripheral.PlacementRequest{
+			GroupID:  en.GroupID,
+			Op:       peripheral.OpOffload,
+			Strategy: peripheral.StrategyFIFO,
+		})
+		if errors.Is(err, peripheral.ErrNoStackAvailable) && en.All {
+			// 停用或被預約的堆疊上的貨物不動
+			break
+		}
+		if err != nil {
+			return shifted, err
+		}
+		var c peripheral.CargoData
+		err = e.command(ctx, chosen.LocationID, func(ctx context.Context) (err error) {
+			c, err = e.stacks.ShiftCargoAt(ctx, chosen.LocationID)
+			return err
+		})
+		if err != nil {
+			return shifted, err
+		}
+		shifted = append(shifted, c.ID)
+	}
+	return shifted, nil
+}
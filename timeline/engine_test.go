@@ -0,0 +1,274 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"kenmec/peripheral/jimmy/wcs"
+)
+
+// waitWaiters 等 Run 設好 (或取消) 計時器，避免在它之前前進時鐘
+func waitWaiters(t *testing.T, clock *infra.ManualClock, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for clock.Waiters() != n {
+		if time.Now().After(deadline) {
+			t.Fatalf("clock has %d waiters, want %d", clock.Waiters(), n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// TestEngineManualClock 用 manual clock 逐步執行：重複觸發、重複到期結束、暫停時間不算進度
+func TestEngineManualClock(t *testing.T) {
+	logger, err := infra.NewSlogLogger(io.Discard, "error", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := infra.NewManualClock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	bus := infra.New()
+
+	firedCh := make(chan Fired, 16)
+	bus.Subscribe(EventFired, func(data interface{}) { firedCh <- data.(Fired) })
+
+	e := New(nil, nil, nil, bus, logger)
+	if err := e.SetClock(clock); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// MISSION 只發出事件，不需要堆疊
+	err = e.start("script-1", []Entry{
+		{ID: "every-10s", Type: db.TimelineTypeMISSION, At: 10 * time.Second, Interval: 10 * time.Second, Until: 30 * time.Second},
+		{ID: "once", Type: db.TimelineTypeMISSION, At: 15 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := func(id string, elapsed time.Duration) {
+		t.Helper()
+		select {
+		case f := <-firedCh:
+			if f.TimelineID != id || f.Elapsed != elapsed || f.Err != "" {
+				t.Fatalf("fired %s at %s (err %q), want %s at %s", f.TimelineID, f.Elapsed, f.Err, id, elapsed)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s did not fire at %s", id, elapsed)
+		}
+	}
+	step := func(d time.Duration) {
+		t.Helper()
+		waitWaiters(t, clock, 1)
+		clock.Advance(d)
+	}
+
+	step(10 * time.Second)
+	expect("every-10s", 10*time.Second)
+	step(5 * time.Second)
+	expect("once", 15*time.Second)
+	step(5 * time.Second)
+	expect("every-10s", 20*time.Second)
+
+	if err := e.Pause(); err != nil {
+		t.Fatal(err)
+	}
+	waitWaiters(t, clock, 0)
+	clock.Advance(time.Hour)
+	if st := e.Status(); st.State != StatePaused || st.Elapsed != 20*time.Second {
+		t.Fatalf("paused status = %s at %s, want paused at 20s", st.State, st.Elapsed)
+	}
+
+	if err := e.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	step(10 * time.Second)
+	expect("every-10s", 30*time.Second)
+
+	// 30s 之後超過 Until，不會再觸發
+	waitWaiters(t, clock, 0)
+	st := e.Status()
+	if st.State != StateRunning || st.Fired != 4 || st.Pending != 0 {
+		t.Fatalf("status = %+v, want running with 4 fired and nothing pending", st)
+	}
+	select {
+	case f := <-firedCh:
+		t.Fatalf("unexpected fire %s at %s", f.TimelineID, f.Elapsed)
+	default:
+	}
+}
+
+// stationDB 是只有一個 mock_wcs_station (沒有延遲、已啟用) 的資料庫，只給 wcs.Mock.Load 使用
+type stationDB struct{ locationID string }
+
+func (d stationDB) Connect(context.Context) (driver.Conn, error) { return d, nil }
+func (d stationDB) Driver() driver.Driver                        { return d }
+func (d stationDB) Open(string) (driver.Conn, error)             { return d, nil }
+func (d stationDB) Close() error                                 { return nil }
+func (d stationDB) Begin() (driver.Tx, error)                    { return nil, errors.New("not supported") }
+func (d stationDB) Prepare(string) (driver.Stmt, error)          { return nil, errors.New("not supported") }
+
+func (d stationDB) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	return &stationRows{row: []driver.Value{"st-1", "CONVEYOR", int64(0), true, d.locationID}}, nil
+}
+
+type stationRows struct{ row []driver.Value }
+
+func (r *stationRows) Columns() []string {
+	return []string{"id", "machine_type", "delay_ms", "is_enabled", "locationId"}
+}
+func (r *stationRows) Close() error { return nil }
+
+func (r *stationRows) Next(dest []driver.Value) error {
+	if r.row == nil {
+		return io.EOF
+	}
+	copy(dest, r.row)
+	r.row = nil
+	return nil
+}
+
+// stationEngine 建立 L1 (P1) 有 mock WCS 站點的時間軸並開始 Run，站點先注入 faults
+func stationEngine(t *testing.T, faults ...wcs.Fault) (*Engine, *peripheral.YFYStackManager, *wcs.Mock, *infra.ManualClock, chan Fired) {
+	t.Helper()
+	logger, err := infra.NewSlogLogger(io.Discard, "error", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := infra.NewManualClock(time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC))
+	bus := infra.New()
+
+	stacks := peripheral.NewStackManagerFromSnapshot(&peripheral.StateSnapshot{
+		ScriptID: "script-1",
+		Stacks:   map[string]peripheral.YFYStack{"L1": {PeripheralID: "P1", StackCount: 2, Booker: "none"}},
+	}, nil, nil, bus, logger)
+
+	conn := sql.OpenDB(stationDB{locationID: "L1"})
+	t.Cleanup(func() { conn.Close() })
+	stations := wcs.New(conn, logger, time.Second)
+	stations.SetClock(clock)
+	if err := stations.Load(context.Background(), "script-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stations.Inject("L1", faults...); err != nil {
+		t.Fatal(err)
+	}
+
+	firedCh := make(chan Fired, 16)
+	bus.Subscribe(EventFired, func(data interface{}) { firedCh <- data.(Fired) })
+
+	e := New(nil, stacks, nil, bus, logger)
+	if err := e.SetClock(clock); err != nil {
+		t.Fatal(err)
+	}
+	e.SetStations(stations)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- e.Run(ctx) }()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	return e, stacks, stations, clock, firedCh
+}
+
+// fireNext 前進 d 並等下一次觸發
+func fireNext(t *testing.T, clock *infra.ManualClock, firedCh chan Fired, d time.Duration) Fired {
+	t.Helper()
+	waitWaiters(t, clock, 1)
+	clock.Advance(d)
+	select {
+	case f := <-firedCh:
+		return f
+	case <-time.After(time.Second):
+		t.Fatal("timeline did not fire")
+	}
+	return Fired{}
+}
+
+func cargoOn(t *testing.T, stacks *peripheral.YFYStackManager, locID string) int {
+	t.Helper()
+	s, ok := stacks.Stack(locID)
+	if !ok {
+		t.Fatalf("stack %s is missing", locID)
+	}
+	return len(s.Cargo)
+}
+
+// TestEngineStations 生成經過 mock WCS，站點注入的故障讓該次觸發失敗且不放貨
+func TestEngineStations(t *testing.T) {
+	e, stacks, _, clock, firedCh := stationEngine(t, wcs.Fault{Kind: wcs.FaultError, Code: "E42", Times: 1})
+
+	err := e.start("script-1", []Entry{
+		{ID: "spawn", Type: db.TimelineTypeSPAWNCARGO, PeripheralID: "P1", At: 10 * time.Second, Interval: 10 * time.Second, Until: 20 * time.Second},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if f := fireNext(t, clock, firedCh, 10*time.Second); !strings.Contains(f.Err, "E42") || len(f.Cargo) != 0 {
+		t.Fatalf("first spawn = %q with %v, want the station error E42", f.Err, f.Cargo)
+	}
+	if n := cargoOn(t, stacks, "L1"); n != 0 {
+		t.Fatalf("L1 has %d cargo after the failed spawn, want 0", n)
+	}
+
+	if f := fireNext(t, clock, firedCh, 10*time.Second); f.Err != "" || len(f.Cargo) != 1 {
+		t.Fatalf("second spawn = %q with %v, want one cargo", f.Err, f.Cargo)
+	}
+	if n := cargoOn(t, stacks, "L1"); n != 1 {
+		t.Fatalf("L1 has %d cargo after the second spawn, want 1", n)
+	}
+}
+
+// TestEngineRequestTimeout 經過 request bus 時，卡住的站點在逾時後失敗，之後也不會再放貨
+func TestEngineRequestTimeout(t *testing.T) {
+	e, stacks, stations, clock, firedCh := stationEngine(t, wcs.Fault{Kind: wcs.FaultStuck, Times: 1})
+
+	requests := infra.NewWithConfig(infra.Config{DefaultTimeout: 50 * time.Millisecond})
+	stations.Serve(requests)
+	e.SetRequests(requests)
+
+	if err := e.start("script-1", []Entry{{ID: "spawn", Type: db.TimelineTypeSPAWNCARGO, PeripheralID: "P1", At: 10 * time.Second}}); err != nil {
+		t.Fatal(err)
+	}
+	if f := fireNext(t, clock, firedCh, 10*time.Second); !strings.Contains(f.Err, "timeout") {
+		t.Fatalf("spawn on a stuck station = %q, want a request timeout", f.Err)
+	}
+
+	// 逾時後取消，站點不再卡著，也沒有晚一步放上貨物
+	deadline := time.Now().Add(time.Second)
+	for {
+		st, err := stations.Station("L1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.Stuck == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("station still has %d stuck commands", st.Stuck)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := cargoOn(t, stacks, "L1"); n != 0 {
+		t.Fatalf("L1 has %d cargo after the timed out spawn, want 0", n)
+	}
+}
@@ -0,0 +1,195 @@
+package timeline
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/peripheral"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Entry 是一筆啟用中的 timeline，時間都是相對於時間軸開始的偏移
+type Entry struct {
+	ID                 string
+	Type               db.TimelineType
+	SimulationResultID string
+
+	At       time.Duration // 第一次觸發
+	Interval time.Duration // 重複間隔，0 表示只觸發一次
+	Until    time.Duration // 重複到這個時間為止，0 表示直到停止
+
+	PeripheralID string // SPAWN_CARGO 的 peripheral_name_id，SHIFT_CARGO 的 shift_peripheral_id
+	GroupID      string // *_GROUP 的 peripheral_group_id
+	Count        int    // *_GROUP 一次生成或移走的數量
+	All          bool   // is_spawn_all / is_shift_all：填滿或清空整個群組
+
+	// Template 是 spawn_cargo_info_id 指向的貨物，生成的貨物沿用它的 metadata，nil 表示沒有指定
+	Template *peripheral.CargoData
+}
+
+// loadEntries 讀出腳本所有啟用的 timeline。時間格式錯誤的跳過並回傳錯誤清單
+func loadEntries(ctx context.Context, q *db.Queries, scriptID string) ([]Entry, error) {
+	rows, err := q.ListTimelines(ctx, scriptID)
+	if err != nil {
+		return nil, fmt.Errorf("load timelines of script %s: %w", scriptID, err)
+	}
+
+	templates := make(map[string]*peripheral.CargoData)
+	var (
+		entries []Entry
+		errs    []error
+	)
+
+	for _, r := range rows {
+		e, err := toEntry(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("timeline %s: %w", r.ID, err))
+			continue
+		}
+
+		id := r.SpawnCargoInfoID.String
+		if id == "" {
+			id = r.GroupSpawnCargoInfoID.String
+		}
+		if id != "" {
+			if _, ok := templates[id]; !ok {
+				templates[id], err = loadTemplate(ctx, q, id)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("timeline %s: %w", r.ID, err))
+				}
+			}
+			e.Template = templates[id]
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, errors.Join(errs...)
+}
+
+func toEntry(r db.ListTimelinesRow) (Entry, error) {
+	e := Entry{
+		ID:                 r.ID,
+		Type:               r.Type,
+		SimulationResultID: r.SimulationResultID.String,
+	}
+
+	at, err := parseOffset(r.Timestamp)
+	if err != nil {
+		return e, fmt.Errorf("timestamp: %w", err)
+	}
+	e.At = at
+
+	var (
+		interval sql.NullInt32
+		rng      sql.NullString
+	)
+
+	switch r.Type {
+	case db.TimelineTypeSPAWNCARGO:
+		e.PeripheralID = r.SpawnPeripheralID.String
+	case db.TimelineTypeSHIFTCARGO:
+		e.PeripheralID = r.ShiftPeripheralID.String
+	case db.TimelineTypeSPAWNCARGOGROUP:
+		e.GroupID = r.SpawnGroupID.String
+		e.Count = int(r.SpawnNumber.Int32)
+		e.All = r.IsSpawnAll.Bool
+		interval, rng = r.SpawnInterval, r.SpawnRange
+	case db.TimelineTypeSHIFTCARGOGROUP:
+		e.GroupID = r.ShiftGroupID.String
+		e.Count = int(r.ShiftNumber.Int32)
+		e.All = r.IsShiftAll.Bool
+		interval, rng = r.ShiftInterval, r.ShiftRange
+	}
+
+	// active_interval 是毫秒，-1 (預設) 表示不重複
+	if interval.Valid && interval.Int32 > 0 {
+		e.Interval = time.Duration(interval.Int32) * time.Millisecond
+	}
+	if rng.Valid && rng.String != "" {
+		if e.Until, err = parseRange(rng.String); err != nil {
+			return e, fmt.Errorf("range: %w", err)
+		}
+	}
+
+	return e, nil
+}
+
+func loadTemplate(ctx context.Context, q *db.Queries, cargoID string) (*peripheral.CargoData, error) {
+	r, err := q.GetCargoInfo(ctx, cargoID)
+	if err != nil {
+		return nil, fmt.Errorf("load spawn cargo %s: %w", cargoID, err)
+	}
+	return &peripheral.CargoData{
+		ID:         r.ID,
+		Metadata:   r.Metadata,
+		MetadataID: r.CustomCargoMetadataID.String,
+		CustomID:   r.CustomID.String,
+		Status:     string(r.Status),
+	}, nil
+}
+
+// parseOffset 解析 timeline.timestamp，支援以下寫法：
+//
+//	"90000"        毫秒
+//	"1m30s"        Go duration
+//	"00:01:30"     時:分:秒，也可以是 "01:30" 或帶小數 "00:01:30.5"
+func parseOffset(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return 0, errors.New("empty")
+	}
+
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("negative offset %q", s)
+		}
+		return time.Duration(ms) * time.Millisecond, nil
+	}
+
+	if strings.Contains(s, ":") {
+		parts := strings.Split(s, ":")
+		if len(parts) > 3 {
+			return 0, fmt.Errorf("invalid clock offset %q", s)
+		}
+
+		var d time.Duration
+		for i, p := range parts {
+			v, err := strconv.ParseFloat(p, 64)
+			if err != nil || v < 0 {
+				return 0, fmt.Errorf("invalid clock offset %q", s)
+			}
+			// 最後一段是秒，往前依序是分、時
+			unit := time.Second
+			for j := len(parts) - 1; j > i; j-- {
+				unit *= 60
+			}
+			d += time.Duration(v * float64(unit))
+		}
+		return d, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset %q", s)
+	}
+	if d < 0 {
+		return 0, fmt.Errorf("negative offset %q", s)
+	}
+	return d, nil
+}
+
+// parseRange 解析 range，重複觸發到這個時間為止。
+// 可以只寫結束時間，或寫成 "開始-結束" / "開始~結束" 取結束時間，格式同 parseOffset
+func parseRange(s string) (time.Duration, error) {
+	for _, sep := range []string{"~", "-"} {
+		if i := strings.LastIndex(s, sep); i > 0 {
+			return parseOffset(s[i+1:])
+		}
+	}
+	return parseOffset(s)
+}
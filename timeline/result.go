@@ -0,0 +1,532 @@
+package timeline
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+	"maps"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+var ErrNoResult = errors.New("no simulation result yet")
+
+// drainTimeout 是 Run 結束前寫入佇列中結果的期限
+const drainTimeout = 5 * time.Second
+
+// Counts 是貨物進出的次數
+type Counts struct {
+	Spawned   int `json:"spawned"`   // 時間軸生成
+	Loaded    int `json:"loaded"`    // 放上堆疊，包含生成與搬入
+	Offloaded int `json:"offloaded"` // 從堆疊取走，包含移走與搬出
+	Shifted   int `json:"shifted"`   // 時間軸移走
+}
+
+// StackResult 是單一堆疊在這次執行的統計
+type StackResult struct {
+	LocationID   string `json:"locationId"`
+	PeripheralID string `json:"peripheralId,omitempty"`
+	GroupID      string `json:"groupId,omitempty"`
+	Counts
+	TransfersIn  int `json:"transfersIn"`
+	TransfersOut int `json:"transfersOut"`
+	Capacity     int `json:"capacity"`
+	PeakCargo    int `json:"peakCargo"`
+}
+
+// GroupResult 是 peripheral group 在這次執行的統計，使用率 (貨物數 / 容量) 依取樣計算
+type GroupResult struct {
+	GroupID string `json:"groupId"`
+	Counts
+	AvgUtilisation  float64 `json:"avgUtilisation"`
+	PeakUtilisation float64 `json:"peakUtilisation"`
+
+	samples int
+}
+
+// Sample 是某個時間點所有堆疊的使用率
+type Sample struct {
+	ElapsedMs int64              `json:"elapsedMs"`
+	Cargo     int                `json:"cargo"`
+	Capacity  int                `json:"capacity"`
+	Groups    map[string]float64 `json:"groups,omitempty"` // groupId -> 使用率
+}
+
+// Result 是一次時間軸執行的統計。結束時寫入 simulation_result，完整內容另存成 <id>.json
+type Result struct {
+	ID       string `json:"id"`
+	ScriptID string `json:"scriptId"`
+	// StartedAt、EndedAt 是真實時間，不受倍速影響；模擬時間的進度是 ElapsedMs
+	StartedAt time.Time `json:"startedAt"`
+	EndedAt   time.Time `json:"endedAt,omitzero"`
+	ElapsedMs int64     `json:"elapsedMs"` // 結束時的模擬時間進度
+
+	Totals Counts `json:"totals"`
+	// CargosCarried 是堆疊間搬運 (Transfer) 的次數，即 simulation_result.total_cargos_carried
+	CargosCarried     int `json:"cargosCarried"`
+	Missions          int `json:"missions"`
+	CompletedMissions int `json:"completedMissions"` // 成功發出的 MISSION
+	Failures          int `json:"failures"`          // 觸發失敗的次數
+
+	Stacks      map[string]*StackResult `json:"stacks"`      // locationId
+	Peripherals map[string]*Counts      `json:"peripherals"` // 不是堆疊的週邊 (輸送帶)，依 peripheral_name.id
+	Groups      map[string]*GroupResult `json:"groups"`
+	Samples     []Sample                `json:"samples"`
+}
+
+func (r *Result) clone() Result {
+	out := *r
+	out.Stacks = make(map[string]*StackResult, len(r.Stacks))
+	for k, v := range r.Stacks {
+		s := *v
+		out.Stacks[k] = &s
+	}
+	out.Peripherals = make(map[string]*Counts, len(r.Peripherals))
+	for k, v := range r.Peripherals {
+		c := *v
+		out.Peripherals[k] = &c
+	}
+	out.Groups = make(map[string]*GroupResult, len(r.Groups))
+	for k, v := range r.Groups {
+		g := *v
+		out.Groups[k] = &g
+	}
+	out.Samples = make([]Sample, len(r.Samples))
+	for i, s := range r.Samples {
+		s.Groups = maps.Clone(s.Groups)
+		out.Samples[i] = s
+	}
+	return out
+}
+
+func (r *Result) stack(locID string, info stackInfo) *StackResult {
+	s, ok := r.Stacks[locID]
+	if !ok {
+		s = &StackResult{LocationID: locID}
+		r.Stacks[locID] = s
+	}
+	s.PeripheralID = info.peripheralID
+	s.GroupID = info.groupID
+	s.Capacity = info.capacity
+	s.PeakCargo = max(s.PeakCargo, info.cargo)
+	return s
+}
+
+func (r *Result) group(groupID string) *GroupResult {
+	g, ok := r.Groups[groupID]
+	if !ok {
+		g = &GroupResult{GroupID: groupID}
+		r.Groups[groupID] = g
+	}
+	return g
+}
+
+func (r *Result) peripheral(peripheralID string) *Counts {
+	c, ok := r.Peripherals[peripheralID]
+	if !ok {
+		c = &Counts{}
+		r.Peripherals[peripheralID] = c
+	}
+	return c
+}
+
+// stackInfo 是統計需要的堆疊資料，由 stack.changed 事件持續更新
+type stackInfo struct {
+	cargo        int
+	capacity     int
+	peripheralID string
+	groupID      string
+}
+
+func toStackInfo(s peripheral.YFYStack) stackInfo {
+	return stackInfo{
+		cargo:        len(s.Cargo),
+		capacity:     s.StackCount,
+		peripheralID: s.PeripheralID,
+		groupID:      s.Placement.GroupID,
+	}
+}
+
+// Collector 在時間軸執行期間訂閱週邊事件做統計，停止時寫入 simulation_result。
+// 事件 handler 只更新自己的資料，讀堆疊、取樣與寫入資料庫都在 Run 的 goroutine 裡
+type Collector struct {
+	db       *db.Queries
+	stacks   *peripheral.YFYStackManager
+	logger   infra.Logger
+	clock    infra.Clock   // 取樣用的模擬時間
+	wall     infra.Clock   // 開始與結束的真實時間
+	dir      string        // 明細 JSON 的目錄，空字串表示不寫
+	interval time.Duration // 使用率的取樣間隔 (模擬時間)
+
+	mu    sync.Mutex
+	known map[string]stackInfo // locationId -> 最後一次看到的堆疊
+	stale bool                 // 堆疊整份換掉了，known 要重新取
+	run   *Result              // 執行中的統計，nil 表示沒有在執行
+	last  *Result              // 最後一次結束的統計
+
+	paused  bool
+	base    time.Duration // 最後一次開始或繼續時的進度
+	baseAt  time.Time
+	results chan Result
+}
+
+// NewCollector 建立統計並訂閱 bus，interval 是使用率的取樣間隔，dir 是明細 JSON 的目錄
+func NewCollector(conn *sql.DB, stacks *peripheral.YFYStackManager, bus *infra.EventBus, logger infra.Logger, dir string, interval time.Duration) *Collector {
+	c := &Collector{
+		stacks:   stacks,
+		logger:   logger.With(infra.FieldComponent, "simulation-result"),
+		clock:    infra.NewRealClock(),
+		wall:     infra.NewRealClock(),
+		dir:      dir,
+		interval: interval,
+		known:    make(map[string]stackInfo),
+		results:  make(chan Result, 8),
+	}
+	if conn != nil {
+		c.db = db.New(conn)
+	}
+	for locID, s := range stacks.Snapshot() {
+		c.known[locID] = toStackInfo(s)
+	}
+
+	bus.Subscribe(peripheral.EventStackChanged, c.onStackChanged)
+	bus.Subscribe(EventFired, c.onFired)
+	bus.Subscribe(EventState, c.onState)
+	return c
+}
+
+// SetClock 替換取樣用的時間來源，要和時間軸用同一個
+func (c *Collector) SetClock(clock infra.Clock) {
+	if clock == nil {
+		clock = infra.NewRealClock()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.clock = clock
+}
+
+// Current 回傳執行中的統計，沒有在執行時回傳最後一次的結果
+func (c *Collector) Current() (Result, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case c.run != nil:
+		return c.run.clone(), nil
+	case c.last != nil:
+		return c.last.clone(), nil
+	}
+	return Result{}, ErrNoResult
+}
+
+// onStackChanged 在堆疊管理的鎖裡被呼叫，只能更新自己的資料
+func (c *Collector) onStackChanged(data interface{}) {
+	change, ok := data.(peripheral.StackChange)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch change.Type {
+	case peripheral.ChangeReset:
+		c.stale = true
+	case peripheral.ChangeDelete:
+		delete(c.known, change.LocationID)
+	case peripheral.ChangeUpsert:
+		prev, ok := c.known[change.LocationID]
+		info := toStackInfo(*change.Stack)
+		c.known[change.LocationID] = info
+		if c.run == nil || !ok {
+			return
+		}
+		c.count(change.LocationID, info, info.cargo-prev.cargo)
+	case peripheral.ChangeTransfer:
+		from, to := toStackInfo(*change.FromStack), toStackInfo(*change.Stack)
+		c.known[change.FromLocationID] = from
+		c.known[change.LocationID] = to
+		if c.run == nil {
+			return
+		}
+		c.count(change.FromLocationID, from, -1)
+		c.count(change.LocationID, to, 1)
+		c.run.Stacks[change.FromLocationID].TransfersOut++
+		c.run.Stacks[change.LocationID].TransfersIn++
+		c.run.CargosCarried++
+	}
+}
+
+// count 記錄堆疊的貨物數變化，呼叫前必須持有 mu 且正在執行
+func (c *Collector) count(locID string, info stackInfo, delta int) {
+	s := c.run.stack(locID, info)
+	var g *GroupResult
+	if info.groupID != "" {
+		g = c.run.group(info.groupID)
+	}
+
+	for _, counts := range []*Counts{&c.run.Totals, &s.Counts, groupCounts(g)} {
+		if counts == nil {
+			continue
+		}
+		if delta > 0 {
+			counts.Loaded += delta
+		} else {
+			counts.Offloaded -= delta
+		}
+	}
+}
+
+func groupCounts(g *GroupResult) *Counts {
+	if g == nil {
+		return nil
+	}
+	return &g.Counts
+}
+
+func (c *Collector) onFired(data interface{}) {
+	ev, ok := data.(Fired)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.run == nil {
+		return
+	}
+	if ev.Err != "" {
+		c.run.Failures++
+	}
+
+	var add func(*Counts)
+	switch ev.Type {
+	case db.TimelineTypeSPAWNCARGO, db.TimelineTypeSPAWNCARGOGROUP:
+		add = func(n *Counts) { n.Spawned += len(ev.Cargo) }
+	case db.TimelineTypeSHIFTCARGO, db.TimelineTypeSHIFTCARGOGROUP:
+		add = func(n *Counts) { n.Shifted += len(ev.Cargo) }
+	case db.TimelineTypeMISSION:
+		c.run.Missions++
+		if ev.Err == "" {
+			c.run.CompletedMissions++
+		}
+		return
+	default:
+		return
+	}
+
+	add(&c.run.Totals)
+	if ev.GroupID != "" {
+		add(&c.run.group(ev.GroupID).Counts)
+	}
+	if ev.PeripheralID == "" {
+		return
+	}
+	for locID, info := range c.known {
+		if info.peripheralID == ev.PeripheralID {
+			add(&c.run.stack(locID, info).Counts)
+			if info.groupID != "" && info.groupID != ev.GroupID {
+				add(&c.run.group(info.groupID).Counts)
+			}
+			return
+		}
+	}
+	add(c.run.peripheral(ev.PeripheralID))
+}
+
+// onState 在 Engine 的鎖裡被呼叫，只能更新自己的資料，寫入交給 Run
+func (c *Collector) onState(data interface{}) {
+	ev, ok := data.(StateChanged)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch ev.State {
+	case StateRunning:
+		if c.run == nil {
+			id := ev.SimulationResultID
+			if id == "" {
+				id = rand.Text()
+			}
+			c.run = &Result{
+				ID:          id,
+				ScriptID:    ev.ScriptID,
+				StartedAt:   c.wall.Now(),
+				Stacks:      make(map[string]*StackResult),
+				Peripherals: make(map[string]*Counts),
+				Groups:      make(map[string]*GroupResult),
+			}
+		}
+		c.paused = false
+		c.base, c.baseAt = ev.Elapsed, ev.Timestamp
+	case StatePaused:
+		c.paused = true
+	case StateStopped:
+		if c.run == nil {
+			return
+		}
+		c.run.EndedAt = c.wall.Now()
+		c.run.ElapsedMs = ev.Elapsed.Milliseconds()
+		c.last, c.run = c.run, nil
+
+		select {
+		case c.results <- c.last.clone():
+		default:
+			c.logger.Warn("simulation result %s dropped, writer is behind", c.last.ID)
+		}
+	}
+}
+
+// Run 定時取樣使用率並寫入結束的統計，執行到 ctx 取消為止
+func (c *Collector) Run(ctx context.Context) error {
+	c.mu.Lock()
+	ticker := c.clock.NewTicker(c.interval)
+	c.mu.Unlock()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// 結束前停止的執行可能還在佇列裡，不跟著 ctx 取消，在期限內寫完
+			drainCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), drainTimeout)
+			defer cancel()
+			return c.Flush(drainCtx)
+		case <-ticker.C():
+			c.sample()
+		case r := <-c.results:
+			c.save(ctx, r)
+		}
+	}
+}
+
+// Flush 寫入佇列中還沒寫的結果，ctx 到期時放棄剩下的並回傳錯誤。
+// Run 結束後才停止的時間軸 (例如關機時) 由呼叫端用它寫入
+func (c *Collector) Flush(ctx context.Context) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			if n := len(c.results); n > 0 {
+				return fmt.Errorf("%d simulation results not written: %w", n, err)
+			}
+			return nil
+		}
+
+		select {
+		case r := <-c.results:
+			c.save(ctx, r)
+		default:
+			return nil
+		}
+	}
+}
+
+func (c *Collector) save(ctx context.Context, r Result) {
+	if err := c.write(ctx, r); err != nil {
+		c.logger.Error("write simulation result %s: %v", r.ID, err)
+		return
+	}
+	c.logger.With(infra.FieldScriptID, r.ScriptID).
+		Info("simulation result %s written: %d spawned, %d shifted, %d carried", r.ID, r.Totals.Spawned, r.Totals.Shifted, r.CargosCarried)
+}
+
+// sample 記錄目前的使用率，堆疊整份換掉後順便重新取得堆疊資料
+func (c *Collector) sample() {
+	c.mu.Lock()
+	stale, running := c.stale, c.run != nil && !c.paused
+	c.mu.Unlock()
+
+	if stale {
+		known := make(map[string]stackInfo)
+		for locID, s := range c.stacks.Snapshot() {
+			known[locID] = toStackInfo(s)
+		}
+		c.mu.Lock()
+		c.known, c.stale = known, false
+		c.mu.Unlock()
+	}
+	if !running {
+		return
+	}
+
+	groups := c.stacks.GroupSummaries()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.run == nil || c.paused {
+		return
+	}
+
+	s := Sample{
+		ElapsedMs: (c.base + c.clock.Since(c.baseAt)).Milliseconds(),
+		Groups:    make(map[string]float64, len(groups)),
+	}
+	for _, info := range c.known {
+		s.Cargo += info.cargo
+		s.Capacity += info.capacity
+	}
+	for _, g := range groups {
+		if g.Capacity == 0 {
+			continue
+		}
+		u := float64(g.CargoCount) / float64(g.Capacity)
+		s.Groups[g.GroupID] = u
+
+		gr := c.run.group(g.GroupID)
+		gr.AvgUtilisation = (gr.AvgUtilisation*float64(gr.samples) + u) / float64(gr.samples+1)
+		gr.PeakUtilisation = max(gr.PeakUtilisation, u)
+		gr.samples++
+	}
+	c.run.Samples = append(c.run.Samples, s)
+}
+
+// write 把明細存成 JSON 並寫入 simulation_result。
+// amr_stat 和 mission_success_rate 由派車端填寫 (這裡只知道任務有沒有發出，不知道有沒有完成)，
+// 新增時先放空物件和 0，已存在時不覆蓋
+func (c *Collector) write(ctx context.Context, r Result) error {
+	var errs []error
+
+	if c.dir != "" {
+		detail, err := json.MarshalIndent(r, "", "  ")
+		if err == nil {
+			err = os.MkdirAll(c.dir, 0o755)
+		}
+		if err == nil {
+			err = os.WriteFile(filepath.Join(c.dir, r.ID+".json"), detail, 0o644)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("detail: %w", err))
+		}
+	}
+
+	if c.db != nil {
+		err := c.db.UpsertSimulationResult(ctx, db.UpsertSimulationResultParams{
+			ID:                 r.ID,
+			AmrStat:            json.RawMessage("{}"),
+			Starttime:          r.StartedAt.Format(time.RFC3339),
+			Endtime:            r.EndedAt.Format(time.RFC3339),
+			TotalCargosCarried: int32(r.CargosCarried),
+			MissionSuccessRate: 0,
+			TotalMissionCount:  int32(r.Missions),
+			CompletedMissions:  int32(r.CompletedMissions),
+		})
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,106 @@
+package timeline
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kenmec/peripheral/jimmy/infra"
+	"kenmec/peripheral/jimmy/peripheral"
+)
+
+func testCollector(t *testing.T) (*Collector, *infra.EventBus, string) {
+	t.Helper()
+	logger, err := infra.NewSlogLogger(io.Discard, "error", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bus := infra.New()
+	stacks := peripheral.NewStackManagerFromSnapshot(&peripheral.StateSnapshot{ScriptID: "script-1"}, nil, nil, bus, logger)
+
+	dir := t.TempDir()
+	return NewCollector(nil, stacks, bus, logger, dir, time.Second), bus, dir
+}
+
+func written(t *testing.T, dir, id string) bool {
+	t.Helper()
+	_, err := os.Stat(filepath.Join(dir, id+".json"))
+	return err == nil
+}
+
+// TestCollectorRunDrains 取消後 Run 仍要寫完已經在佇列裡的結果
+func TestCollectorRunDrains(t *testing.T) {
+	c, _, dir := testCollector(t)
+	c.results <- Result{ID: "r1"}
+	c.results <- Result{ID: "r2"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Run(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"r1", "r2"} {
+		if !written(t, dir, id) {
+			t.Errorf("result %s was not written before Run returned", id)
+		}
+	}
+}
+
+// TestCollectorFlushAfterStop 關機時 Run 已經結束才停止時間軸，結果由 Flush 寫入
+func TestCollectorFlushAfterStop(t *testing.T) {
+	c, bus, dir := testCollector(t)
+
+	now := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	bus.PublishSync(EventState, StateChanged{State: StateRunning, ScriptID: "script-1", SimulationResultID: "r1", StartedAt: now, Timestamp: now})
+	bus.PublishSync(EventState, StateChanged{State: StateStopped, ScriptID: "script-1", SimulationResultID: "r1", Timestamp: now.Add(time.Minute)})
+
+	if err := c.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if !written(t, dir, "r1") {
+		t.Fatal("result r1 was not written by Flush")
+	}
+
+	// 期限已過時不寫入，回傳剩下的數量
+	bus.PublishSync(EventState, StateChanged{State: StateRunning, ScriptID: "script-1", SimulationResultID: "r2", StartedAt: now, Timestamp: now})
+	bus.PublishSync(EventState, StateChanged{State: StateStopped, ScriptID: "script-1", SimulationResultID: "r2", Timestamp: now})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.Flush(ctx); err == nil {
+		t.Fatal("Flush with an expired context = nil, want an error")
+	}
+	if written(t, dir, "r2") {
+		t.Fatal("result r2 was written after the deadline")
+	}
+}
+
+// TestCollectorWallClock 開始與結束時間用真實時間，倍速下的模擬進度另外記在 ElapsedMs
+func TestCollectorWallClock(t *testing.T) {
+	c, bus, _ := testCollector(t)
+
+	start := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	wall := infra.NewManualClock(start)
+	c.wall = wall
+
+	// 時間軸用 10 倍速，StartedAt、Timestamp 是模擬時間
+	sim := start.Add(time.Hour)
+	bus.PublishSync(EventState, StateChanged{State: StateRunning, ScriptID: "script-1", SimulationResultID: "r1", StartedAt: sim, Timestamp: sim})
+	wall.Advance(time.Minute)
+	bus.PublishSync(EventState, StateChanged{State: StateStopped, ScriptID: "script-1", SimulationResultID: "r1", Elapsed: 10 * time.Minute, Timestamp: sim.Add(10 * time.Minute)})
+
+	r, err := c.Current()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.StartedAt.Equal(start) || !r.EndedAt.Equal(start.Add(time.Minute)) {
+		t.Errorf("StartedAt, EndedAt = %v, %v, want %v, %v", r.StartedAt, r.EndedAt, start, start.Add(time.Minute))
+	}
+	if r.ElapsedMs != (10 * time.Minute).Milliseconds() {
+		t.Errorf("ElapsedMs = %d, want %d", r.ElapsedMs, (10 * time.Minute).Milliseconds())
+	}
+}
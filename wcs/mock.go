@@ -0,0 +1,350 @@
+package wcs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"kenmec/peripheral/jimmy/db"
+	"kenmec/peripheral/jimmy/infra"
+	"sort"
+	"sync"
+	"time"
+)
+
+var (
+	ErrStationNotFound = errors.New("mock wcs station not found")
+	ErrStationDisabled = errors.New("mock wcs station is disabled")
+	ErrStationTimeout  = errors.New("mock wcs station timed out")
+	ErrInvalidFault    = errors.New("invalid fault")
+)
+
+// StationError 是注入的站點錯誤碼，模擬 WCS 回報的異常
+type StationError struct {
+	LocationID string
+	Code       string
+	Message    string
+}
+
+func (e *StationError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("station %s error %s", e.LocationID, e.Code)
+	}
+	return fmt.Sprintf("station %s error %s: %s", e.LocationID, e.Code, e.Message)
+}
+
+// FaultKind 是注入的故障種類
+type FaultKind string
+
+const (
+	FaultTimeout FaultKind = "timeout" // 等到逾時才回應 ErrStationTimeout
+	FaultError   FaultKind = "error"   // 立刻回應 StationError
+	FaultStuck   FaultKind = "stuck"   // 卡住，直到清除故障或呼叫端放棄
+)
+
+// Fault 是一筆注入的故障，依序套用在之後的指令上
+type Fault struct {
+	Kind    FaultKind
+	Code    string // FaultError 的錯誤碼
+	Message string
+	// Times 是要套用幾次指令，0 表示一直套用到清除為止
+	Times int
+}
+
+func (f Fault) validate() error {
+	switch f.Kind {
+	case FaultTimeout, FaultError, FaultStuck:
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrInvalidFault, f.Kind)
+	}
+	if f.Times < 0 {
+		return fmt.Errorf("%w: times must not be negative: %d", ErrInvalidFault, f.Times)
+	}
+	return nil
+}
+
+// Station 是一個 mock_wcs_station 目前的設定，Enabled 與 Delay 可以在執行中修改
+type Station struct {
+	ID          string
+	LocationID  string
+	MachineType db.MockWcsStationMachineType
+	Delay       time.Duration
+	Enabled     bool
+	Stuck       int     // 卡在 FaultStuck 的指令數
+	Faults      []Fault // 還沒用完的故障
+}
+
+type station struct {
+	Station
+	release chan struct{} // 清除故障時關閉，叫醒卡住的指令
+}
+
+// Mock 模擬 WCS 站點的回應：每個指令先等 delay_ms，停用的站點拒絕指令，
+// 並依序套用注入的故障。沒有設定 mock_wcs_station 的位置直接執行
+type Mock struct {
+	db      *db.Queries
+	logger  infra.Logger
+	clock   infra.Clock
+	timeout time.Duration
+
+	mu       sync.Mutex
+	stations map[string]*station // locationId -> 站點
+}
+
+// New 建立 mock WCS，timeout 是 FaultTimeout 讓指令等多久才回應
+func New(conn *sql.DB, logger infra.Logger, timeout time.Duration) *Mock {
+	m := &Mock{
+		logger:   logger.With(infra.FieldComponent, "mock-wcs"),
+		clock:    infra.NewRealClock(),
+		timeout:  timeout,
+		stations: make(map[string]*station),
+	}
+	if conn != nil {
+		m.db = db.New(conn)
+	}
+	return m
+}
+
+// SetClock 替換延遲與逾時用的時間來源
+func (m *Mock) SetClock(c infra.Clock) {
+	if c == nil {
+		c = infra.NewRealClock()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock = c
+}
+
+// Load 讀出腳本的所有站點並整個替換，原本注入的故障一併清除
+func (m *Mock) Load(ctx context.Context, scriptID string) error {
+	if m.db == nil {
+		return nil
+	}
+
+	rows, err := m.db.ListMockWcsStations(ctx, sql.NullString{String: scriptID, Valid: true})
+	if err != nil {
+		return fmt.Errorf("load mock wcs stations of script %s: %w", scriptID, err)
+	}
+
+	stations := make(map[string]*station, len(rows))
+	for _, r := range rows {
+		stations[r.Locationid] = &station{
+			Station: Station{
+				ID:          r.ID,
+				LocationID:  r.Locationid,
+				MachineType: r.MachineType,
+				Delay:       time.Duration(r.DelayMs) * time.Millisecond,
+				Enabled:     r.IsEnabled,
+			},
+			release: make(chan struct{}),
+		}
+	}
+
+	m.mu.Lock()
+	old := m.stations
+	m.stations = stations
+	m.mu.Unlock()
+
+	for _, s := range old {
+		close(s.release)
+	}
+	return nil
+}
+
+// Stations 回傳所有站點的複本，依 locationId 排序
+func (m *Mock) Stations() []Station {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Station, 0, len(m.stations))
+	for _, s := range m.stations {
+		out = append(out, s.clone())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LocationID < out[j].LocationID })
+	return out
+}
+
+// Station 回傳單一站點的複本
+func (m *Mock) Station(locationID string) (Station, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.station(locationID)
+	if err != nil {
+		return Station{}, err
+	}
+	return s.clone(), nil
+}
+
+func (s *station) clone() Station {
+	c := s.Station
+	c.Faults = append([]Fault(nil), s.Faults...)
+	return c
+}
+
+// station 找出 locationID 的站點，呼叫前必須持有 mu
+func (m *Mock) station(locationID string) (*station, error) {
+	s, ok := m.stations[locationID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrStationNotFound, locationID)
+	}
+	return s, nil
+}
+
+// Configure 在執行中修改站點的啟用狀態與延遲，nil 表示不修改。不會寫回資料庫
+func (m *Mock) Configure(locationID string, enabled *bool, delay *time.Duration) (Station, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.station(locationID)
+	if err != nil {
+		return Station{}, err
+	}
+	if enabled != nil {
+		s.Enabled = *enabled
+	}
+	if delay != nil {
+		s.Delay = max(*delay, 0)
+	}
+	return s.clone(), nil
+}
+
+// Inject 把故障排到站點的故障佇列後面
+func (m *Mock) Inject(locationID string, faults ...Fault) (Station, error) {
+	for _, f := range faults {
+		if err := f.validate(); err != nil {
+			return Station{}, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.station(locationID)
+	if err != nil {
+		return Station{}, err
+	}
+	s.Faults = append(s.Faults, faults...)
+	m.logger.With(infra.FieldLocationID, locationID).Info("injected %d faults", len(faults))
+	return s.clone(), nil
+}
+
+// ClearFaults 清除站點的故障，卡住的指令以 ErrStationTimeout 結束
+func (m *Mock) ClearFaults(locationID string) (Station, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, err := m.station(locationID)
+	if err != nil {
+		return Station{}, err
+	}
+	s.Faults = nil
+	close(s.release)
+	s.release = make(chan struct{})
+	return s.clone(), nil
+}
+
+// next 取出這個指令要套用的故障，呼叫前必須持有 mu
+func (s *station) next() (Fault, bool) {
+	if len(s.Faults) == 0 {
+		return Fault{}, false
+	}
+	f := s.Faults[0]
+	if f.Times > 0 {
+		s.Faults[0].Times--
+		if s.Faults[0].Times == 0 {
+			s.Faults = s.Faults[1:]
+		}
+	}
+	return f, true
+}
+
+// Do 以站點的行為執行指令：停用時拒絕，套用下一個故障，等待 delay 後才執行 fn
+func (m *Mock) Do(ctx context.Context, locationID string, fn func(ctx context.Context) error) error {
+	m.mu.Lock()
+	s, ok := m.stations[locationID]
+	if !ok {
+		m.mu.Unlock()
+		return fn(ctx)
+	}
+	if !s.Enabled {
+		m.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrStationDisabled, locationID)
+	}
+	fault, faulted := s.next()
+	if faulted && fault.Kind == FaultStuck {
+		s.Stuck++
+	}
+	delay, release, clock := s.Delay, s.release, m.clock
+	m.mu.Unlock()
+
+	log := m.logger.With(infra.FieldLocationID, locationID)
+
+	if faulted {
+		log.Debug("applying %s fault", fault.Kind)
+		switch fault.Kind {
+		case FaultError:
+			return &StationError{LocationID: locationID, Code: fault.Code, Message: fault.Message}
+		case FaultTimeout:
+			if err := sleep(ctx, clock, m.timeout); err != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %s after %s", ErrStationTimeout, locationID, m.timeout)
+		case FaultStuck:
+			defer func() {
+				m.mu.Lock()
+				s.Stuck--
+				m.mu.Unlock()
+			}()
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-release:
+				return fmt.Errorf("%w: %s was stuck", ErrStationTimeout, locationID)
+			}
+		}
+	}
+
+	if err := sleep(ctx, clock, delay); err != nil {
+		return err
+	}
+	return fn(ctx)
+}
+
+// TopicCommand 是經過 RequestResponseBus 交給站點執行的指令，內容是 Command
+const TopicCommand = "wcs.command"
+
+// Command 是 TopicCommand 的內容，Run 由 LocationID 的站點以 Do 執行
+type Command struct {
+	LocationID string
+	Run        func(ctx context.Context) error
+}
+
+// Serve 在 rb 處理 TopicCommand，回傳 handler ID。經過 request bus 的指令有逾時與延遲的指標
+func (m *Mock) Serve(rb *infra.RequestResponseBus) int {
+	return rb.RegisterHandler(TopicCommand, func(ctx context.Context, req infra.Request) (interface{}, error) {
+		cmd, ok := req.Data.(Command)
+		if !ok {
+			return nil, fmt.Errorf("%s: unexpected request %T", TopicCommand, req.Data)
+		}
+		return nil, m.Do(ctx, cmd.LocationID, cmd.Run)
+	})
+}
+
+// sleep 依 clock 等待 d，ctx 取消時提早回傳
+func sleep(ctx context.Context, clock infra.Clock, d time.Duration) error {
+	if d <= 0 {
+		return nil
+	}
+	t := clock.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C():
+		return nil
+	}
+}